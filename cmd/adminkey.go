@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/adminkeys"
+	"github.com/pageton/temp-mail/internal/migrate"
+	"github.com/pageton/temp-mail/internal/storage"
+)
+
+// runAdminKey implements "temp-mail admin-key create|list|revoke" and
+// returns the process exit code.
+func runAdminKey(args []string) int {
+	flags := flag.NewFlagSet("admin-key", flag.ExitOnError)
+	configPath := flags.String("config", "config.toml", "path to config.toml")
+	name := flags.String("name", "", "create: who or what the key is for")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: temp-mail admin-key [flags] create|list|revoke [id]")
+		fmt.Fprintln(flags.Output(), "  create  create a key for the /admin routes and print it once")
+		fmt.Fprintln(flags.Output(), "  list    list the keys")
+		fmt.Fprintln(flags.Output(), "  revoke  delete the key with the given ID")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 1 || flags.NArg() > 2 || (flags.NArg() == 2) != (flags.Arg(0) == "revoke") {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Println("Error loading config:", err)
+		return 1
+	}
+	queries, database, err := storage.Open(cfg.Database)
+	if err != nil {
+		log.Println("Error opening database:", err)
+		return 1
+	}
+	defer database.Close()
+	ctx := context.Background()
+
+	status, err := migrate.Check(ctx, database, cfg.Database.Backend)
+	if err != nil {
+		log.Println("Error reading schema version:", err)
+		return 1
+	}
+	if len(status.Pending) > 0 {
+		log.Println("Database schema is out of date: run `temp-mail migrate up`")
+		return 1
+	}
+
+	switch flags.Arg(0) {
+	case "create":
+		if *name == "" {
+			log.Println("-name is required")
+			return 2
+		}
+		id, key, err := adminkeys.Create(ctx, queries, *name)
+		if err != nil {
+			log.Println("Error creating admin key:", err)
+			return 1
+		}
+		fmt.Printf("id:  %s\nkey: %s\n", id, key)
+		fmt.Println("Store the key now, it cannot be shown again.")
+	case "list":
+		keys, err := queries.GetAdminKeys(ctx)
+		if err != nil {
+			log.Println("Error listing admin keys:", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tCREATED\tLAST USED")
+		for _, k := range keys {
+			lastUsed := "never"
+			if k.Lastusedat.Valid {
+				lastUsed = time.UnixMilli(k.Lastusedat.Int64).Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+				k.ID, k.Name, time.UnixMilli(k.Createdat).Format(time.RFC3339), lastUsed)
+		}
+		w.Flush()
+	case "revoke":
+		n, err := queries.DeleteAdminKey(ctx, flags.Arg(1))
+		if err != nil {
+			log.Println("Error revoking admin key:", err)
+			return 1
+		}
+		if n == 0 {
+			log.Println("No admin key with ID", flags.Arg(1))
+			return 1
+		}
+		fmt.Println("revoked:", flags.Arg(1))
+	default:
+		flags.Usage()
+		return 2
+	}
+	return 0
+}
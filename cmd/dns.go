@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/dns"
+)
+
+// runDNS implements "temp-mail dns" and returns the process exit code.
+func runDNS(args []string) int {
+	flags := flag.NewFlagSet("dns", flag.ExitOnError)
+	configPath := flags.String("config", "config.toml", "path to config.toml")
+	format := flags.String("format", "zone", "output format: zone, json or cloudflare")
+	check := flags.Bool("check", false, "look up the records and report which are missing or differ")
+	resolver := flags.String("resolver", "", "DNS server (host:port) for -check, overrides [dns] resolver")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: temp-mail dns [flags]")
+		fmt.Fprintln(flags.Output(), "Prints the recommended DNS records for every configured domain.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Println("Error loading config:", err)
+		return 1
+	}
+	loadDomains(cfg)
+	records, err := dns.Records(cfg)
+	if err != nil {
+		log.Println("Error building DNS records:", err)
+		return 1
+	}
+
+	if *check {
+		addr := cfg.DNS.Resolver
+		if *resolver != "" {
+			addr = *resolver
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		results := dns.Check(ctx, dns.NewResolver(addr), records)
+
+		code := 0
+		for _, r := range results {
+			if r.Status != dns.StatusOK {
+				code = 1
+			}
+		}
+		if *format == "json" {
+			printJSON(results)
+			return code
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "STATUS\tTYPE\tNAME\tEXPECTED\tFOUND")
+		for _, r := range results {
+			found := fmt.Sprint(r.Found)
+			if r.Error != "" {
+				found = r.Error
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Status, r.Type, r.Name, r.Value, found)
+		}
+		w.Flush()
+		return code
+	}
+
+	switch *format {
+	case "zone":
+		fmt.Print(dns.Zone(records))
+	case "json":
+		printJSON(records)
+	case "cloudflare":
+		printJSON(dns.Cloudflare(records))
+	default:
+		flags.Usage()
+		return 2
+	}
+	return 0
+}
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/webhooks"
+)
+
+// exTempFail asks Postfix to keep the message queued and retry later.
+const exTempFail = 75
+
+// forward reads a message from stdin and posts it, signed, to the local
+// webhook. It is run by the Postfix forward script for every delivery and
+// returns the process exit code.
+func forward(args []string) int {
+	flags := flag.NewFlagSet("forward", flag.ExitOnError)
+	configPath := flags.String("config", "config.toml", "path to config.toml")
+	flags.Parse(args)
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Println("Error loading config:", err)
+		if errors.Is(err, fs.ErrPermission) {
+			// Postfix runs alias pipes as its default_privs user.
+			log.Println("The forward script runs as the Postfix default_privs user (usually nobody):",
+				"make the config and secret_file readable to it, e.g. chgrp nogroup <file> && chmod 0640 <file>")
+		}
+		return exTempFail
+	}
+	if err := cfg.Server.Secret.Check(); err != nil {
+		// Kept queued until the secret is fixed, since serve refuses it too.
+		log.Println(err)
+		return exTempFail
+	}
+	body, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		log.Println("Error reading message:", err)
+		return exTempFail
+	}
+
+	url := fmt.Sprintf("http://localhost:%d/webhook", cfg.Server.Port)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		log.Println("Error creating request:", err)
+		return exTempFail
+	}
+	var envelope []string
+	if recipient := strings.TrimSpace(os.Getenv("ORIGINAL_RECIPIENT")); recipient != "" {
+		envelope = append(envelope, recipient)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("X-Envelope-To", strings.Join(envelope, ","))
+	req.Header.Set(webhooks.HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhooks.HeaderSignature,
+		webhooks.SignMessage(string(cfg.Server.Secret), timestamp, envelope, body))
+
+	client := &http.Client{Timeout: time.Minute}
+	res, err := client.Do(req)
+	if err != nil {
+		log.Println("Error posting message:", err)
+		return exTempFail
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode <= 299:
+		return 0
+	case res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusUnprocessableEntity:
+		// Retrying will not make the message parse or find a recipient.
+		log.Println("Webhook rejected message:", res.Status)
+		return 0
+	default:
+		log.Println("Webhook returned", res.Status)
+		return exTempFail
+	}
+}
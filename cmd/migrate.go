@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/migrate"
+	"github.com/pageton/temp-mail/internal/storage"
+)
+
+// runMigrate implements "temp-mail migrate status|up" and returns the
+// process exit code.
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	configPath := flags.String("config", "config.toml", "path to config.toml")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: temp-mail migrate [-config path] status|up")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Println("Error loading config:", err)
+		return 1
+	}
+	_, database, err := storage.Open(cfg.Database)
+	if err != nil {
+		log.Println("Error opening database:", err)
+		return 1
+	}
+	defer database.Close()
+	ctx := context.Background()
+
+	switch flags.Arg(0) {
+	case "status":
+		status, err := migrate.GetStatus(ctx, database, cfg.Database.Backend)
+		if err != nil {
+			log.Println("Error reading schema version:", err)
+			return 1
+		}
+		fmt.Printf("current version: %d\nlatest version:  %d\n", status.Current, status.Latest)
+		if status.Current > status.Latest {
+			fmt.Println("the database was migrated by a newer version of temp-mail")
+		}
+		for _, m := range status.Pending {
+			fmt.Printf("pending: %d_%s\n", m.Version, m.Name)
+		}
+	case "up":
+		applied, err := migrate.Up(ctx, database, cfg.Database.Backend)
+		for _, m := range applied {
+			fmt.Printf("applied: %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Println("Error migrating database:", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("database is up to date")
+		}
+	default:
+		flags.Usage()
+		return 2
+	}
+	return 0
+}
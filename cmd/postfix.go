@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/domains"
+	"github.com/pageton/temp-mail/internal/postfix"
+	"github.com/pageton/temp-mail/internal/storage"
+)
+
+// runPostfix implements "temp-mail postfix render|diff|check|apply|rollback"
+// and returns the process exit code.
+func runPostfix(args []string) int {
+	flags := flag.NewFlagSet("postfix", flag.ExitOnError)
+	configPath := flags.String("config", "config.toml", "path to config.toml")
+	dir := flags.String("dir", "", "render: write the files below this directory instead of printing them")
+	backupDir := flags.String("backup-dir", postfix.BackupDir, "apply, rollback: directory for backups")
+	binary := flags.String("binary", "", "temp-mail binary run by the forward script (default server.forward_binary, else this binary)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: temp-mail postfix [flags] render|diff|check|apply|rollback [backup]")
+		fmt.Fprintln(flags.Output(), "  render    print the generated files, or write them below -dir")
+		fmt.Fprintln(flags.Output(), "  diff      show how the installed files differ from the generated ones")
+		fmt.Fprintln(flags.Output(), "  check     check that Postfix is installed and its files are up to date")
+		fmt.Fprintln(flags.Output(), "  apply     back up the installed files, write the new ones and restart Postfix")
+		fmt.Fprintln(flags.Output(), "  rollback  restore a backup, by default the latest one")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 1 || flags.NArg() > 2 || (flags.NArg() == 2 && flags.Arg(0) != "rollback") {
+		flags.Usage()
+		return 2
+	}
+
+	if flags.Arg(0) == "rollback" {
+		backup := flags.Arg(1)
+		if backup == "" {
+			latest, err := postfix.LatestBackup(*backupDir)
+			if err != nil {
+				log.Println("Error finding backup:", err)
+				return 1
+			}
+			backup = latest
+		}
+		if err := postfix.Rollback(backup); err != nil {
+			log.Println("Error restoring backup:", err)
+			return 1
+		}
+		fmt.Println("restored:", backup)
+		return 0
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Println("Error loading config:", err)
+		return 1
+	}
+	if *binary != "" {
+		cfg.Server.ForwardBinary = *binary
+	}
+	loadDomains(cfg)
+	files, err := postfix.Render(cfg, *configPath)
+	if err != nil && flags.Arg(0) != "check" {
+		log.Println("Error rendering Postfix configuration:", err)
+		return 1
+	}
+
+	switch flags.Arg(0) {
+	case "render":
+		if *dir != "" {
+			if err := postfix.WriteDir(files, *dir); err != nil {
+				log.Println("Error writing files:", err)
+				return 1
+			}
+			return 0
+		}
+		for _, f := range files {
+			fmt.Printf("==> %s <==\n%s\n", f.Path, f.Content)
+		}
+	case "diff":
+		diff, err := postfix.Diff(files)
+		if err != nil {
+			log.Println("Error reading installed files:", err)
+			return 1
+		}
+		fmt.Print(diff)
+		if diff != "" {
+			return 1
+		}
+	case "check":
+		code := 0
+		if err := postfix.Check(); err != nil {
+			fmt.Println("missing dependency:", err)
+			code = 1
+		}
+		// Render refuses an invalid configuration, leaving no files to compare.
+		if err != nil {
+			fmt.Println("cannot render:", err)
+			code = 1
+		}
+		for _, f := range files {
+			installed, err := os.ReadFile(f.Path)
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				fmt.Println("missing:", f.Path)
+				code = 1
+			case err != nil:
+				fmt.Printf("unreadable: %s: %v\n", f.Path, err)
+				code = 1
+			case !bytes.Equal(installed, []byte(f.Content)):
+				fmt.Println("outdated:", f.Path)
+				code = 1
+			default:
+				fmt.Println("up to date:", f.Path)
+			}
+		}
+		return code
+	case "apply":
+		backup, err := postfix.Setup(cfg, *configPath, *backupDir)
+		if err != nil {
+			log.Println("Error applying Postfix configuration:", err)
+			return 1
+		}
+		fmt.Println("Postfix configuration applied, backup in", backup)
+	default:
+		flags.Usage()
+		return 2
+	}
+	return 0
+}
+
+// loadDomains adds the active domains stored in the database to cfg. Only the
+// configured aliases are used when there is no database yet or it cannot be
+// read.
+func loadDomains(cfg *config.Config) {
+	if cfg.Database.Backend == storage.BackendSQLite {
+		if _, err := os.Stat(cfg.Database.Path); err != nil {
+			return
+		}
+	}
+	queries, database, err := storage.Open(cfg.Database)
+	if err == nil {
+		defer database.Close()
+		err = domains.Load(context.Background(), queries, cfg)
+	}
+	if err != nil {
+		log.Println("Using the configured domains only:", err)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/handlers"
+	"github.com/pageton/temp-mail/internal/cleanup"
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/domains"
+	"github.com/pageton/temp-mail/internal/migrate"
+	"github.com/pageton/temp-mail/internal/postfix"
+	"github.com/pageton/temp-mail/internal/pubsub"
+	"github.com/pageton/temp-mail/internal/search"
+	"github.com/pageton/temp-mail/internal/smtpd"
+	"github.com/pageton/temp-mail/internal/storage"
+	"github.com/pageton/temp-mail/internal/webhooks"
+	"github.com/pageton/temp-mail/middlewares"
+)
+
+// serve runs the HTTP API, and the SMTP listener when it is enabled. Postfix
+// is only configured when -setup-postfix is given.
+func serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	configPath := flags.String("config", "config.toml", "path to config.toml")
+	setupPostfix := flags.Bool("setup-postfix", false, "write the Postfix configuration and restart Postfix before starting")
+	flags.Parse(args)
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cfg.Server.Secret.Check(); err != nil {
+		log.Fatal(err)
+	}
+
+	app := fiber.New(fiber.Config{
+		Prefork:   cfg.Server.Prefork,
+		BodyLimit: cfg.Server.BodyLimit,
+	})
+
+	ctx := context.Background()
+
+	queries, database, err := storage.Open(cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	hub := pubsub.NewHub()
+	smtpServer := smtpd.NewServer(cfg, queries, hub)
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-sigChan
+		log.Printf("Received signal %s, closing database", sig)
+		if cfg.SMTP.Enabled {
+			smtpServer.Close()
+		}
+		database.Close()
+		log.Println("Database connection closed")
+		os.Exit(0)
+	}()
+
+	// Prefork children start after the parent has migrated.
+	if cfg.Database.AutoMigrate && !fiber.IsChild() {
+		applied, err := migrate.Up(ctx, database, cfg.Database.Backend)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, m := range applied {
+			log.Printf("Applied migration %d_%s", m.Version, m.Name)
+		}
+	}
+	status, err := migrate.Check(ctx, database, cfg.Database.Backend)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(status.Pending) > 0 {
+		log.Fatalf("Database schema is at version %d, %d is required: run `temp-mail migrate up`",
+			status.Current, status.Latest)
+	}
+
+	// Prefork processes and PostgreSQL replicas pass live update events to
+	// each other through the database.
+	if cfg.Server.Prefork || cfg.Database.Backend == storage.BackendPostgres {
+		relay, err := pubsub.NewRelay(ctx, hub, queries)
+		if err != nil {
+			log.Fatal(err)
+		}
+		relay.Start(ctx)
+	}
+
+	if cfg.Database.Backend == storage.BackendSQLite {
+		if err = setupSQLite(ctx, database); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Domains added through the admin API; every process keeps its own copy.
+	if err = domains.Load(ctx, queries, cfg); err != nil {
+		log.Fatal(err)
+	}
+	// Prefork children start after the parent has set up Postfix.
+	if *setupPostfix && !fiber.IsChild() {
+		if cfg.SMTP.Enabled {
+			log.Fatal("-setup-postfix cannot be used with the built-in SMTP listener")
+		}
+		if _, err = postfix.Setup(cfg, *configPath, postfix.BackupDir); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Domain changes published by any process or replica wake the watcher.
+	// With apply_postfix, the parent process applies them to Postfix unless
+	// the installed virtual_regexp already lists the active domains.
+	var applyDomains func([]string) error
+	if cfg.Domains.ApplyPostfix && !cfg.SMTP.Enabled && !fiber.IsChild() {
+		applyDomains = func(active []string) error {
+			installed, err := postfix.InstalledDomains()
+			if err == nil && slices.Equal(installed, active) {
+				return nil
+			}
+			if _, err := postfix.ApplyDomains(cfg, postfix.BackupDir); err != nil {
+				return err
+			}
+			log.Printf("Applied the Postfix configuration for %d domains", len(active))
+			return nil
+		}
+	}
+	domainChanges := hub.SubscribeDomainChanges()
+	go func() {
+		for range domainChanges.Events() {
+			domains.Refresh()
+		}
+	}()
+	domains.Watch(ctx, queries, cfg, cfg.Domains.RefreshInterval, applyDomains)
+
+	middlewares.Cors(app) // CORS middleware
+
+	// Prefork processes share the rate limit counters through the database.
+	var limiterQueries db.Querier
+	if cfg.Server.Prefork {
+		limiterQueries = queries
+	}
+	middlewares.RateLimiter(app, limiterQueries) // Rate limiter middleware
+
+	// Background jobs run once, in the parent process when preforking.
+	cleaner := cleanup.New(cfg, queries, hub)
+	if !fiber.IsChild() {
+		cleaner.Start(ctx, cfg.Cleanup.Interval) // Expired mail cleanup
+
+		webhooks.NewDispatcher(cfg, queries).Start(ctx) // Outbound webhook deliveries
+	}
+
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("config", cfg)
+		c.Locals("queries", queries)
+		c.Locals("hub", hub)
+		c.Locals("cleaner", cleaner)
+		return c.Next()
+	})
+
+	if cfg.SMTP.Enabled && !fiber.IsChild() {
+		go func() {
+			log.Printf("SMTP listener started on %s", smtpServer.Addr)
+			if err := smtpServer.ListenAndServe(); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	app.Post("/webhook", handlers.Webhook)
+	app.Get("/.well-known/mta-sts.txt", handlers.GetMTASTSPolicy)
+
+	api := app.Group("/api")
+	api.Get("/domains", handlers.GetDomains)
+	api.Post("/addresses", handlers.CreateAddress)
+	api.Get("/delete/:inboxid", middlewares.AddressOwner, handlers.DeleteInbox)
+	api.Get("/email/:email", middlewares.AddressOwner, handlers.GetEmail)
+	api.Post("/email/:email/claim", handlers.ClaimAddress)
+	api.Put("/email/:email/retention", middlewares.AddressOwner, handlers.SetAddressRetention)
+	api.Get("/email/:email/search", middlewares.AddressOwner, handlers.SearchEmails)
+	api.Get("/email/:email/stream", middlewares.AddressOwner, handlers.StreamEmails)
+	api.Get("/email/:email/wait", middlewares.AddressOwner, handlers.WaitForEmail)
+	api.Get("/subscribe", websocket.New(handlers.Subscribe))
+	api.Post("/webhooks", handlers.CreateWebhook)
+	api.Get("/webhooks/:webhookId", handlers.GetWebhook)
+	api.Delete("/webhooks/:webhookId", handlers.DeleteWebhook)
+	api.Get("/webhooks/:webhookId/deliveries", handlers.GetWebhookDeliveries)
+	api.Get("/inbox/:inboxid", middlewares.AddressOwner, handlers.GetInbox)
+	api.Put("/inbox/:inboxid/retention", middlewares.AddressOwner, handlers.SetInboxRetention)
+	api.Get("/inbox/:inboxid/raw", middlewares.AddressOwner, handlers.GetRawMessage)
+	api.Get("/inbox/:inboxid/attachments/:attachmentId", middlewares.AddressOwner, handlers.GetAttachment)
+
+	admin := app.Group("/admin", middlewares.AdminAuth)
+	admin.Get("/cleanup", handlers.GetCleanup)
+	admin.Post("/cleanup", handlers.RunCleanup)
+	admin.Get("/dns", handlers.GetDNSRecords)
+	admin.Get("/domains", handlers.ListDomains)
+	admin.Post("/domains", handlers.CreateDomain)
+	admin.Get("/domains/:domain", handlers.GetDomain)
+	admin.Patch("/domains/:domain", handlers.UpdateDomain)
+	admin.Delete("/domains/:domain", handlers.DeleteDomain)
+	admin.Get("/addresses", handlers.ListAddresses)
+	admin.Get("/messages/:inboxid", handlers.GetInbox)
+	admin.Get("/messages/:inboxid/raw", handlers.GetRawMessage)
+	admin.Get("/messages/:inboxid/attachments/:attachmentId", handlers.GetAttachment)
+	admin.Post("/purge", handlers.Purge)
+	admin.Get("/stats", handlers.GetStats)
+
+	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
+	log.Fatal(app.Listen(addr))
+}
+
+// setupSQLite creates the FTS5 search index and indexes mail stored before
+// it existed. Connection pragmas are set in the DSN by storage.Open.
+func setupSQLite(ctx context.Context, database *sql.DB) error {
+	if err := search.Setup(ctx, database); err != nil {
+		return err
+	}
+	if !search.Enabled() && !fiber.IsChild() {
+		log.Println("Full-text search disabled: build with -tags sqlite_fts5 to enable it")
+	}
+	if fiber.IsChild() {
+		return nil
+	}
+	indexed, err := search.Backfill(ctx, database)
+	if err != nil {
+		return err
+	}
+	if indexed > 0 {
+		log.Printf("Indexed %d messages stored before search was enabled", indexed)
+	}
+	return nil
+}
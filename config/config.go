@@ -2,15 +2,39 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/BurntSushi/toml"
 )
 
 // Config defines the structure of config.toml
 type Config struct {
-	App      AppConfig      `toml:"app"`
-	Server   ServerConfig   `toml:"server"`
-	Domains  DomainsConfig  `toml:"domains"`
-	Database DatabaseConfig `toml:"database"`
+	App       AppConfig       `toml:"app"`
+	Server    ServerConfig    `toml:"server"`
+	Domains   DomainsConfig   `toml:"domains"`
+	Database  DatabaseConfig  `toml:"database"`
+	SMTP      SMTPConfig      `toml:"smtp"`
+	Webhooks  WebhooksConfig  `toml:"webhooks"`
+	Addresses AddressesConfig `toml:"addresses"`
+	Cleanup   CleanupConfig   `toml:"cleanup"`
+	DNS       DNSConfig       `toml:"dns"`
+
+	// Postfix overrides settings of the generated main.cf, keyed by their
+	// main.cf parameter names. It is decoded by DecodePostfix.
+	Postfix toml.Primitive `toml:"postfix"`
+
+	meta toml.MetaData
+
+	// managed holds the active domains added through the admin API.
+	mu      sync.RWMutex
+	managed []string
 }
 
 type AppConfig struct {
@@ -19,26 +43,237 @@ type AppConfig struct {
 }
 
 type ServerConfig struct {
-	Host    string `toml:"host"`
-	Port    int    `toml:"port"`
-	Secret  int    `toml:"secret"`
-	Prefork bool   `toml:"prefork"`
+	Host         string        `toml:"host"`
+	Port         int           `toml:"port"`
+	Secret       Secret        `toml:"secret"`
+	SecretFile   string        `toml:"secret_file"`
+	LegacySecret bool          `toml:"legacy_secret"`
+	ReplayWindow time.Duration `toml:"replay_window"`
+	Prefork      bool          `toml:"prefork"`
+	BodyLimit    int           `toml:"body_limit"`
+	// ForwardBinary is the temp-mail binary run by the generated Postfix
+	// forward script. The running binary is used when it is empty.
+	ForwardBinary string `toml:"forward_binary"`
+}
+
+// Secret is the key used to sign webhook requests. Older configs set it to
+// an integer, which is still read as its decimal string so that Check can
+// name the problem.
+type Secret string
+
+// PlaceholderSecret is the value shipped in the example config.
+const PlaceholderSecret = "change-me"
+
+// MinSecretLength is the shortest secret Check accepts, in bytes.
+const MinSecretLength = 32
+
+// ErrWeakSecret is returned by Secret.Check for a secret that is too easy to
+// guess.
+var ErrWeakSecret = errors.New(`server.secret is empty, the "change-me" placeholder, a number or shorter than 32 bytes: set a random value, e.g. from "openssl rand -hex 32"`)
+
+// Check returns ErrWeakSecret unless s is long enough and not a number.
+// Anyone who knows the secret can inject mail through /webhook.
+func (s Secret) Check() error {
+	v := strings.TrimSpace(string(s))
+	if v == PlaceholderSecret || len(v) < MinSecretLength {
+		return ErrWeakSecret
+	}
+	if strings.Trim(strings.TrimPrefix(v, "-"), "0123456789") == "" {
+		return ErrWeakSecret
+	}
+	return nil
+}
+
+// UnmarshalTOML implements toml.Unmarshaler.
+func (s *Secret) UnmarshalTOML(v any) error {
+	switch v := v.(type) {
+	case string:
+		*s = Secret(v)
+	case int64:
+		*s = Secret(strconv.FormatInt(v, 10))
+	default:
+		return fmt.Errorf("secret must be a string, got %T", v)
+	}
+	return nil
 }
 
 type DomainsConfig struct {
-	Aliases []string `toml:"aliases"`
+	Aliases          []string                 `toml:"aliases"`
+	DefaultRetention time.Duration            `toml:"default_retention"`
+	MaxRetention     time.Duration            `toml:"max_retention"`
+	Retention        map[string]time.Duration `toml:"retention"`
+	// RefreshInterval is how often domains added through the admin API are
+	// reloaded from the database, for prefork processes and other replicas.
+	RefreshInterval time.Duration `toml:"refresh_interval"`
+	// ApplyPostfix rewrites the Postfix configuration when a domain is
+	// added, enabled or removed through the admin API.
+	ApplyPostfix bool `toml:"apply_postfix"`
 }
 
 type DatabaseConfig struct {
-	Path string `toml:"path"`
+	Backend     string `toml:"backend"` // "sqlite" or "postgres"
+	Path        string `toml:"path"`
+	DSN         string `toml:"dsn"`
+	CompressRaw bool   `toml:"compress_raw"`
+	AutoMigrate bool   `toml:"auto_migrate"`
+	// BusyTimeout is how long SQLite waits for a lock held by another
+	// connection or prefork process before failing with "database is locked".
+	BusyTimeout time.Duration `toml:"busy_timeout"`
+}
+
+type SMTPConfig struct {
+	Enabled         bool   `toml:"enabled"`
+	Host            string `toml:"host"`
+	Port            int    `toml:"port"`
+	Hostname        string `toml:"hostname"`
+	MaxMessageBytes int64  `toml:"max_message_bytes"`
 }
 
 // LoadConfig loads the configuration from a TOML file path
 func LoadConfig(path string) (*Config, error) {
 	var conf Config
-	if _, err := toml.DecodeFile(path, &conf); err != nil {
+	meta, err := toml.DecodeFile(path, &conf)
+	if err != nil {
 		return nil, err
 	}
+	conf.meta = meta
+	if conf.Server.SecretFile != "" {
+		secret, err := os.ReadFile(conf.Server.SecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading secret file: %w", err)
+		}
+		conf.Server.Secret = Secret(strings.TrimSpace(string(secret)))
+	}
+	if conf.Database.Backend == "" {
+		conf.Database.Backend = "sqlite"
+	}
+	if conf.Database.BusyTimeout <= 0 {
+		conf.Database.BusyTimeout = 5 * time.Second
+	}
+	if conf.Server.ReplayWindow <= 0 {
+		conf.Server.ReplayWindow = 5 * time.Minute
+	}
+	if conf.Domains.DefaultRetention <= 0 {
+		conf.Domains.DefaultRetention = 3 * 24 * time.Hour
+	}
+	if conf.Domains.MaxRetention <= 0 {
+		conf.Domains.MaxRetention = 30 * 24 * time.Hour
+	}
+	if conf.Domains.RefreshInterval <= 0 {
+		conf.Domains.RefreshInterval = 30 * time.Second
+	}
+	if conf.Cleanup.Interval <= 0 {
+		conf.Cleanup.Interval = 5 * time.Minute
+	}
+	if conf.DNS.TTL <= 0 {
+		conf.DNS.TTL = 3600
+	}
+	if conf.DNS.DMARCPolicy == "" {
+		conf.DNS.DMARCPolicy = "none"
+	}
+	if conf.DNS.MTASTSMode == "" {
+		conf.DNS.MTASTSMode = "testing"
+	}
+	if conf.DNS.MTASTSMaxAge <= 0 {
+		conf.DNS.MTASTSMaxAge = 86400
+	}
+	if conf.Addresses.DefaultTTL <= 0 {
+		conf.Addresses.DefaultTTL = 24 * time.Hour
+	}
+	if conf.Addresses.MaxTTL < conf.Addresses.DefaultTTL {
+		conf.Addresses.MaxTTL = conf.Addresses.DefaultTTL
+	}
 
 	return &conf, nil
 }
+
+type WebhooksConfig struct {
+	MaxAttempts  int           `toml:"max_attempts"`
+	Timeout      time.Duration `toml:"timeout"`
+	AllowPrivate bool          `toml:"allow_private"` // Allow URLs on loopback and private networks
+}
+
+type AddressesConfig struct {
+	DefaultTTL time.Duration `toml:"default_ttl"`
+	MaxTTL     time.Duration `toml:"max_ttl"`
+}
+
+type CleanupConfig struct {
+	Interval  time.Duration `toml:"interval"`
+	BatchSize int           `toml:"batch_size"`
+}
+
+type DNSConfig struct {
+	IPv4         string `toml:"ipv4"` // Address of the mail host, for its A record
+	IPv6         string `toml:"ipv6"` // Address of the mail host, for its AAAA record
+	TTL          int    `toml:"ttl"`
+	DMARCPolicy  string `toml:"dmarc_policy"`
+	ReportEmail  string `toml:"report_email"` // DMARC and TLS-RPT reports; none are requested when empty
+	MTASTSMode   string `toml:"mta_sts_mode"`
+	MTASTSMaxAge int    `toml:"mta_sts_max_age"` // Seconds
+	Resolver     string `toml:"resolver"`        // host:port used by the check, system resolver when empty
+}
+
+// RetentionFor returns how long mail for domain is kept: the override in
+// [domains.retention], or default_retention.
+func (c *Config) RetentionFor(domain string) time.Duration {
+	for d, retention := range c.Domains.Retention {
+		if strings.EqualFold(d, domain) && retention > 0 {
+			return retention
+		}
+	}
+	return c.Domains.DefaultRetention
+}
+
+// DecodePostfix decodes the [postfix] section into v. Settings missing from
+// the file keep the value already in v; unknown settings are an error.
+func (c *Config) DecodePostfix(v any) error {
+	if !c.meta.IsDefined("postfix") {
+		return nil
+	}
+	if err := c.meta.PrimitiveDecode(c.Postfix, v); err != nil {
+		return fmt.Errorf("[postfix]: %w", err)
+	}
+	for _, key := range c.meta.Undecoded() {
+		if len(key) > 1 && key[0] == "postfix" {
+			return fmt.Errorf("[postfix]: unknown setting %q", key[1])
+		}
+	}
+	return nil
+}
+
+// HasDomain reports whether domain is one of the configured domain aliases
+// or an active domain added through the admin API.
+func (c *Config) HasDomain(domain string) bool {
+	return slices.ContainsFunc(c.ActiveDomains(), func(d string) bool {
+		return strings.EqualFold(d, domain)
+	})
+}
+
+// IsConfiguredDomain reports whether domain is listed in config.toml.
+func (c *Config) IsConfiguredDomain(domain string) bool {
+	return slices.ContainsFunc(c.Domains.Aliases, func(d string) bool {
+		return strings.EqualFold(d, domain)
+	})
+}
+
+// ActiveDomains returns the domains mail is accepted for: the configured
+// aliases followed by the active domains added through the admin API.
+func (c *Config) ActiveDomains() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	domains := slices.Clone(c.Domains.Aliases)
+	for _, d := range c.managed {
+		if !c.IsConfiguredDomain(d) {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// SetManagedDomains replaces the active domains added through the admin API.
+func (c *Config) SetManagedDomains(domains []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.managed = domains
+}
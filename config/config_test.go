@@ -0,0 +1,42 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSecretCheck(t *testing.T) {
+	for secret, want := range map[Secret]error{
+		"":                                    ErrWeakSecret,
+		"  ":                                  ErrWeakSecret,
+		PlaceholderSecret:                     ErrWeakSecret,
+		" change-me\n":                        ErrWeakSecret,
+		"31":                                  ErrWeakSecret,
+		"4f9c2e7a1b0d8e6f3a5c9b2d7":           ErrWeakSecret,
+		"12345678901234567890123456789012345": ErrWeakSecret,
+		"4f9c2e7a1b0d8e6f3a5c9b2d7e1f0a3c":    nil,
+		"4f9c2e7a1b0d8e6f3a5c9b2d7e1f0a3c6b8d2e4f7a9c1b3d5e7f9a2c4b6d8e0f": nil,
+	} {
+		if err := secret.Check(); !errors.Is(err, want) {
+			t.Errorf("Secret(%q).Check() = %v, want %v", secret, err, want)
+		}
+	}
+}
+
+func TestSecretInteger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	for _, secret := range []string{"31", "9223372036854775807"} {
+		if err := os.WriteFile(path, []byte("[server]\nsecret = "+secret+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.Server.Secret.Check(); !errors.Is(err, ErrWeakSecret) {
+			t.Errorf("integer secret %s: Check() = %v, want ErrWeakSecret", secret, err)
+		}
+	}
+}
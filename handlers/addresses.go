@@ -0,0 +1,109 @@
+// Package handlers contains the address generation handlers for the application.
+package handlers
+
+import (
+	"errors"
+	"log"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/addresses"
+	"github.com/pageton/temp-mail/internal/claims"
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/storage"
+)
+
+type CreateAddressRequest struct {
+	// Domain defaults to a random configured domain.
+	Domain string `json:"domain"`
+	// Strategy is "words" (default), "cuid", "pronounceable" or "prefix".
+	Strategy string `json:"strategy"`
+	Prefix   string `json:"prefix"`
+	// TTL is a Go duration such as "1h"; defaults to default_ttl.
+	TTL string `json:"ttl"`
+	// Claim also claims the address and returns its token.
+	Claim bool `json:"claim"`
+}
+
+type AddressResponse struct {
+	Success bool        `json:"success"`
+	Data    AddressData `json:"data"`
+}
+
+type AddressData struct {
+	Address   string    `json:"address"`
+	Domain    string    `json:"domain"`
+	Strategy  string    `json:"strategy"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Token     string    `json:"token,omitempty"`
+}
+
+func CreateAddress(c *fiber.Ctx) error {
+	var req CreateAddressRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": "Invalid request body"})
+		}
+	}
+	cfg := c.Locals("config").(*config.Config)
+
+	domain := strings.ToLower(strings.TrimSpace(req.Domain))
+	if active := cfg.ActiveDomains(); domain == "" && len(active) > 0 {
+		domain = active[rand.IntN(len(active))]
+	}
+	if !cfg.HasDomain(domain) {
+		return c.Status(fiber.StatusBadRequest).
+			JSON(&fiber.Map{"error": "Domain is not one of the configured domains"})
+	}
+	if req.Strategy == "" {
+		req.Strategy = addresses.StrategyWords
+	}
+	ttl := cfg.Addresses.DefaultTTL
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 || d > cfg.Addresses.MaxTTL {
+			return c.Status(fiber.StatusBadRequest).
+				JSON(&fiber.Map{"error": "ttl must be a duration up to " + cfg.Addresses.MaxTTL.String()})
+		}
+		ttl = d
+	}
+
+	// The address is checked, recorded and claimed in one transaction, so
+	// mail or a concurrent claim cannot slip in between.
+	queries := c.Locals("queries").(storage.Store)
+	var address db.Address
+	var token string
+	err := queries.InTx(c.Context(), func(tx storage.Store) error {
+		var err error
+		address, err = addresses.Create(c.Context(), tx, domain, req.Strategy, req.Prefix, ttl)
+		if err != nil || !req.Claim {
+			return err
+		}
+		token, err = claims.Claim(c.Context(), tx, address.Address)
+		return err
+	})
+	if errors.Is(err, addresses.ErrUnknownStrategy) || errors.Is(err, addresses.ErrInvalidPrefix) {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		log.Println("Error creating address:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error creating address"})
+	}
+
+	data := AddressData{
+		Address:   address.Address,
+		Domain:    domain,
+		Strategy:  address.Strategy,
+		CreatedAt: time.UnixMilli(address.Createdat),
+		ExpiresAt: time.UnixMilli(address.Expiresat),
+		Token:     token,
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(&AddressResponse{Success: true, Data: data})
+}
@@ -0,0 +1,309 @@
+// Package handlers contains the admin handlers for the application.
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/cleanup"
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/domains"
+	"github.com/pageton/temp-mail/internal/storage"
+)
+
+// startedAt is reported as the process start in the system stats.
+var startedAt = time.Now()
+
+type CleanupResponse struct {
+	Success bool         `json:"success"`
+	Data    *CleanupData `json:"data"`
+}
+
+type CleanupData struct {
+	Emails    int64     `json:"emails"`
+	Inboxes   int64     `json:"inboxes"`
+	Addresses int64     `json:"addresses"`
+	StartedAt time.Time `json:"startedAt"`
+	Duration  string    `json:"duration"`
+}
+
+// RunCleanup removes expired mail right away and reports what was removed.
+func RunCleanup(c *fiber.Ctx) error {
+	cleaner := c.Locals("cleaner").(*cleanup.Cleaner)
+	result, err := cleaner.Run(c.Context())
+	if err != nil {
+		log.Println("Error cleaning up expired mail:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error cleaning up expired mail"})
+	}
+	return c.Status(fiber.StatusOK).JSON(&CleanupResponse{Success: true, Data: cleanupData(&result)})
+}
+
+// GetCleanup reports the most recent cleanup run; data is null before the
+// first run.
+func GetCleanup(c *fiber.Ctx) error {
+	cleaner := c.Locals("cleaner").(*cleanup.Cleaner)
+	last, err := cleaner.Last(c.Context())
+	if err != nil {
+		log.Println("Error getting the last cleanup:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error getting the last cleanup"})
+	}
+	return c.Status(fiber.StatusOK).JSON(&CleanupResponse{Success: true, Data: cleanupData(last)})
+}
+
+func cleanupData(result *cleanup.Result) *CleanupData {
+	if result == nil {
+		return nil
+	}
+	return &CleanupData{
+		Emails:    result.Emails,
+		Inboxes:   result.Inboxes,
+		Addresses: result.Addresses,
+		StartedAt: result.StartedAt,
+		Duration:  result.Duration.String(),
+	}
+}
+
+type AddressSummaryResponse struct {
+	Success bool                 `json:"success"`
+	Data    []AddressSummaryData `json:"data"`
+}
+
+type AddressSummaryData struct {
+	Address        string    `json:"address"`
+	Messages       int64     `json:"messages"`
+	LastReceivedAt time.Time `json:"lastReceivedAt"`
+}
+
+// ListAddresses returns every address that has mail, with its message
+// count, most recently active first. domain, limit and offset narrow the
+// list.
+func ListAddresses(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", defaultPageSize)
+	if limit < 1 || limit > maxPageSize {
+		return c.Status(fiber.StatusBadRequest).
+			JSON(&fiber.Map{"error": fmt.Sprintf("limit must be between 1 and %d", maxPageSize)})
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": "offset must not be negative"})
+	}
+	var domain string
+	if c.Query("domain") != "" {
+		var err error
+		if domain, err = domains.Normalize(c.Query("domain")); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": err.Error()})
+		}
+	}
+
+	queries := c.Locals("queries").(storage.Store)
+	rows, err := queries.GetAddressSummaries(c.Context(), db.GetAddressSummariesParams{
+		Domain: sql.NullString{String: domain, Valid: domain != ""},
+		Limit:  int64(limit),
+		Offset: int64(offset),
+	})
+	if err != nil {
+		log.Println("Error getting addresses:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error getting addresses"})
+	}
+	data := make([]AddressSummaryData, len(rows))
+	for i, row := range rows {
+		data[i] = AddressSummaryData{
+			Address:        row.Address.String,
+			Messages:       row.Messages,
+			LastReceivedAt: time.UnixMilli(row.Lastreceivedat),
+		}
+	}
+	return c.Status(fiber.StatusOK).JSON(&AddressSummaryResponse{Success: true, Data: data})
+}
+
+type PurgeRequest struct {
+	// At least one of Address, Domain or Sender is required; when several
+	// are set, mail must match all of them.
+	Address string `json:"address"`
+	Domain  string `json:"domain"`
+	Sender  string `json:"sender"`
+}
+
+type PurgeResponse struct {
+	Success bool      `json:"success"`
+	Data    PurgeData `json:"data"`
+}
+
+type PurgeData struct {
+	Emails   int64  `json:"emails"`
+	Inboxes  int64  `json:"inboxes"`
+	Duration string `json:"duration"`
+}
+
+// Purge removes the mail received by an address or domain, or sent by a
+// sender, regardless of its retention.
+func Purge(c *fiber.Ctx) error {
+	var req PurgeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": "Invalid request body"})
+	}
+	filter := cleanup.Filter{
+		Address: strings.ToLower(strings.TrimSpace(req.Address)),
+		Sender:  strings.ToLower(strings.TrimSpace(req.Sender)),
+	}
+	if filter.Address == "" && req.Domain == "" && filter.Sender == "" {
+		return c.Status(fiber.StatusBadRequest).
+			JSON(&fiber.Map{"error": "One of address, domain or sender is required"})
+	}
+	for _, address := range []string{filter.Address, filter.Sender} {
+		if address != "" && !strings.Contains(address, "@") {
+			return c.Status(fiber.StatusBadRequest).
+				JSON(&fiber.Map{"error": "address and sender must be email addresses"})
+		}
+	}
+	if req.Domain != "" {
+		domain, err := domains.Normalize(req.Domain)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": err.Error()})
+		}
+		filter.Domain = domain
+	}
+
+	cleaner := c.Locals("cleaner").(*cleanup.Cleaner)
+	result, err := cleaner.Purge(c.Context(), filter)
+	if err != nil {
+		log.Println("Error purging mail:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error purging mail"})
+	}
+	log.Printf("Admin key %q purged %d inboxes and %d emails (address=%q domain=%q sender=%q)",
+		c.Locals("adminKey"), result.Inboxes, result.Emails, filter.Address, filter.Domain, filter.Sender)
+
+	return c.Status(fiber.StatusOK).JSON(&PurgeResponse{
+		Success: true,
+		Data: PurgeData{
+			Emails:   result.Emails,
+			Inboxes:  result.Inboxes,
+			Duration: result.Duration.String(),
+		},
+	})
+}
+
+type StatsResponse struct {
+	Success bool      `json:"success"`
+	Data    StatsData `json:"data"`
+}
+
+type StatsData struct {
+	Version string       `json:"version"`
+	Backend string       `json:"backend"`
+	Search  bool         `json:"search"`
+	Domains int          `json:"domains"`
+	Mail    MailStats    `json:"mail"`
+	Storage StorageStats `json:"storage"`
+	Webhook WebhookStats `json:"webhooks"`
+	Process ProcessStats `json:"process"`
+	Cleanup *CleanupData `json:"cleanup"`
+}
+
+type MailStats struct {
+	Emails             int64      `json:"emails"`
+	Inboxes            int64      `json:"inboxes"`
+	Addresses          int64      `json:"addresses"`
+	GeneratedAddresses int64      `json:"generatedAddresses"`
+	ClaimedAddresses   int64      `json:"claimedAddresses"`
+	Attachments        int64      `json:"attachments"`
+	OldestEmailAt      *time.Time `json:"oldestEmailAt,omitempty"`
+	NewestEmailAt      *time.Time `json:"newestEmailAt,omitempty"`
+}
+
+type StorageStats struct {
+	AttachmentBytes int64 `json:"attachmentBytes"`
+	RawBytes        int64 `json:"rawBytes"` // Uncompressed
+}
+
+type WebhookStats struct {
+	Webhooks          int64 `json:"webhooks"`
+	PendingDeliveries int64 `json:"pendingDeliveries"`
+	FailedDeliveries  int64 `json:"failedDeliveries"`
+}
+
+type ProcessStats struct {
+	StartedAt  time.Time `json:"startedAt"`
+	Uptime     string    `json:"uptime"`
+	Goroutines int       `json:"goroutines"`
+	HeapBytes  uint64    `json:"heapBytes"`
+}
+
+// GetStats reports what is stored and how this process is doing. Process
+// figures are per process when preforking.
+func GetStats(c *fiber.Ctx) error {
+	cfg := c.Locals("config").(*config.Config)
+	queries := c.Locals("queries").(storage.Store)
+	cleaner := c.Locals("cleaner").(*cleanup.Cleaner)
+
+	row, err := queries.GetStats(c.Context())
+	if err != nil {
+		log.Println("Error getting stats:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error getting stats"})
+	}
+	last, err := cleaner.Last(c.Context())
+	if err != nil {
+		log.Println("Error getting the last cleanup:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error getting stats"})
+	}
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	return c.Status(fiber.StatusOK).JSON(&StatsResponse{
+		Success: true,
+		Data: StatsData{
+			Version: cfg.App.Version,
+			Backend: cfg.Database.Backend,
+			Search:  queries.SearchEnabled(),
+			Domains: len(cfg.ActiveDomains()),
+			Mail: MailStats{
+				Emails:             row.Emails,
+				Inboxes:            row.Inboxes,
+				Addresses:          row.Addresses,
+				GeneratedAddresses: row.Generatedaddresses,
+				ClaimedAddresses:   row.Claimedaddresses,
+				Attachments:        row.Attachments,
+				OldestEmailAt:      optionalTime(row.Oldestemailat),
+				NewestEmailAt:      optionalTime(row.Newestemailat),
+			},
+			Storage: StorageStats{
+				AttachmentBytes: row.Attachmentbytes,
+				RawBytes:        row.Rawbytes,
+			},
+			Webhook: WebhookStats{
+				Webhooks:          row.Webhooks,
+				PendingDeliveries: row.Pendingdeliveries,
+				FailedDeliveries:  row.Faileddeliveries,
+			},
+			Process: ProcessStats{
+				StartedAt:  startedAt,
+				Uptime:     time.Since(startedAt).Round(time.Second).String(),
+				Goroutines: runtime.NumGoroutine(),
+				HeapBytes:  mem.HeapAlloc,
+			},
+			Cleanup: cleanupData(last),
+		},
+	})
+}
+
+// optionalTime converts Unix milliseconds, where 0 means none.
+func optionalTime(ms int64) *time.Time {
+	if ms == 0 {
+		return nil
+	}
+	t := time.UnixMilli(ms)
+	return &t
+}
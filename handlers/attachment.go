@@ -0,0 +1,97 @@
+// Package handlers contains the attachment handlers for the application.
+package handlers
+
+import (
+	"log"
+	"mime"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/storage"
+)
+
+type AttachmentResponse struct {
+	ID          string  `json:"id"`
+	Filename    *string `json:"filename"`
+	ContentType *string `json:"contentType"`
+	Size        int64   `json:"size"`
+	ContentID   *string `json:"contentId,omitempty"`
+	Disposition *string `json:"disposition"`
+}
+
+func GetAttachment(c *fiber.Ctx) error {
+	inboxID := c.Params("inboxid")
+	attachmentID := c.Params("attachmentId")
+	if inboxID == "" || attachmentID == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("Missing inbox or attachment ID")
+	}
+	queries := c.Locals("queries").(storage.Store)
+	attachment, err := queries.GetAttachmentForInbox(c.Context(), db.GetAttachmentForInboxParams{
+		InboxID:      inboxID,
+		AttachmentID: attachmentID,
+	})
+	if err != nil {
+		log.Println("Error getting attachment:", err)
+		return c.Status(fiber.StatusNotFound).
+			JSON(&fiber.Map{"error": "Attachment does not exist or has been deleted"})
+	}
+
+	filename := attachment.Filename.String
+	if filename == "" {
+		filename = attachment.ID
+	}
+	contentType := attachment.Contenttype.String
+	if contentType == "" {
+		contentType = fiber.MIMEOctetStream
+	}
+	// Attachments come from whoever sent the mail and are served from the
+	// API's origin, so only types browsers cannot run script in are shown
+	// inline; everything else is a download in a sandbox.
+	if attachment.Disposition.String == "inline" && c.Query("download") == "" && inlineSafe(contentType) {
+		c.Set(fiber.HeaderContentDisposition, "inline")
+	} else {
+		c.Attachment(filename)
+		c.Set(fiber.HeaderContentSecurityPolicy, "sandbox; default-src 'none'")
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	return c.Status(fiber.StatusOK).Send(attachment.Content)
+}
+
+// inlineTypes are the content types that may be rendered inline. SVG is left
+// out on purpose: it can carry script.
+var inlineTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+func inlineSafe(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && inlineTypes[mediaType]
+}
+
+func attachmentsForInbox(c *fiber.Ctx, queries storage.Store, inboxID string) ([]AttachmentResponse, error) {
+	rows, err := queries.GetAttachmentsByInboxID(c.Context(), inboxID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]AttachmentResponse, 0, len(rows))
+	for _, a := range rows {
+		ar := AttachmentResponse{
+			ID:          a.ID,
+			Filename:    nullString(a.Filename),
+			ContentType: nullString(a.Contenttype),
+			Size:        a.Size,
+			ContentID:   nullString(a.Contentid),
+			Disposition: nullString(a.Disposition),
+		}
+		result = append(result, ar)
+	}
+	return result, nil
+}
@@ -0,0 +1,285 @@
+// Package handlers contains the outbound webhook handlers for the application.
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lucsky/cuid"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/claims"
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/storage"
+	"github.com/pageton/temp-mail/internal/utils"
+	"github.com/pageton/temp-mail/internal/webhooks"
+	"github.com/pageton/temp-mail/middlewares"
+)
+
+type CreateWebhookRequest struct {
+	URL string `json:"url"`
+	// Exactly one of Address, Pattern or Domain selects the mail to forward.
+	Address     string `json:"address"`
+	Pattern     string `json:"pattern"`
+	Domain      string `json:"domain"`
+	IncludeBody bool   `json:"includeBody"`
+	// Secret signs deliveries; one is generated when empty.
+	Secret string `json:"secret"`
+}
+
+type OutboundWebhookResponse struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Pattern     string    `json:"pattern"`
+	IncludeBody bool      `json:"includeBody"`
+	CreatedAt   time.Time `json:"createdAt"`
+	// Secret and Token are only returned when the webhook is created. Token
+	// is the bearer token for the other webhook routes.
+	Secret string `json:"secret,omitempty"`
+	Token  string `json:"token,omitempty"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID             int64      `json:"id"`
+	InboxID        string     `json:"inboxId"`
+	Status         string     `json:"status"`
+	Attempts       int64      `json:"attempts"`
+	ResponseStatus *int64     `json:"responseStatus,omitempty"`
+	LastError      *string    `json:"lastError,omitempty"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+func CreateWebhook(c *fiber.Ctx) error {
+	var req CreateWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": "Invalid request body"})
+	}
+	cfg := c.Locals("config").(*config.Config)
+	pattern, err := webhookPattern(cfg, req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": err.Error()})
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": "url must be an http or https URL"})
+	}
+	if !cfg.Webhooks.AllowPrivate {
+		if err := webhooks.CheckHost(c.Context(), u.Hostname()); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": err.Error()})
+		}
+	}
+	queries := c.Locals("queries").(storage.Store)
+	if address, ok := webhooks.Literal(pattern); ok {
+		// Mail of a claimed address is only forwarded to its owner's webhooks,
+		// whether the address was given as address or as pattern.
+		pattern = address
+		err = claims.Check(c.Context(), queries, address, middlewares.BearerToken(c))
+		if errors.Is(err, claims.ErrMissingToken) {
+			return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{"error": err.Error()})
+		}
+		if errors.Is(err, claims.ErrInvalidToken) {
+			return c.Status(fiber.StatusForbidden).JSON(&fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			log.Println("Error checking address claim:", err)
+			return c.Status(fiber.StatusInternalServerError).
+				JSON(&fiber.Map{"error": "Error creating webhook"})
+		}
+	}
+	secret := req.Secret
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			log.Println("Error generating webhook secret:", err)
+			return c.Status(fiber.StatusInternalServerError).
+				JSON(&fiber.Map{"error": "Error creating webhook"})
+		}
+		secret = hex.EncodeToString(buf)
+	}
+	token, tokenHash, err := webhooks.NewToken()
+	if err != nil {
+		log.Println("Error generating webhook token:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error creating webhook"})
+	}
+
+	id := cuid.New()
+	err = queries.InsertWebhook(c.Context(), db.InsertWebhookParams{
+		ID:          id,
+		Url:         u.String(),
+		Secret:      secret,
+		Pattern:     pattern,
+		Includebody: req.IncludeBody,
+		Tokenhash:   sql.NullString{String: tokenHash, Valid: true},
+	})
+	if err != nil {
+		log.Println("Error inserting webhook:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error creating webhook"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(&OutboundWebhookResponse{
+		ID:          id,
+		URL:         u.String(),
+		Pattern:     pattern,
+		IncludeBody: req.IncludeBody,
+		CreatedAt:   time.Now(),
+		Secret:      secret,
+		Token:       token,
+	})
+}
+
+func GetWebhook(c *fiber.Ctx) error {
+	hook, ferr := webhookParam(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(&fiber.Map{"error": ferr.Message})
+	}
+	return c.Status(fiber.StatusOK).JSON(&OutboundWebhookResponse{
+		ID:          hook.ID,
+		URL:         hook.Url,
+		Pattern:     hook.Pattern,
+		IncludeBody: hook.Includebody,
+		CreatedAt:   time.UnixMilli(hook.Createdat.Int64),
+	})
+}
+
+func DeleteWebhook(c *fiber.Ctx) error {
+	hook, ferr := webhookParam(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(&fiber.Map{"error": ferr.Message})
+	}
+	queries := c.Locals("queries").(storage.Store)
+	n, err := queries.DeleteWebhook(c.Context(), hook.ID)
+	if err != nil {
+		log.Println("Error deleting webhook:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error deleting webhook"})
+	}
+	if n == 0 {
+		return c.Status(fiber.StatusNotFound).
+			JSON(&fiber.Map{"error": "Webhook does not exist or has been deleted"})
+	}
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{"success": true})
+}
+
+func GetWebhookDeliveries(c *fiber.Ctx) error {
+	hook, ferr := webhookParam(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(&fiber.Map{"error": ferr.Message})
+	}
+	queries := c.Locals("queries").(storage.Store)
+	limit := c.QueryInt("limit", defaultPageSize)
+	if limit < 1 || limit > maxPageSize {
+		limit = defaultPageSize
+	}
+	rows, err := queries.GetWebhookDeliveries(c.Context(), db.GetWebhookDeliveriesParams{
+		Webhookid: hook.ID,
+		Limit:     int64(limit),
+	})
+	if err != nil {
+		log.Println("Error getting webhook deliveries:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error getting webhook deliveries"})
+	}
+
+	result := make([]WebhookDeliveryResponse, 0, len(rows))
+	for _, r := range rows {
+		d := WebhookDeliveryResponse{
+			ID:             r.ID,
+			InboxID:        r.Inboxid,
+			Status:         r.Status,
+			Attempts:       r.Attempts,
+			ResponseStatus: nullInt64(r.Responsestatus),
+			LastError:      nullString(r.Lasterror),
+			DeliveredAt:    nullMillis(r.Deliveredat),
+			CreatedAt:      time.UnixMilli(r.Createdat.Int64),
+		}
+		if r.Status == "pending" {
+			next := time.UnixMilli(r.Nextattemptat)
+			d.NextAttemptAt = &next
+		}
+		result = append(result, d)
+	}
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{"success": true, "data": result})
+}
+
+// webhookParam returns the :webhookId webhook, or an error unless the request
+// carries the bearer token returned when it was created.
+func webhookParam(c *fiber.Ctx) (db.Webhook, *fiber.Error) {
+	queries := c.Locals("queries").(storage.Store)
+	hook, err := queries.GetWebhook(c.Context(), c.Params("webhookId"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return hook, fiber.NewError(fiber.StatusNotFound, "Webhook does not exist or has been deleted")
+	}
+	if err != nil {
+		log.Println("Error getting webhook:", err)
+		return hook, fiber.NewError(fiber.StatusInternalServerError, "Error getting webhook")
+	}
+	err = webhooks.Authorize(hook, middlewares.BearerToken(c))
+	if errors.Is(err, webhooks.ErrMissingToken) {
+		return hook, fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+	if err != nil {
+		return hook, fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+	return hook, nil
+}
+
+// webhookPattern returns the pattern stored for a webhook request. Patterns
+// must stay within the configured domains.
+func webhookPattern(cfg *config.Config, req CreateWebhookRequest) (string, error) {
+	var pattern string
+	set := 0
+	for _, v := range []string{req.Address, req.Pattern, req.Domain} {
+		if v != "" {
+			pattern = strings.ToLower(strings.TrimSpace(v))
+			set++
+		}
+	}
+	if set != 1 {
+		return "", errors.New("exactly one of address, pattern or domain is required")
+	}
+	if req.Domain != "" && strings.Contains(pattern, "@") {
+		return "", errors.New("domain must not contain @")
+	}
+	if req.Domain == "" && !strings.Contains(pattern, "@") {
+		return "", errors.New("address and pattern must contain @")
+	}
+	if !webhooks.ValidPattern(pattern) {
+		return "", errors.New("invalid pattern")
+	}
+	domain := pattern
+	if strings.Contains(pattern, "@") {
+		domain = utils.EmailDomain(pattern)
+	}
+	if !cfg.HasDomain(domain) {
+		return "", errors.New("pattern must use one of the configured domains")
+	}
+	return pattern, nil
+}
+
+// nullInt64 returns a pointer to the integer value, or nil if it is NULL.
+func nullInt64(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int64
+}
+
+// nullMillis converts a nullable Unix-milliseconds column to a time.
+func nullMillis(n sql.NullInt64) *time.Time {
+	if !n.Valid {
+		return nil
+	}
+	t := time.UnixMilli(n.Int64)
+	return &t
+}
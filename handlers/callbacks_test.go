@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/claims"
+	"github.com/pageton/temp-mail/internal/storage/storagetest"
+)
+
+func TestCreateWebhookChecksClaim(t *testing.T) {
+	queries, _ := storagetest.SQLite(t)
+	token, err := claims.Claim(context.Background(), queries, "victim@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Domains:  config.DomainsConfig{Aliases: []string{"example.com"}},
+		Webhooks: config.WebhooksConfig{AllowPrivate: true},
+	}
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("config", cfg)
+		c.Locals("queries", queries)
+		return c.Next()
+	})
+	app.Post("/webhooks", CreateWebhook)
+
+	tests := []struct {
+		name  string
+		body  string
+		token string
+		want  int
+	}{
+		{"address without token", `{"address":"victim@example.com"}`, "", fiber.StatusUnauthorized},
+		{"pattern without token", `{"pattern":"Victim@example.com"}`, "", fiber.StatusUnauthorized},
+		{"escaped pattern without token", `{"pattern":"vict\\im@example.com"}`, "", fiber.StatusUnauthorized},
+		{"pattern with wrong token", `{"pattern":"victim@example.com"}`, "wrong", fiber.StatusForbidden},
+		{"pattern with token", `{"pattern":"victim@example.com"}`, token, fiber.StatusCreated},
+		{"wildcard without token", `{"pattern":"*@example.com"}`, "", fiber.StatusCreated},
+	}
+	for _, tt := range tests {
+		body := strings.Replace(tt.body, "{", `{"url":"https://hooks.example.net/in",`, 1)
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, res.Body)
+		if res.StatusCode != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, res.StatusCode, tt.want)
+		}
+	}
+}
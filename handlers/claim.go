@@ -0,0 +1,59 @@
+// Package handlers contains the address claim handlers for the application.
+package handlers
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pageton/temp-mail/internal/claims"
+	"github.com/pageton/temp-mail/internal/storage"
+)
+
+type ClaimResponse struct {
+	Success bool      `json:"success"`
+	Data    ClaimData `json:"data"`
+}
+
+type ClaimData struct {
+	Address   string    `json:"address"`
+	Token     string    `json:"token"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ClaimAddress makes the caller the owner of an address that has not received
+// mail yet. The returned token is only shown once and is required for later
+// reads, streams and deletes.
+func ClaimAddress(c *fiber.Ctx) error {
+	email, ferr := emailParam(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(&fiber.Map{"error": ferr.Message})
+	}
+
+	queries := c.Locals("queries").(storage.Store)
+	var token string
+	err := queries.InTx(c.Context(), func(tx storage.Store) error {
+		var err error
+		token, err = claims.Claim(c.Context(), tx, email)
+		return err
+	})
+	if errors.Is(err, claims.ErrAlreadyClaimed) || errors.Is(err, claims.ErrHasMail) {
+		return c.Status(fiber.StatusConflict).JSON(&fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		log.Println("Error claiming address:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error claiming address"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(&ClaimResponse{
+		Success: true,
+		Data: ClaimData{
+			Address:   email,
+			Token:     token,
+			CreatedAt: time.Now(),
+		},
+	})
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package handlers
+
+import "net"
+
+// clientGone cannot peek at sockets on this platform, so disconnects are
+// only noticed when a write fails.
+func clientGone(net.Conn) bool {
+	return false
+}
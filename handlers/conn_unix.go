@@ -0,0 +1,35 @@
+//go:build unix
+
+package handlers
+
+import (
+	"net"
+	"syscall"
+)
+
+// clientGone reports whether the peer closed conn. It peeks at the socket
+// without consuming anything, so pipelined requests stay for the server.
+func clientGone(conn net.Conn) bool {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return false
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return false
+	}
+	gone := false
+	raw.Read(func(fd uintptr) bool {
+		var buf [1]byte
+		n, _, err := syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		switch err {
+		case nil:
+			gone = n == 0
+		case syscall.EAGAIN, syscall.EINTR:
+		default:
+			gone = true
+		}
+		return true
+	})
+	return gone
+}
@@ -0,0 +1,53 @@
+//go:build unix
+
+package handlers
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClientGone(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	if clientGone(server) {
+		t.Fatal("clientGone = true for an open connection")
+	}
+
+	// Pending request bytes are peeked, not consumed.
+	if _, err := client.Write([]byte("GET")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if clientGone(server) {
+		t.Fatal("clientGone = true with unread data")
+	}
+	buf := make([]byte, 3)
+	if _, err := server.Read(buf); err != nil || string(buf) != "GET" {
+		t.Fatalf("Read = %q, %v; want the pending bytes", buf, err)
+	}
+
+	client.Close()
+	deadline := time.Now().Add(time.Second)
+	for !clientGone(server) {
+		if time.Now().After(deadline) {
+			t.Fatal("clientGone = false after the client closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,49 @@
+// Package handlers contains the pagination helpers for the application.
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// emailCursor marks a position in an address listing, which is ordered by
+// creation time and then email ID, newest first.
+type emailCursor struct {
+	CreatedAt int64
+	EmailID   int64
+}
+
+func (c emailCursor) String() string {
+	raw := fmt.Sprintf("%d:%d", c.CreatedAt, c.EmailID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseCursor(s string) (emailCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return emailCursor{}, errInvalidCursor
+	}
+	var c emailCursor
+	if _, err := fmt.Sscanf(string(raw), "%d:%d", &c.CreatedAt, &c.EmailID); err != nil {
+		return emailCursor{}, errInvalidCursor
+	}
+	return c, nil
+}
+
+// parseTime accepts RFC 3339 timestamps and Unix milliseconds and returns
+// Unix milliseconds, matching the createdAt columns.
+func parseTime(s string) (int64, error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return ms, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, err
+	}
+	return t.UnixMilli(), nil
+}
@@ -6,7 +6,8 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 
-	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/pubsub"
+	"github.com/pageton/temp-mail/internal/storage"
 )
 
 func DeleteInbox(c *fiber.Ctx) error {
@@ -14,7 +15,7 @@ func DeleteInbox(c *fiber.Ctx) error {
 	if inboxID == "" {
 		return c.Status(fiber.StatusBadRequest).SendString("Missing inbox ID")
 	}
-	queries := c.Locals("queries").(*db.Queries)
+	queries := c.Locals("queries").(storage.Store)
 	inbox, err := queries.GetInboxByID(c.Context(), inboxID)
 	if err != nil || inbox.ID == "" {
 		log.Println("Error getting inbox:", err)
@@ -29,5 +30,12 @@ func DeleteInbox(c *fiber.Ctx) error {
 			JSON(&fiber.Map{"error": "Error deleting inbox"})
 	}
 
+	hub := c.Locals("hub").(*pubsub.Hub)
+	hub.Publish(pubsub.Event{
+		Type:    pubsub.EventDeleted,
+		Address: inbox.Address.String,
+		InboxID: inbox.ID,
+	})
+
 	return c.Status(fiber.StatusOK).JSON(&fiber.Map{"success": true})
 }
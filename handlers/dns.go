@@ -0,0 +1,59 @@
+// Package handlers contains the DNS handlers for the application.
+package handlers
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/dns"
+)
+
+type DNSResponse struct {
+	Success bool `json:"success"`
+	Data    any  `json:"data"`
+}
+
+// GetDNSRecords returns the recommended DNS records for every configured
+// domain. format=zone returns a zone file snippet and format=cloudflare
+// Cloudflare API request bodies grouped by zone; check=true looks the
+// records up and reports their status instead.
+func GetDNSRecords(c *fiber.Ctx) error {
+	cfg := c.Locals("config").(*config.Config)
+	records, err := dns.Records(cfg)
+	if err != nil {
+		log.Println("Error building DNS records:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error building DNS records"})
+	}
+
+	if c.QueryBool("check") {
+		results := dns.Check(c.Context(), dns.NewResolver(cfg.DNS.Resolver), records)
+		return c.Status(fiber.StatusOK).JSON(&DNSResponse{Success: true, Data: results})
+	}
+
+	switch c.Query("format", "json") {
+	case "json":
+		return c.Status(fiber.StatusOK).JSON(&DNSResponse{Success: true, Data: records})
+	case "zone":
+		return c.Status(fiber.StatusOK).SendString(dns.Zone(records))
+	case "cloudflare":
+		return c.Status(fiber.StatusOK).JSON(&DNSResponse{Success: true, Data: dns.Cloudflare(records)})
+	default:
+		return c.Status(fiber.StatusBadRequest).
+			JSON(&fiber.Map{"error": "format must be json, zone or cloudflare"})
+	}
+}
+
+// GetMTASTSPolicy serves the MTA-STS policy for requests to
+// https://mta-sts.<domain>/.well-known/mta-sts.txt.
+func GetMTASTSPolicy(c *fiber.Ctx) error {
+	cfg := c.Locals("config").(*config.Config)
+	hostname, err := dns.Hostname(cfg)
+	if err != nil {
+		log.Println("Error building MTA-STS policy:", err)
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	return c.Status(fiber.StatusOK).SendString(dns.MTASTSPolicy(cfg, hostname))
+}
@@ -1,10 +1,21 @@
-// Package handlers contains the GetDomains handlers for the application.
+// Package handlers contains the domain handlers for the application.
 package handlers
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/domains"
+	"github.com/pageton/temp-mail/internal/pubsub"
+	"github.com/pageton/temp-mail/internal/storage"
 )
 
 type Response struct {
@@ -12,13 +23,274 @@ type Response struct {
 	Result  []string `json:"result"`
 }
 
+type CreateDomainRequest struct {
+	Name string `json:"name"`
+	// Status defaults to pending.
+	Status   string         `json:"status"`
+	Metadata map[string]any `json:"metadata"`
+}
+
+type UpdateDomainRequest struct {
+	Status *string `json:"status"`
+	// Metadata replaces the stored metadata when present.
+	Metadata map[string]any `json:"metadata"`
+}
+
+type DomainResponse struct {
+	Success bool       `json:"success"`
+	Data    DomainData `json:"data"`
+}
+
+type DomainListResponse struct {
+	Success bool         `json:"success"`
+	Data    []DomainData `json:"data"`
+}
+
+type DomainData struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Source is "config" for the aliases in config.toml, which cannot be
+	// changed through the API, and "api" otherwise.
+	Source    string         `json:"source"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+	CreatedAt *time.Time     `json:"createdAt,omitempty"`
+	UpdatedAt *time.Time     `json:"updatedAt,omitempty"`
+}
+
 func GetDomains(c *fiber.Ctx) error {
 	cfg := c.Locals("config").(*config.Config)
 
 	res := Response{
 		Success: true,
-		Result:  cfg.Domains.Aliases,
+		Result:  cfg.ActiveDomains(),
 	}
 
 	return c.Status(fiber.StatusOK).JSON(&res)
 }
+
+// ListDomains returns the configured domains followed by the domains added
+// through the API, whatever their status.
+func ListDomains(c *fiber.Ctx) error {
+	cfg := c.Locals("config").(*config.Config)
+	queries := c.Locals("queries").(storage.Store)
+	rows, err := queries.GetDomainList(c.Context())
+	if err != nil {
+		log.Println("Error getting domains:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error getting domains"})
+	}
+	data := make([]DomainData, 0, len(cfg.Domains.Aliases)+len(rows))
+	for _, name := range cfg.Domains.Aliases {
+		data = append(data, DomainData{Name: name, Status: domains.StatusActive, Source: "config"})
+	}
+	for _, row := range rows {
+		if !cfg.IsConfiguredDomain(row.Name) {
+			data = append(data, domainData(row))
+		}
+	}
+	return c.Status(fiber.StatusOK).JSON(&DomainListResponse{Success: true, Data: data})
+}
+
+func GetDomain(c *fiber.Ctx) error {
+	cfg := c.Locals("config").(*config.Config)
+	name, err := domains.Normalize(c.Params("domain"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": err.Error()})
+	}
+	if cfg.IsConfiguredDomain(name) {
+		return c.Status(fiber.StatusOK).JSON(&DomainResponse{
+			Success: true,
+			Data:    DomainData{Name: name, Status: domains.StatusActive, Source: "config"},
+		})
+	}
+	queries := c.Locals("queries").(storage.Store)
+	row, err := queries.GetDomain(c.Context(), name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(&fiber.Map{"error": "Domain does not exist"})
+	}
+	if err != nil {
+		log.Println("Error getting domain:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error getting domain"})
+	}
+	return c.Status(fiber.StatusOK).JSON(&DomainResponse{Success: true, Data: domainData(row)})
+}
+
+// CreateDomain adds a domain. Mail is accepted for it once its status is
+// active.
+func CreateDomain(c *fiber.Ctx) error {
+	var req CreateDomainRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": "Invalid request body"})
+	}
+	cfg := c.Locals("config").(*config.Config)
+	name, err := domains.Normalize(req.Name)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": err.Error()})
+	}
+	if req.Status == "" {
+		req.Status = domains.StatusPending
+	}
+	if err = domains.CheckStatus(req.Status); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": err.Error()})
+	}
+	if cfg.IsConfiguredDomain(name) {
+		return c.Status(fiber.StatusConflict).
+			JSON(&fiber.Map{"error": "Domain is configured in config.toml"})
+	}
+	metadata, err := encodeMetadata(req.Metadata)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": "Invalid metadata"})
+	}
+
+	now := time.Now().UnixMilli()
+	row := db.Domain{Name: name, Status: req.Status, Metadata: metadata, Createdat: now, Updatedat: now}
+	queries := c.Locals("queries").(storage.Store)
+	n, err := queries.InsertDomain(c.Context(), db.InsertDomainParams(row))
+	if err != nil {
+		log.Println("Error inserting domain:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error creating domain"})
+	}
+	if n == 0 {
+		return c.Status(fiber.StatusConflict).JSON(&fiber.Map{"error": "Domain already exists"})
+	}
+	status, err := reloadDomains(c, cfg, queries, fiber.StatusCreated)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{"error": err.Error()})
+	}
+	return c.Status(status).JSON(&DomainResponse{Success: true, Data: domainData(row)})
+}
+
+// UpdateDomain changes the status or metadata of a domain added through the
+// API, e.g. {"status": "disabled"} to stop accepting mail for it.
+func UpdateDomain(c *fiber.Ctx) error {
+	var req UpdateDomainRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": "Invalid request body"})
+	}
+	cfg := c.Locals("config").(*config.Config)
+	name, err := domains.Normalize(c.Params("domain"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": err.Error()})
+	}
+	if cfg.IsConfiguredDomain(name) {
+		return c.Status(fiber.StatusConflict).
+			JSON(&fiber.Map{"error": "Domain is configured in config.toml"})
+	}
+	queries := c.Locals("queries").(storage.Store)
+	row, err := queries.GetDomain(c.Context(), name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(&fiber.Map{"error": "Domain does not exist"})
+	}
+	if err != nil {
+		log.Println("Error getting domain:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error updating domain"})
+	}
+	if req.Status != nil {
+		if err = domains.CheckStatus(*req.Status); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": err.Error()})
+		}
+		row.Status = *req.Status
+	}
+	if req.Metadata != nil {
+		if row.Metadata, err = encodeMetadata(req.Metadata); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": "Invalid metadata"})
+		}
+	}
+
+	row.Updatedat = time.Now().UnixMilli()
+	n, err := queries.UpdateDomain(c.Context(), db.UpdateDomainParams{
+		Status:    row.Status,
+		Metadata:  row.Metadata,
+		Updatedat: row.Updatedat,
+		Name:      name,
+	})
+	if err != nil {
+		log.Println("Error updating domain:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error updating domain"})
+	}
+	if n == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(&fiber.Map{"error": "Domain does not exist"})
+	}
+	status, err := reloadDomains(c, cfg, queries, fiber.StatusOK)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{"error": err.Error()})
+	}
+	return c.Status(status).JSON(&DomainResponse{Success: true, Data: domainData(row)})
+}
+
+// DeleteDomain removes a domain added through the API. Mail already received
+// for it is kept until it expires.
+func DeleteDomain(c *fiber.Ctx) error {
+	cfg := c.Locals("config").(*config.Config)
+	name, err := domains.Normalize(c.Params("domain"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": err.Error()})
+	}
+	if cfg.IsConfiguredDomain(name) {
+		return c.Status(fiber.StatusConflict).
+			JSON(&fiber.Map{"error": "Domain is configured in config.toml"})
+	}
+	queries := c.Locals("queries").(storage.Store)
+	n, err := queries.DeleteDomain(c.Context(), name)
+	if err != nil {
+		log.Println("Error deleting domain:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error deleting domain"})
+	}
+	if n == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(&fiber.Map{"error": "Domain does not exist"})
+	}
+	status, err := reloadDomains(c, cfg, queries, fiber.StatusOK)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{"error": err.Error()})
+	}
+	return c.Status(status).JSON(&fiber.Map{"success": true})
+}
+
+// reloadDomains makes a change visible to this process right away and tells
+// the domain watchers of the other processes and replicas about it. With
+// apply_postfix, a change to the set of active domains is applied to Postfix
+// in the background by those watchers, and status is replaced by 202
+// Accepted. The returned error is meant for the response.
+func reloadDomains(c *fiber.Ctx, cfg *config.Config, queries storage.Store, status int) (int, error) {
+	before := cfg.ActiveDomains()
+	if err := domains.Load(c.Context(), queries, cfg); err != nil {
+		log.Println("Error loading domains:", err)
+		return 0, errors.New("Change saved, but reloading domains failed")
+	}
+	hub := c.Locals("hub").(*pubsub.Hub)
+	hub.Publish(pubsub.Event{Type: pubsub.EventDomainsChanged})
+	if !cfg.Domains.ApplyPostfix || cfg.SMTP.Enabled || slices.Equal(before, cfg.ActiveDomains()) {
+		return status, nil
+	}
+	return fiber.StatusAccepted, nil
+}
+
+func encodeMetadata(metadata map[string]any) (string, error) {
+	if metadata == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(metadata)
+	return string(b), err
+}
+
+func domainData(row db.Domain) DomainData {
+	var metadata map[string]any
+	if err := json.Unmarshal([]byte(row.Metadata), &metadata); err != nil {
+		log.Println("Error decoding domain metadata:", err)
+	}
+	createdAt := time.UnixMilli(row.Createdat)
+	updatedAt := time.UnixMilli(row.Updatedat)
+	return DomainData{
+		Name:      row.Name,
+		Status:    row.Status,
+		Source:    "api",
+		Metadata:  metadata,
+		CreatedAt: &createdAt,
+		UpdatedAt: &updatedAt,
+	}
+}
@@ -3,9 +3,9 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
-	"slices"
 	"strings"
 	"time"
 
@@ -13,6 +13,7 @@ import (
 
 	"github.com/pageton/temp-mail/config"
 	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/storage"
 )
 
 type DatabaseEmail struct {
@@ -27,61 +28,132 @@ type DatabaseEmail struct {
 type DatabaseEmails []DatabaseEmail
 
 type EmailResponse struct {
-	Success bool           `json:"success"`
-	Data    DatabaseEmails `json:"data"`
+	Success    bool           `json:"success"`
+	Data       DatabaseEmails `json:"data"`
+	NextCursor *string        `json:"nextCursor,omitempty"`
 }
 
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
 func GetEmail(c *fiber.Ctx) error {
-	email := c.Params("email")
-	if email == "" {
-		return c.Status(fiber.StatusBadRequest).SendString("Missing email")
-	}
-	cfg := c.Locals("config").(*config.Config)
-	parts := strings.SplitN(email, "@", 2)
-	if len(parts) != 2 {
-		fmt.Println("Invalid email address")
-		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": "Invalid email address"})
+	email, ferr := emailParam(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(&fiber.Map{"error": ferr.Message})
 	}
-	domain := parts[1]
-	if !slices.Contains(cfg.Domains.Aliases, domain) {
-		log.Println("Email address does NOT belong to allowed domains:", domain)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Email address does not belong to allowed domains",
-		})
+
+	params, err := listParams(c, email)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": err.Error()})
 	}
 
-	queries := c.Locals("queries").(*db.Queries)
-	emails, err := queries.GetEmailsForAddress(
-		c.Context(),
-		sql.NullString{String: email, Valid: true},
-	)
+	queries := c.Locals("queries").(storage.Store)
+	emails, err := queries.GetEmailsForAddress(c.Context(), params)
 	if err != nil {
 		log.Println("Error getting emails:", err)
 		return c.Status(fiber.StatusInternalServerError).
 			JSON(&fiber.Map{"error": "Error getting emails"})
 	}
 
-	if len(emails) == 0 {
-		log.Println("No emails found for address:", email)
-		return c.Status(fiber.StatusNotFound).
-			JSON(&fiber.Map{"error": "No emails found for address"})
+	// One extra row is fetched to tell whether another page follows.
+	var nextCursor *string
+	if pageSize := int(params.Limit) - 1; len(emails) > pageSize {
+		emails = emails[:pageSize]
+		last := emails[len(emails)-1]
+		cursor := emailCursor{CreatedAt: last.Createdat, EmailID: last.Emailid}.String()
+		nextCursor = &cursor
 	}
 
-	var result DatabaseEmails
+	result := make(DatabaseEmails, 0, len(emails))
 	for _, e := range emails {
 		de := DatabaseEmail{
 			ID:          e.ID,
-			Subject:     &e.Subject.String,
-			CreatedAt:   time.UnixMilli(e.Createdat.Int64),
-			ExpiresAt:   e.Expiresat.Time,
-			FromAddress: &e.Fromaddress.String,
+			Subject:     nullString(e.Subject),
+			CreatedAt:   time.UnixMilli(e.Createdat),
+			ExpiresAt:   time.UnixMilli(e.Expiresat),
+			FromAddress: nullString(e.Fromaddress),
 			ToAddress:   e.Toaddress,
 		}
 		result = append(result, de)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(&EmailResponse{
-		Success: true,
-		Data:    result,
+		Success:    true,
+		Data:       result,
+		NextCursor: nextCursor,
 	})
 }
+
+// listParams builds the listing query from the limit, cursor, since, until,
+// from and subject query parameters.
+func listParams(c *fiber.Ctx, email string) (db.GetEmailsForAddressParams, error) {
+	params := db.GetEmailsForAddressParams{
+		Address: sql.NullString{String: email, Valid: true},
+	}
+
+	limit := c.QueryInt("limit", defaultPageSize)
+	if limit < 1 || limit > maxPageSize {
+		return params, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+	}
+	params.Limit = int64(limit) + 1
+
+	if s := c.Query("cursor"); s != "" {
+		cursor, err := parseCursor(s)
+		if err != nil {
+			return params, err
+		}
+		params.CursorCreatedAt = sql.NullInt64{Int64: cursor.CreatedAt, Valid: true}
+		params.CursorID = sql.NullInt64{Int64: cursor.EmailID, Valid: true}
+	}
+	if s := c.Query("since"); s != "" {
+		since, err := parseTime(s)
+		if err != nil {
+			return params, errors.New("invalid since, use RFC 3339 or Unix milliseconds")
+		}
+		params.Since = sql.NullInt64{Int64: since, Valid: true}
+	}
+	if s := c.Query("until"); s != "" {
+		until, err := parseTime(s)
+		if err != nil {
+			return params, errors.New("invalid until, use RFC 3339 or Unix milliseconds")
+		}
+		params.Until = sql.NullInt64{Int64: until, Valid: true}
+	}
+	params.FromAddress = likeFilter(c.Query("from"))
+	params.Subject = likeFilter(c.Query("subject"))
+	return params, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// likeFilter escapes s for the substring LIKE filters, which use '\' as
+// their escape character, so % and _ match literally.
+func likeFilter(s string) sql.NullString {
+	return sql.NullString{String: likeEscaper.Replace(s), Valid: s != ""}
+}
+
+// emailParam returns the lower-cased :email route parameter, or a 400 error
+// when it is not an address on one of the configured domains.
+func emailParam(c *fiber.Ctx) (string, *fiber.Error) {
+	email := strings.ToLower(c.Params("email"))
+	if email == "" {
+		return "", fiber.NewError(fiber.StatusBadRequest, "Missing email")
+	}
+	cfg := c.Locals("config").(*config.Config)
+	parts := strings.SplitN(email, "@", 2)
+	if len(parts) != 2 {
+		log.Println("Invalid email address:", email)
+		return "", fiber.NewError(fiber.StatusBadRequest, "Invalid email address")
+	}
+	domain := parts[1]
+	if !cfg.HasDomain(domain) {
+		log.Println("Email address does NOT belong to allowed domains:", domain)
+		return "", fiber.NewError(
+			fiber.StatusBadRequest,
+			"Email address does not belong to allowed domains",
+		)
+	}
+	return email, nil
+}
@@ -2,12 +2,13 @@
 package handlers
 
 import (
+	"database/sql"
 	"log"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 
-	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/storage"
 )
 
 type InboxResponse struct {
@@ -19,6 +20,10 @@ type InboxResponse struct {
 	CreatedAt   time.Time `json:"createdAt"`
 	FromAddress *string   `json:"fromAddress"`
 	ToAddress   string    `json:"toAddress"`
+	HasText     bool      `json:"hasText"`
+	HasHTML     bool      `json:"hasHtml"`
+
+	Attachments []AttachmentResponse `json:"attachments"`
 }
 
 func GetInbox(c *fiber.Ctx) error {
@@ -26,21 +31,38 @@ func GetInbox(c *fiber.Ctx) error {
 	if inboxID == "" {
 		return c.Status(fiber.StatusBadRequest).SendString("Missing inbox ID")
 	}
-	queries := c.Locals("queries").(*db.Queries)
+	queries := c.Locals("queries").(storage.Store)
 	inbox, err := queries.GetInboxByID(c.Context(), inboxID)
 	if err != nil || inbox.ID == "" {
 		log.Println("Error getting inbox:", err)
 		return c.Status(fiber.StatusNotFound).
 			SendString("Inbox does not exist or has been deleted")
 	}
+	attachments, err := attachmentsForInbox(c, queries, inbox.ID)
+	if err != nil {
+		log.Println("Error getting attachments:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error getting attachments"})
+	}
 	return c.Status(fiber.StatusOK).JSON(&InboxResponse{
 		ID:          inbox.ID,
-		TextContent: &inbox.Textcontent.String,
-		HTMLContent: &inbox.Htmlcontent.String,
-		Subject:     &inbox.Subject.String,
-		CreatedAt:   time.UnixMilli(inbox.Createdat.Int64),
-		ExpiresAt:   inbox.Expiresat.Time,
-		FromAddress: &inbox.Fromaddress.String,
+		TextContent: nullString(inbox.Textcontent),
+		HTMLContent: nullString(inbox.Htmlcontent),
+		Subject:     nullString(inbox.Subject),
+		CreatedAt:   time.UnixMilli(inbox.Createdat),
+		ExpiresAt:   time.UnixMilli(inbox.Expiresat),
+		FromAddress: nullString(inbox.Fromaddress),
 		ToAddress:   inbox.Toaddress,
+		HasText:     inbox.Textcontent.Valid,
+		HasHTML:     inbox.Htmlcontent.Valid,
+		Attachments: attachments,
 	})
 }
+
+// nullString returns a pointer to the string value, or nil if it is NULL.
+func nullString(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
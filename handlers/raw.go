@@ -0,0 +1,38 @@
+// Package handlers contains the raw message handlers for the application.
+package handlers
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pageton/temp-mail/internal/ingest"
+	"github.com/pageton/temp-mail/internal/storage"
+)
+
+func GetRawMessage(c *fiber.Ctx) error {
+	inboxID := c.Params("inboxid")
+	if inboxID == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("Missing inbox ID")
+	}
+	queries := c.Locals("queries").(storage.Store)
+	raw, err := queries.GetRawMessageByInboxID(c.Context(), inboxID)
+	if err != nil {
+		log.Println("Error getting raw message:", err)
+		return c.Status(fiber.StatusNotFound).
+			JSON(&fiber.Map{"error": "Raw message does not exist or has been deleted"})
+	}
+
+	content, err := ingest.DecompressRaw(raw.Compression, raw.Content)
+	if err != nil {
+		log.Println("Error decompressing raw message:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error reading raw message"})
+	}
+
+	c.Attachment(inboxID + ".eml")
+	c.Set(fiber.HeaderContentType, "message/rfc822")
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	c.Set(fiber.HeaderContentSecurityPolicy, "sandbox; default-src 'none'")
+	return c.Status(fiber.StatusOK).Send(content)
+}
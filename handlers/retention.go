@@ -0,0 +1,120 @@
+// Package handlers contains the retention handlers for the application.
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/retention"
+	"github.com/pageton/temp-mail/internal/storage"
+)
+
+type RetentionRequest struct {
+	// Retention is a Go duration such as "1h" or "720h", counted from the
+	// time a message was received.
+	Retention string `json:"retention"`
+}
+
+type RetentionResponse struct {
+	Success bool          `json:"success"`
+	Data    RetentionData `json:"data"`
+}
+
+type RetentionData struct {
+	Address   string     `json:"address,omitempty"`
+	ID        string     `json:"id,omitempty"`
+	Retention string     `json:"retention"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Updated   int        `json:"updated"`
+}
+
+// SetAddressRetention changes how long mail for an address is kept, for
+// stored and future messages.
+func SetAddressRetention(c *fiber.Ctx) error {
+	email, ferr := emailParam(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(&fiber.Map{"error": ferr.Message})
+	}
+	d, ferr := retentionParam(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(&fiber.Map{"error": ferr.Message})
+	}
+
+	cfg := c.Locals("config").(*config.Config)
+	queries := c.Locals("queries").(storage.Store)
+	var updated int
+	err := queries.InTx(c.Context(), func(tx storage.Store) error {
+		var err error
+		updated, err = retention.SetForAddress(c.Context(), cfg, tx, email, d)
+		return err
+	})
+	if err != nil {
+		log.Println("Error setting address retention:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error setting retention"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&RetentionResponse{
+		Success: true,
+		Data: RetentionData{
+			Address:   email,
+			Retention: d.String(),
+			Updated:   updated,
+		},
+	})
+}
+
+// SetInboxRetention changes when a single message expires. The message is
+// shared by all of its recipients' inboxes, so it is never set to expire
+// before the retention of the other recipients.
+func SetInboxRetention(c *fiber.Ctx) error {
+	d, ferr := retentionParam(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(&fiber.Map{"error": ferr.Message})
+	}
+
+	queries := c.Locals("queries").(storage.Store)
+	inbox, err := queries.GetInboxByID(c.Context(), c.Params("inboxid"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).
+			JSON(&fiber.Map{"error": "Inbox does not exist or has been deleted"})
+	}
+	cfg := c.Locals("config").(*config.Config)
+	expiresAt, err := retention.SetForInbox(
+		c.Context(), cfg, queries, inbox.ID, inbox.Address.String, time.UnixMilli(inbox.Createdat), d,
+	)
+	if err != nil {
+		log.Println("Error setting inbox retention:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error setting retention"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&RetentionResponse{
+		Success: true,
+		Data: RetentionData{
+			ID:        inbox.ID,
+			Retention: d.String(),
+			ExpiresAt: &expiresAt,
+			Updated:   1,
+		},
+	})
+}
+
+func retentionParam(c *fiber.Ctx) (time.Duration, *fiber.Error) {
+	var req RetentionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	cfg := c.Locals("config").(*config.Config)
+	d, err := time.ParseDuration(req.Retention)
+	if err != nil || d <= 0 || d > cfg.Domains.MaxRetention {
+		return 0, fiber.NewError(
+			fiber.StatusBadRequest,
+			"retention must be a duration up to "+cfg.Domains.MaxRetention.String(),
+		)
+	}
+	return d, nil
+}
@@ -0,0 +1,85 @@
+// Package handlers contains the search handlers for the application.
+package handlers
+
+import (
+	"database/sql"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pageton/temp-mail/internal/search"
+	"github.com/pageton/temp-mail/internal/storage"
+)
+
+type SearchResult struct {
+	DatabaseEmail
+	SubjectHighlight *string `json:"subjectHighlight,omitempty"`
+	Snippet          *string `json:"snippet,omitempty"`
+}
+
+type SearchResponse struct {
+	Success bool           `json:"success"`
+	Data    []SearchResult `json:"data"`
+}
+
+func SearchEmails(c *fiber.Ctx) error {
+	email, ferr := emailParam(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(&fiber.Map{"error": ferr.Message})
+	}
+	queries := c.Locals("queries").(storage.Store)
+	if !queries.SearchEnabled() {
+		return c.Status(fiber.StatusNotImplemented).
+			JSON(&fiber.Map{"error": "Search is not available on this server"})
+	}
+	if _, err := search.Parse(c.Query("q")); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": "Missing search query"})
+	}
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	rows, err := queries.Search(c.Context(), email, c.Query("q"), int64(limit))
+	if err != nil {
+		log.Println("Error searching emails:", err)
+		return c.Status(fiber.StatusBadRequest).
+			JSON(&fiber.Map{"error": "Error searching emails"})
+	}
+
+	result := make([]SearchResult, 0, len(rows))
+	for _, r := range rows {
+		result = append(result, SearchResult{
+			DatabaseEmail: DatabaseEmail{
+				ID:          r.ID,
+				Subject:     nullString(r.Subject),
+				CreatedAt:   time.UnixMilli(r.Createdat),
+				ExpiresAt:   time.UnixMilli(r.Expiresat),
+				FromAddress: nullString(r.Fromaddress),
+				ToAddress:   r.Toaddress,
+			},
+			SubjectHighlight: markMatches(r.Subjecthighlight),
+			Snippet:          markMatches(r.Snippet),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&SearchResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+var matchMarker = strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>")
+
+// markMatches HTML-escapes a highlighted column and wraps its matches in
+// <mark> tags.
+func markMatches(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	marked := matchMarker.Replace(html.EscapeString(s.String))
+	return &marked
+}
@@ -0,0 +1,104 @@
+// Package handlers contains the server-sent events handlers for the application.
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pageton/temp-mail/internal/pubsub"
+)
+
+// streamHeartbeat is how often an idle stream sends a comment line, which
+// keeps proxies from closing it and detects clients that went away.
+const streamHeartbeat = 15 * time.Second
+
+// errClientGone stops a stream whose client closed the connection.
+var errClientGone = errors.New("client disconnected")
+
+func StreamEmails(c *fiber.Ctx) error {
+	email, ferr := emailParam(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(&fiber.Map{"error": ferr.Message})
+	}
+	hub := c.Locals("hub").(*pubsub.Hub)
+	sub := hub.Subscribe(email)
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	conn := c.Context().Conn()
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer sub.Close()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		send := func(format string, args ...any) error {
+			if _, err := fmt.Fprintf(w, format, args...); err != nil {
+				return err
+			}
+			return w.Flush()
+		}
+
+		if err := send("retry: 3000\n: subscribed to %s\n\n", email); err != nil {
+			return
+		}
+		for {
+			var err error
+			select {
+			case e, ok := <-sub.Events():
+				if !ok {
+					return
+				}
+				if e.Type != pubsub.EventReceived {
+					continue
+				}
+				data, merr := json.Marshal(eventEmail(e))
+				if merr != nil {
+					log.Println("Error encoding event:", merr)
+					continue
+				}
+				err = send("event: %s\nid: %s\ndata: %s\n\n", e.Type, e.InboxID, data)
+			case <-heartbeat.C:
+				// A write to a closed socket can still succeed once, so
+				// also check the connection itself.
+				if clientGone(conn) {
+					err = errClientGone
+				} else {
+					err = send(": ping\n\n")
+				}
+			}
+			if err != nil {
+				// The client disconnected; the deferred Close drops the
+				// subscription.
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// eventEmail returns the DatabaseEmail summary carried by an event.
+func eventEmail(e pubsub.Event) DatabaseEmail {
+	de := DatabaseEmail{
+		ID:        e.InboxID,
+		CreatedAt: e.CreatedAt,
+		ExpiresAt: e.ExpiresAt,
+		ToAddress: e.ToAddress,
+	}
+	if e.Subject != "" {
+		de.Subject = &e.Subject
+	}
+	if e.FromAddress != "" {
+		de.FromAddress = &e.FromAddress
+	}
+	return de
+}
@@ -0,0 +1,192 @@
+// Package handlers contains the WebSocket subscription handlers for the application.
+package handlers
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/claims"
+	"github.com/pageton/temp-mail/internal/pubsub"
+	"github.com/pageton/temp-mail/internal/storage"
+	"github.com/pageton/temp-mail/internal/utils"
+)
+
+// SubscribeRequest is a client frame on the subscription socket.
+type SubscribeRequest struct {
+	Action    string   `json:"action"` // "subscribe" or "unsubscribe"
+	Addresses []string `json:"addresses"`
+	Domains   []string `json:"domains"`
+	// Tokens maps claimed addresses to their ownership tokens.
+	Tokens map[string]string `json:"tokens"`
+}
+
+// SubscribeFrame is a server frame on the subscription socket.
+type SubscribeFrame struct {
+	Type      string         `json:"type"`
+	Address   string         `json:"address,omitempty"`
+	ID        string         `json:"id,omitempty"`
+	Data      *DatabaseEmail `json:"data,omitempty"`
+	Addresses []string       `json:"addresses,omitempty"`
+	Domains   []string       `json:"domains,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+const socketPingInterval = 30 * time.Second
+
+// Subscribe serves a WebSocket on which a client subscribes to addresses and
+// whole domains and receives new-message, deletion and expiry events.
+// Claimed addresses need their token to be subscribed to, and their events
+// are left out of domain subscriptions.
+func Subscribe(conn *websocket.Conn) {
+	cfg := conn.Locals("config").(*config.Config)
+	queries := conn.Locals("queries").(storage.Store)
+	hub := conn.Locals("hub").(*pubsub.Hub)
+	sub := hub.Subscribe()
+	defer sub.Close()
+	ctx := context.Background()
+
+	var ownedMu sync.Mutex
+	owned := make(map[string]bool)
+	visible := func(address string) bool {
+		ownedMu.Lock()
+		ok := owned[address]
+		ownedMu.Unlock()
+		if ok {
+			return true
+		}
+		claimed, err := claims.Claimed(ctx, queries, address)
+		if err != nil {
+			log.Println("Error checking address claim:", err)
+		}
+		return err == nil && !claimed
+	}
+
+	var writeMu sync.Mutex
+	write := func(frame SubscribeFrame) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteJSON(frame)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ping := time.NewTicker(socketPingInterval)
+		defer ping.Stop()
+		for {
+			select {
+			case e, ok := <-sub.Events():
+				if !ok {
+					return
+				}
+				if !visible(e.Address) {
+					continue
+				}
+				frame := SubscribeFrame{Type: string(e.Type), Address: e.Address, ID: e.InboxID}
+				if e.Type == pubsub.EventReceived {
+					de := eventEmail(e)
+					frame.Data = &de
+				}
+				if err := write(frame); err != nil {
+					return
+				}
+			case <-ping.C:
+				writeMu.Lock()
+				err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+				writeMu.Unlock()
+				if err != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		var req SubscribeRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Println("Error reading subscription request:", err)
+			}
+			return
+		}
+
+		topics, invalid := subscriptionTopics(cfg, req)
+		if invalid != "" {
+			if err := write(SubscribeFrame{Type: "error", Error: "Not a configured address or domain: " + invalid}); err != nil {
+				return
+			}
+			continue
+		}
+
+		switch req.Action {
+		case "subscribe":
+			if address, err := checkTokens(ctx, queries, req); err != nil {
+				if err := write(SubscribeFrame{Type: "error", Address: address, Error: err.Error()}); err != nil {
+					return
+				}
+				continue
+			}
+			ownedMu.Lock()
+			for _, address := range req.Addresses {
+				if req.Tokens[address] != "" {
+					owned[strings.ToLower(strings.TrimSpace(address))] = true
+				}
+			}
+			ownedMu.Unlock()
+			sub.Add(topics...)
+		case "unsubscribe":
+			sub.Remove(topics...)
+		default:
+			if err := write(SubscribeFrame{Type: "error", Error: "Unknown action: " + req.Action}); err != nil {
+				return
+			}
+			continue
+		}
+		ack := SubscribeFrame{Type: req.Action + "d", Addresses: req.Addresses, Domains: req.Domains}
+		if err := write(ack); err != nil {
+			return
+		}
+	}
+}
+
+// subscriptionTopics validates the addresses and domains of a request against
+// the configured domains. It returns the first invalid entry, if any.
+func subscriptionTopics(cfg *config.Config, req SubscribeRequest) ([]string, string) {
+	var topics []string
+	for _, address := range req.Addresses {
+		address = strings.ToLower(strings.TrimSpace(address))
+		if !cfg.HasDomain(utils.EmailDomain(address)) {
+			return nil, address
+		}
+		topics = append(topics, address)
+	}
+	for _, domain := range req.Domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if !cfg.HasDomain(domain) {
+			return nil, domain
+		}
+		topics = append(topics, domain)
+	}
+	return topics, ""
+}
+
+// checkTokens verifies the tokens of the claimed addresses in a subscribe
+// request. It returns the first address that failed.
+func checkTokens(ctx context.Context, queries storage.Store, req SubscribeRequest) (string, error) {
+	for _, address := range req.Addresses {
+		token := req.Tokens[address]
+		address = strings.ToLower(strings.TrimSpace(address))
+		if err := claims.Check(ctx, queries, address, token); err != nil {
+			return address, err
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,143 @@
+// Package handlers contains the long-poll handlers for the application.
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/pubsub"
+	"github.com/pageton/temp-mail/internal/storage"
+)
+
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 120 * time.Second
+	// waitProbeInterval is how often a waiting request checks whether its
+	// client is still connected.
+	waitProbeInterval = 5 * time.Second
+)
+
+type WaitResponse struct {
+	Success bool          `json:"success"`
+	Data    DatabaseEmail `json:"data"`
+	// Cursor can be passed as after= to wait for the next message.
+	Cursor string `json:"cursor"`
+}
+
+// WaitForEmail blocks until a message for the address arrives that matches
+// the subject and from filters, or returns 204 when the timeout expires.
+// Without after= only messages stored after the request started count; with
+// it, stored messages newer than the cursor are returned immediately.
+func WaitForEmail(c *fiber.Ctx) error {
+	email, ferr := emailParam(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(&fiber.Map{"error": ferr.Message})
+	}
+	timeout, err := parseWaitTimeout(c.Query("timeout"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": err.Error()})
+	}
+	subject := c.Query("subject")
+	from := c.Query("from")
+
+	// Subscribe before looking at stored mail so nothing slips in between.
+	hub := c.Locals("hub").(*pubsub.Hub)
+	sub := hub.Subscribe(email)
+	defer sub.Close()
+
+	if after := c.Query("after"); after != "" {
+		cursor, err := parseCursor(after)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": err.Error()})
+		}
+		queries := c.Locals("queries").(storage.Store)
+		next, err := queries.GetNextEmailForAddress(c.Context(), db.GetNextEmailForAddressParams{
+			Address:        sql.NullString{String: email, Valid: true},
+			AfterCreatedAt: cursor.CreatedAt,
+			AfterID:        cursor.EmailID,
+			Subject:        likeFilter(subject),
+			FromAddress:    likeFilter(from),
+		})
+		switch {
+		case err == nil:
+			return c.Status(fiber.StatusOK).JSON(&WaitResponse{
+				Success: true,
+				Data: DatabaseEmail{
+					ID:          next.ID,
+					Subject:     nullString(next.Subject),
+					CreatedAt:   time.UnixMilli(next.Createdat),
+					ExpiresAt:   time.UnixMilli(next.Expiresat),
+					FromAddress: nullString(next.Fromaddress),
+					ToAddress:   next.Toaddress,
+				},
+				Cursor: emailCursor{CreatedAt: next.Createdat, EmailID: next.Emailid}.String(),
+			})
+		case !errors.Is(err, sql.ErrNoRows):
+			log.Println("Error getting next email:", err)
+			return c.Status(fiber.StatusInternalServerError).
+				JSON(&fiber.Map{"error": "Error getting emails"})
+		}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	probe := time.NewTicker(waitProbeInterval)
+	defer probe.Stop()
+	conn := c.Context().Conn()
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				return c.SendStatus(fiber.StatusNoContent)
+			}
+			if e.Type != pubsub.EventReceived || !containsFold(e.Subject, subject) ||
+				!containsFold(e.FromAddress, from) {
+				continue
+			}
+			return c.Status(fiber.StatusOK).JSON(&WaitResponse{
+				Success: true,
+				Data:    eventEmail(e),
+				Cursor:  emailCursor{CreatedAt: e.CreatedAt.UnixMilli(), EmailID: e.EmailID}.String(),
+			})
+		case <-timer.C:
+			return c.SendStatus(fiber.StatusNoContent)
+		case <-probe.C:
+			if clientGone(conn) {
+				// Nobody is left to answer; release the subscription.
+				return nil
+			}
+		case <-c.Context().Done():
+			return c.SendStatus(fiber.StatusServiceUnavailable)
+		}
+	}
+}
+
+// parseWaitTimeout accepts Go durations ("30s", "1m") and plain seconds.
+func parseWaitTimeout(s string) (time.Duration, error) {
+	if s == "" {
+		return defaultWaitTimeout, nil
+	}
+	timeout, err := time.ParseDuration(s)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(s)
+		if convErr != nil {
+			return 0, errors.New("invalid timeout, use a duration such as 30s")
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+	if timeout <= 0 || timeout > maxWaitTimeout {
+		return 0, errors.New("timeout must be between 1s and 2m")
+	}
+	return timeout, nil
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
@@ -2,19 +2,19 @@
 package handlers
 
 import (
-	"database/sql"
+	"crypto/subtle"
+	"errors"
 	"log"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/jhillyerd/enmime/v2"
-	"github.com/lucsky/cuid"
 
 	"github.com/pageton/temp-mail/config"
-	"github.com/pageton/temp-mail/internal/db"
-	"github.com/pageton/temp-mail/internal/utils"
+	"github.com/pageton/temp-mail/internal/ingest"
+	"github.com/pageton/temp-mail/internal/pubsub"
+	"github.com/pageton/temp-mail/internal/storage"
+	"github.com/pageton/temp-mail/internal/webhooks"
 )
 
 type WebhookResponse struct {
@@ -24,102 +24,68 @@ type WebhookResponse struct {
 
 func Webhook(c *fiber.Ctx) error {
 	cfg := c.Locals("config").(*config.Config)
-	secret := c.Get("Secret")
-	s, err := strconv.Atoi(secret)
-	if err != nil || s != cfg.Server.Secret {
+	envelope := envelopeRecipients(c)
+	if err := verifyWebhook(c, cfg, envelope); err != nil {
+		log.Println("Rejected webhook request:", err)
 		return c.Status(fiber.StatusUnauthorized).SendString("Unauthorized")
 	}
-	r := strings.NewReader(string(c.Body()))
 
-	env, err := enmime.ReadEnvelope(r)
+	queries := c.Locals("queries").(storage.Store)
+	hub := c.Locals("hub").(*pubsub.Hub)
+	emailID, err := ingest.Store(c.Context(), cfg, queries, hub, c.Body(), envelope)
 	if err != nil {
-		log.Println("Error parsing email:", err)
-		return c.Status(fiber.StatusInternalServerError).SendString("Error parsing email")
-	}
-	from := env.GetHeader("From")
-	if from == "" {
-		log.Println("Error getting from address:", err)
-		return c.Status(fiber.StatusInternalServerError).SendString("Error getting from address")
-	}
-	to := env.GetHeader("To")
-	if to == "" {
-		log.Println("Error getting to address:", err)
-		return c.Status(fiber.StatusInternalServerError).SendString("Error getting to address")
-	}
-	subject := env.GetHeader("Subject")
-	if subject == "" {
-		log.Println("Error getting subject:", err)
-		return c.Status(fiber.StatusInternalServerError).SendString("Error getting subject")
-	}
-	textBody := env.Text
-	if textBody == "" {
-		log.Println("Error getting body:", err)
-		return c.Status(fiber.StatusInternalServerError).SendString("Error getting body")
-	}
-	htmlBody := env.HTML
-	if htmlBody == "" {
-		log.Println("Error getting html:", err)
-		return c.Status(fiber.StatusInternalServerError).SendString("Error getting html")
+		log.Println("Error storing email:", err)
+		if errors.Is(err, ingest.ErrParse) {
+			return c.Status(fiber.StatusBadRequest).SendString("Error parsing email")
+		}
+		if errors.Is(err, ingest.ErrNoRecipients) {
+			return c.Status(fiber.StatusUnprocessableEntity).SendString("Email has no recipients")
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString("Error storing email")
 	}
 
-	toAddresses := utils.ParseEmailAddresses(to)
-	fromAddresses := utils.ParseEmailAddresses(from)
-	queries := c.Locals("queries").(*db.Queries)
-	emailID, err := queries.InsertEmail(
-		c.Context(),
-		db.InsertEmailParams{
-			Subject:   sql.NullString{String: subject, Valid: true},
-			Expiresat: sql.NullTime{Time: time.Now().Add(3 * 24 * time.Hour), Valid: true},
-		},
-	)
-	if err != nil {
-		log.Println("Error inserting email:", err)
-		return c.Status(fiber.StatusInternalServerError).SendString("Error inserting email")
-	}
-	recipientGroups := []struct {
-		Type   string
-		Values []string
-	}{
-		{Type: "from", Values: fromAddresses},
-		{Type: "to", Values: toAddresses},
+	res := WebhookResponse{
+		Success: true,
+		Data:    emailID,
 	}
+	return c.Status(fiber.StatusOK).JSON(&res)
+}
 
-	for _, group := range recipientGroups {
-		for _, addr := range group.Values {
-			err = queries.InsertEmailAddress(
-				c.Context(),
-				db.InsertEmailAddressParams{
-					Emailid: sql.NullInt64{Int64: emailID, Valid: true},
-					Type:    sql.NullString{String: group.Type, Valid: true},
-					Address: sql.NullString{String: addr, Valid: true},
-				},
-			)
-			if err != nil {
-				log.Println("Error inserting email address:", err)
-				return c.Status(fiber.StatusInternalServerError).
-					SendString("Error inserting email address")
-			}
+// verifyWebhook checks the HMAC signature of a webhook request, which covers
+// the envelope recipients as well as the message. With legacy_secret enabled,
+// requests carrying the old plain Secret header are accepted as well while
+// forward scripts are being upgraded.
+func verifyWebhook(c *fiber.Ctx, cfg *config.Config, envelope []string) error {
+	secret := string(cfg.Server.Secret)
+	if cfg.Server.LegacySecret && c.Get(webhooks.HeaderSignature) == "" {
+		legacy := c.Get("Secret")
+		if secret == "" || subtle.ConstantTimeCompare([]byte(legacy), []byte(secret)) != 1 {
+			return webhooks.ErrInvalidSignature
 		}
+		return nil
 	}
-	for _, toAddress := range toAddresses {
-		err = queries.InsertInbox(
-			c.Context(),
-			db.InsertInboxParams{
-				ID:          cuid.New(),
-				Emailid:     sql.NullInt64{Int64: emailID, Valid: true},
-				Address:     sql.NullString{String: toAddress, Valid: true},
-				Textcontent: sql.NullString{String: textBody, Valid: true},
-				Htmlcontent: sql.NullString{String: htmlBody, Valid: true},
-			},
-		)
-		if err != nil {
-			log.Println("Error inserting inbox:", err)
-			return c.Status(fiber.StatusInternalServerError).SendString("Error inserting inbox")
+	return webhooks.VerifyMessage(
+		secret,
+		c.Get(webhooks.HeaderTimestamp),
+		c.Get(webhooks.HeaderSignature),
+		envelope,
+		c.Body(),
+		cfg.Server.ReplayWindow,
+		time.Now(),
+	)
+}
+
+// envelopeRecipients returns the envelope recipients passed by the forward
+// script in the X-Envelope-To header or the recipient query parameter.
+func envelopeRecipients(c *fiber.Ctx) []string {
+	var result []string
+	for _, value := range strings.Split(c.Get("X-Envelope-To"), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
 		}
 	}
-	res := WebhookResponse{
-		Success: true,
-		Data:    emailID,
+	for _, value := range c.Context().QueryArgs().PeekMulti("recipient") {
+		result = append(result, string(value))
 	}
-	return c.Status(fiber.StatusOK).JSON(&res)
+	return result
 }
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/webhooks"
+)
+
+func TestVerifyWebhookCoversEnvelope(t *testing.T) {
+	cfg := &config.Config{Server: config.ServerConfig{Secret: "s3cret", ReplayWindow: time.Minute}}
+	app := fiber.New()
+	app.Post("/webhook", func(c *fiber.Ctx) error {
+		if err := verifyWebhook(c, cfg, envelopeRecipients(c)); err != nil {
+			return c.SendStatus(fiber.StatusUnauthorized)
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	body := "Subject: hi\r\n\r\nhello\r\n"
+	ts := time.Now().Unix()
+	signature := webhooks.SignMessage("s3cret", ts, []string{"alice@example.com"}, []byte(body))
+
+	tests := []struct {
+		name     string
+		query    string
+		envelope string
+		want     int
+	}{
+		{"signed envelope", "", "alice@example.com", fiber.StatusOK},
+		{"envelope swapped", "", "mallory@example.com", fiber.StatusUnauthorized},
+		{"envelope added", "", "alice@example.com,mallory@example.com", fiber.StatusUnauthorized},
+		{"recipient appended", "?recipient=mallory@example.com", "alice@example.com", fiber.StatusUnauthorized},
+		{"envelope dropped", "", "", fiber.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/webhook"+tt.query, strings.NewReader(body))
+		req.Header.Set("X-Envelope-To", tt.envelope)
+		req.Header.Set(webhooks.HeaderTimestamp, strconv.FormatInt(ts, 10))
+		req.Header.Set(webhooks.HeaderSignature, signature)
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, res.Body)
+		if res.StatusCode != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, res.StatusCode, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Package addresses contains the address generator for the application.
+package addresses
+
+import (
+	"context"
+	crand "crypto/rand"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lucsky/cuid"
+
+	"github.com/pageton/temp-mail/internal/db"
+)
+
+// Naming strategies for generated local parts.
+const (
+	StrategyWords         = "words"         // amber-otter-k7m2qp
+	StrategyCUID          = "cuid"          // c0ffee0000abcdefghijklmno
+	StrategyPronounceable = "pronounceable" // tokavemi
+	StrategyPrefix        = "prefix"        // <prefix>-x7k2qp
+)
+
+var (
+	ErrUnknownStrategy = errors.New("unknown naming strategy")
+	ErrInvalidPrefix   = errors.New("prefix must be 1-32 characters of a-z, 0-9, '.', '_' or '-'")
+	ErrExhausted       = errors.New("could not find an unused address")
+)
+
+// maxAttempts bounds the retries when a generated address is already taken.
+const maxAttempts = 10
+
+var prefixPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,31}$`)
+
+// Create generates an unused address on domain and records it with a
+// lifetime of ttl. An address counts as used when it is already recorded,
+// has received mail or has been claimed. Recording it fails on the primary
+// key of Address if another request got there first; run Create in a
+// transaction so the other checks hold until it commits.
+func Create(
+	ctx context.Context,
+	queries db.Querier,
+	domain, strategy, prefix string,
+	ttl time.Duration,
+) (db.Address, error) {
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	if strategy == StrategyPrefix && !prefixPattern.MatchString(prefix) {
+		return db.Address{}, ErrInvalidPrefix
+	}
+	now := time.Now()
+	for range maxAttempts {
+		local, err := localPart(strategy, prefix)
+		if err != nil {
+			return db.Address{}, err
+		}
+		address := local + "@" + strings.ToLower(domain)
+		inUse, err := queries.AddressInUse(ctx, address)
+		if err != nil {
+			return db.Address{}, fmt.Errorf("checking address: %w", err)
+		}
+		if inUse.Bool {
+			continue
+		}
+		record := db.Address{
+			Address:   address,
+			Strategy:  strategy,
+			Createdat: now.UnixMilli(),
+			Expiresat: now.Add(ttl).UnixMilli(),
+		}
+		n, err := queries.InsertAddress(ctx, db.InsertAddressParams{
+			Address:   record.Address,
+			Strategy:  record.Strategy,
+			Createdat: record.Createdat,
+			Expiresat: record.Expiresat,
+		})
+		if err != nil {
+			return db.Address{}, fmt.Errorf("inserting address: %w", err)
+		}
+		if n == 1 {
+			return record, nil
+		}
+	}
+	return db.Address{}, ErrExhausted
+}
+
+func localPart(strategy, prefix string) (string, error) {
+	switch strategy {
+	case StrategyWords:
+		return fmt.Sprintf("%s-%s-%s", pick(adjectives), pick(nouns), randomSuffix(6)), nil
+	case StrategyCUID:
+		return cuid.New(), nil
+	case StrategyPronounceable:
+		return pronounceable(3 + rand.IntN(2)), nil
+	case StrategyPrefix:
+		return prefix + "-" + randomSuffix(6), nil
+	default:
+		return "", ErrUnknownStrategy
+	}
+}
+
+// pronounceable joins consonant-vowel syllables, ending some with a consonant.
+func pronounceable(syllables int) string {
+	const (
+		consonants = "bdfghklmnprstvz"
+		vowels     = "aeiou"
+	)
+	var b strings.Builder
+	for range syllables {
+		b.WriteByte(consonants[rand.IntN(len(consonants))])
+		b.WriteByte(vowels[rand.IntN(len(vowels))])
+	}
+	if rand.IntN(2) == 0 {
+		b.WriteByte(consonants[rand.IntN(len(consonants))])
+	}
+	return b.String()
+}
+
+// suffixAlphabet has 32 characters, so a random byte maps onto it evenly.
+const suffixAlphabet = "abcdefghijkmnpqrstuvwxyz23456789"
+
+// randomSuffix returns n characters from crypto/rand, so an address cannot
+// be guessed from the word lists or from addresses handed out before.
+func randomSuffix(n int) string {
+	b := make([]byte, n)
+	crand.Read(b)
+	for i := range b {
+		b[i] = suffixAlphabet[b[i]%byte(len(suffixAlphabet))]
+	}
+	return string(b)
+}
+
+func pick(words []string) string {
+	return words[rand.IntN(len(words))]
+}
@@ -0,0 +1,74 @@
+package addresses
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pageton/temp-mail/internal/storage"
+	"github.com/pageton/temp-mail/internal/storage/storagetest"
+)
+
+func TestLocalPart(t *testing.T) {
+	tests := []struct {
+		strategy string
+		prefix   string
+		pattern  string
+	}{
+		{StrategyWords, "", `^[a-z]+-[a-z]+-[a-km-np-z2-9]{6}$`},
+		{StrategyCUID, "", `^c[a-z0-9]{24}$`},
+		{StrategyPronounceable, "", `^[a-z]{6,9}$`},
+		{StrategyPrefix, "ci", `^ci-[a-km-np-z2-9]{6}$`},
+	}
+	for _, tt := range tests {
+		local, err := localPart(tt.strategy, tt.prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !regexp.MustCompile(tt.pattern).MatchString(local) {
+			t.Errorf("%s: %q does not match %s", tt.strategy, local, tt.pattern)
+		}
+	}
+	if _, err := localPart("nope", ""); !errors.Is(err, ErrUnknownStrategy) {
+		t.Errorf("unknown strategy: err = %v", err)
+	}
+}
+
+func TestCreateConcurrent(t *testing.T) {
+	ctx := context.Background()
+	queries, _ := storagetest.SQLite(t)
+
+	const n = 20
+	var wg sync.WaitGroup
+	created := make([]string, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := queries.InTx(ctx, func(tx storage.Store) error {
+				address, err := Create(ctx, tx, "example.com", StrategyWords, "", time.Hour)
+				created[i] = address.Address
+				return err
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for _, address := range created {
+		if seen[address] {
+			t.Errorf("%s handed out twice", address)
+		}
+		seen[address] = true
+		inUse, err := queries.AddressInUse(ctx, address)
+		if err != nil || !inUse.Bool {
+			t.Errorf("%s not recorded (err %v)", address, err)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package addresses
+
+var adjectives = []string{
+	"amber", "ancient", "autumn", "bold", "brave", "breezy", "bright", "calm",
+	"clever", "cosmic", "crimson", "crisp", "curly", "dapper", "dusty", "eager",
+	"early", "fancy", "fuzzy", "gentle", "giant", "golden", "happy", "hidden",
+	"humble", "icy", "jolly", "keen", "kind", "lively", "lucky", "lunar",
+	"mellow", "merry", "misty", "modest", "nimble", "noble", "odd", "olive",
+	"polite", "proud", "quick", "quiet", "rapid", "rosy", "rustic", "shy",
+	"silent", "silver", "sleepy", "snowy", "solar", "spicy", "steady", "sunny",
+	"swift", "tidy", "tiny", "vivid", "warm", "wild", "witty", "zesty",
+}
+
+var nouns = []string{
+	"acorn", "badger", "beacon", "birch", "bison", "breeze", "brook", "cactus",
+	"canyon", "cedar", "comet", "coral", "crane", "dune", "falcon", "fern",
+	"finch", "fjord", "gecko", "glacier", "harbor", "hazel", "heron", "island",
+	"jaguar", "kettle", "koala", "lagoon", "lantern", "lemur", "lotus", "maple",
+	"meadow", "meteor", "moose", "nebula", "oasis", "orchid", "otter", "owl",
+	"panda", "pebble", "pine", "plume", "quartz", "raven", "reef", "river",
+	"robin", "saffron", "sparrow", "spruce", "summit", "thistle", "tiger", "tulip",
+	"valley", "walrus", "willow", "wombat", "yak", "zebra", "zephyr", "tundra",
+}
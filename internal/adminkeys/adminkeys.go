@@ -0,0 +1,77 @@
+// Package adminkeys contains the API keys for the admin routes.
+package adminkeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lucsky/cuid"
+
+	"github.com/pageton/temp-mail/internal/db"
+)
+
+// prefix marks admin keys so they are easy to tell apart from address tokens
+// and to find with secret scanners.
+const prefix = "tmadm_"
+
+var (
+	ErrMissingKey = errors.New("admin API key required")
+	ErrInvalidKey = errors.New("invalid admin API key")
+)
+
+// Create stores a new key named name and returns its ID and the key. Only
+// the key's hash is stored, so a lost key cannot be recovered.
+func Create(ctx context.Context, queries db.Querier, name string) (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	key := prefix + hex.EncodeToString(buf)
+	id := cuid.New()
+	err := queries.InsertAdminKey(ctx, db.InsertAdminKeyParams{
+		ID:        id,
+		Name:      name,
+		Keyhash:   hash(key),
+		Createdat: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return id, key, nil
+}
+
+// Authenticate returns the stored key matching key and records its use, or
+// ErrMissingKey or ErrInvalidKey.
+func Authenticate(ctx context.Context, queries db.Querier, key string) (db.GetAdminKeyByHashRow, error) {
+	if key == "" {
+		return db.GetAdminKeyByHashRow{}, ErrMissingKey
+	}
+	if !strings.HasPrefix(key, prefix) {
+		return db.GetAdminKeyByHashRow{}, ErrInvalidKey
+	}
+	row, err := queries.GetAdminKeyByHash(ctx, hash(key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return row, ErrInvalidKey
+	}
+	if err != nil {
+		return row, err
+	}
+	err = queries.UpdateAdminKeyLastUsed(ctx, db.UpdateAdminKeyLastUsedParams{
+		Lastusedat: sql.NullInt64{Int64: time.Now().UnixMilli(), Valid: true},
+		ID:         row.ID,
+	})
+	return row, err
+}
+
+// hash is the lookup key of an admin key. Keys are 256 random bits, so an
+// unsalted SHA-256 is enough and lets the key be found with one query.
+func hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
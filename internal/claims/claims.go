@@ -0,0 +1,84 @@
+// Package claims contains the address ownership tokens for the application.
+package claims
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+
+	"github.com/pageton/temp-mail/internal/db"
+)
+
+var (
+	ErrAlreadyClaimed = errors.New("address has already been claimed")
+	ErrHasMail        = errors.New("address has already received mail")
+	ErrMissingToken   = errors.New("address is claimed: token required")
+	ErrInvalidToken   = errors.New("invalid token for address")
+)
+
+// Claim records address as owned and returns the bearer token for it. Only
+// the token's hash is stored, so a lost token cannot be recovered. Addresses
+// that already received mail cannot be claimed, since the claim would hand
+// that mail to whoever asked first; run it in a transaction with the check.
+func Claim(ctx context.Context, queries db.Querier, address string) (string, error) {
+	mail, err := queries.CountInboxesForAddress(ctx, sql.NullString{String: address, Valid: true})
+	if err != nil {
+		return "", err
+	}
+	if mail > 0 {
+		return "", ErrHasMail
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	n, err := queries.InsertAddressClaim(ctx, db.InsertAddressClaimParams{
+		Address:   address,
+		Tokenhash: hash(token),
+	})
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return "", ErrAlreadyClaimed
+	}
+	return token, nil
+}
+
+// Claimed reports whether address has an owner.
+func Claimed(ctx context.Context, queries db.Querier, address string) (bool, error) {
+	_, err := queries.GetAddressClaim(ctx, address)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Check returns nil when address is unclaimed or token belongs to it, and
+// ErrMissingToken or ErrInvalidToken otherwise.
+func Check(ctx context.Context, queries db.Querier, address, token string) error {
+	claim, err := queries.GetAddressClaim(ctx, address)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return ErrMissingToken
+	}
+	if subtle.ConstantTimeCompare([]byte(hash(token)), []byte(claim.Tokenhash)) != 1 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
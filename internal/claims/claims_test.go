@@ -0,0 +1,55 @@
+package claims_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/pageton/temp-mail/internal/claims"
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/storage/storagetest"
+)
+
+func TestClaim(t *testing.T) {
+	ctx := context.Background()
+	queries, _ := storagetest.SQLite(t)
+
+	token, err := claims.Claim(ctx, queries, "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := claims.Check(ctx, queries, "alice@example.com", token); err != nil {
+		t.Errorf("Check with the token = %v", err)
+	}
+	if err := claims.Check(ctx, queries, "alice@example.com", "wrong"); !errors.Is(err, claims.ErrInvalidToken) {
+		t.Errorf("Check with a wrong token = %v, want ErrInvalidToken", err)
+	}
+	if _, err := claims.Claim(ctx, queries, "alice@example.com"); !errors.Is(err, claims.ErrAlreadyClaimed) {
+		t.Errorf("second Claim = %v, want ErrAlreadyClaimed", err)
+	}
+}
+
+func TestClaimAddressWithMail(t *testing.T) {
+	ctx := context.Background()
+	queries, _ := storagetest.SQLite(t)
+	emailID, err := queries.InsertEmail(ctx, db.InsertEmailParams{Createdat: 1, Expiresat: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = queries.InsertInbox(ctx, db.InsertInboxParams{
+		ID:      "inbox",
+		Emailid: sql.NullInt64{Int64: emailID, Valid: true},
+		Address: sql.NullString{String: "bob@example.com", Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := claims.Claim(ctx, queries, "bob@example.com"); !errors.Is(err, claims.ErrHasMail) {
+		t.Fatalf("Claim = %v, want ErrHasMail", err)
+	}
+	if claimed, err := claims.Claimed(ctx, queries, "bob@example.com"); err != nil || claimed {
+		t.Errorf("Claimed = %v, %v; want false", claimed, err)
+	}
+}
@@ -0,0 +1,277 @@
+// Package cleanup contains the removal of expired mail for the application.
+package cleanup
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucsky/cuid"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/pubsub"
+)
+
+// Result reports what one cleanup run removed.
+type Result struct {
+	Emails    int64
+	Inboxes   int64
+	Addresses int64
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+// leaseName names the lease held by the process running scheduled cleanups.
+const leaseName = "cleanup"
+
+// Cleaner deletes expired mail and generated addresses in batches and
+// publishes an expiry event for every removed inbox entry.
+type Cleaner struct {
+	queries   db.Querier
+	hub       *pubsub.Hub
+	batchSize int64
+	holder    string // Identifies this process in the lease
+
+	// Now returns the current time; replace it to run against a fake clock.
+	Now func() time.Time
+
+	mu sync.Mutex // serializes runs
+}
+
+func New(cfg *config.Config, queries db.Querier, hub *pubsub.Hub) *Cleaner {
+	batchSize := int64(cfg.Cleanup.BatchSize)
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	return &Cleaner{
+		queries:   queries,
+		hub:       hub,
+		batchSize: batchSize,
+		holder:    cuid.New(),
+		Now:       time.Now,
+	}
+}
+
+// Start runs the cleaner every interval until ctx is done. Replicas sharing
+// the database take turns through RunLeased, so one of them cleans up at a
+// time.
+func (c *Cleaner) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, _, err := c.RunLeased(ctx, 2*interval); err != nil {
+					log.Println("Error cleaning up expired mail:", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// RunLeased calls Run if this cleaner holds the cleanup lease, taking it when
+// it is free or has expired. The lease is held for ttl and renewed by every
+// run, so another replica takes over ttl after this one stops. It reports
+// whether Run was called.
+func (c *Cleaner) RunLeased(ctx context.Context, ttl time.Duration) (Result, bool, error) {
+	now := c.Now()
+	n, err := c.queries.InsertLease(ctx, db.InsertLeaseParams{
+		Name:      leaseName,
+		Holder:    c.holder,
+		ExpiresAt: now.Add(ttl).UnixMilli(),
+	})
+	if err == nil && n == 0 {
+		n, err = c.queries.TakeLease(ctx, db.TakeLeaseParams{
+			Holder:    c.holder,
+			ExpiresAt: now.Add(ttl).UnixMilli(),
+			Name:      leaseName,
+			Now:       now.UnixMilli(),
+		})
+	}
+	if err != nil {
+		return Result{}, false, fmt.Errorf("taking the cleanup lease: %w", err)
+	}
+	if n == 0 {
+		return Result{}, false, nil
+	}
+	result, err := c.Run(ctx)
+	return result, true, err
+}
+
+// Run removes everything that has expired by Now. Emails are deleted in
+// batches so that a large backlog does not hold the database lock for long.
+func (c *Cleaner) Run(ctx context.Context) (Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.Now()
+	result := Result{StartedAt: now}
+	for {
+		ids, err := c.queries.GetExpiredEmailIDs(ctx, db.GetExpiredEmailIDsParams{
+			Now:   now.UnixMilli(),
+			Limit: c.batchSize,
+		})
+		if err != nil {
+			return result, fmt.Errorf("getting expired emails: %w", err)
+		}
+		if len(ids) == 0 {
+			break
+		}
+		emailIDs := make([]sql.NullInt64, len(ids))
+		for i, id := range ids {
+			emailIDs[i] = sql.NullInt64{Int64: id, Valid: true}
+		}
+		inboxes, err := c.queries.GetInboxesByEmailIDs(ctx, emailIDs)
+		if err != nil {
+			return result, fmt.Errorf("getting expired inboxes: %w", err)
+		}
+		n, err := c.queries.DeleteEmailsByID(ctx, ids)
+		if err != nil {
+			return result, fmt.Errorf("deleting expired emails: %w", err)
+		}
+		result.Emails += n
+		result.Inboxes += int64(len(inboxes))
+		for _, inbox := range inboxes {
+			c.hub.Publish(pubsub.Event{
+				Type:    pubsub.EventExpired,
+				Address: inbox.Address.String,
+				InboxID: inbox.ID,
+			})
+		}
+		if int64(len(ids)) < c.batchSize {
+			break
+		}
+	}
+
+	n, err := c.queries.DeleteExpiredAddresses(ctx, now.UnixMilli())
+	if err != nil {
+		return result, fmt.Errorf("deleting expired addresses: %w", err)
+	}
+	result.Addresses = n
+
+	if _, err = c.queries.DeleteExpiredRateLimits(ctx, now.UnixMilli()); err != nil {
+		return result, fmt.Errorf("deleting expired rate limits: %w", err)
+	}
+	if _, err = c.queries.DeleteEventsBefore(ctx, now.Add(-pubsub.RelayRetention).UnixMilli()); err != nil {
+		return result, fmt.Errorf("deleting relayed events: %w", err)
+	}
+	result.Duration = c.Now().Sub(now)
+
+	// Stored with the lease, so that every process reports the same run.
+	payload, err := json.Marshal(result)
+	if err != nil {
+		return result, err
+	}
+	err = c.queries.SetLeaseResult(ctx, db.SetLeaseResultParams{
+		Name:   leaseName,
+		Result: sql.NullString{String: string(payload), Valid: true},
+	})
+	if err != nil {
+		return result, fmt.Errorf("storing the cleanup result: %w", err)
+	}
+	if result.Emails > 0 || result.Addresses > 0 {
+		log.Printf(
+			"Cleanup removed %d emails, %d inboxes and %d addresses in %s",
+			result.Emails, result.Inboxes, result.Addresses, result.Duration,
+		)
+	}
+	return result, nil
+}
+
+// Filter selects the mail removed by Purge. Every field that is set must
+// match; values are compared in lower case.
+type Filter struct {
+	Address string
+	Domain  string // Recipient domain
+	Sender  string // From address
+}
+
+// Purge removes the inbox entries matching filter, and with them the emails
+// that no other inbox entry refers to, in batches like Run. A deletion event
+// is published for every removed inbox entry.
+func (c *Cleaner) Purge(ctx context.Context, filter Filter) (Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	start := c.Now()
+	result := Result{StartedAt: start}
+	params := db.GetInboxesForPurgeParams{
+		Address: nullString(filter.Address),
+		Domain:  nullString(filter.Domain),
+		Sender:  nullString(filter.Sender),
+		Limit:   c.batchSize,
+	}
+	for {
+		inboxes, err := c.queries.GetInboxesForPurge(ctx, params)
+		if err != nil {
+			return result, fmt.Errorf("getting inboxes to purge: %w", err)
+		}
+		if len(inboxes) == 0 {
+			break
+		}
+		ids := make([]string, len(inboxes))
+		emailIDs := make([]int64, 0, len(inboxes))
+		for i, inbox := range inboxes {
+			ids[i] = inbox.ID
+			if inbox.Emailid.Valid {
+				emailIDs = append(emailIDs, inbox.Emailid.Int64)
+			}
+		}
+		n, err := c.queries.DeleteInboxesByID(ctx, ids)
+		if err != nil {
+			return result, fmt.Errorf("deleting inboxes: %w", err)
+		}
+		result.Inboxes += n
+		if len(emailIDs) > 0 {
+			n, err = c.queries.DeleteOrphanedEmails(ctx, emailIDs)
+			if err != nil {
+				return result, fmt.Errorf("deleting emails: %w", err)
+			}
+			result.Emails += n
+		}
+		for _, inbox := range inboxes {
+			c.hub.Publish(pubsub.Event{
+				Type:    pubsub.EventDeleted,
+				Address: inbox.Address.String,
+				InboxID: inbox.ID,
+			})
+		}
+		if int64(len(inboxes)) < c.batchSize {
+			break
+		}
+	}
+	result.Duration = c.Now().Sub(start)
+	return result, nil
+}
+
+func nullString(s string) sql.NullString {
+	s = strings.ToLower(s)
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// Last returns the result of the most recent successful run by any process
+// sharing the database, or nil.
+func (c *Cleaner) Last(ctx context.Context) (*Result, error) {
+	payload, err := c.queries.GetLeaseResult(ctx, leaseName)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !payload.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var result Result
+	if err := json.Unmarshal([]byte(payload.String), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,183 @@
+package cleanup_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/cleanup"
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/migrate"
+	"github.com/pageton/temp-mail/internal/pubsub"
+	"github.com/pageton/temp-mail/internal/storage"
+	"github.com/pageton/temp-mail/internal/storage/storagetest"
+)
+
+func insertInbox(t *testing.T, queries storage.Store, id string, expiresAt time.Time) {
+	t.Helper()
+	ctx := context.Background()
+	emailID, err := queries.InsertEmail(ctx, db.InsertEmailParams{
+		Createdat: expiresAt.Add(-time.Hour).UnixMilli(),
+		Expiresat: expiresAt.UnixMilli(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = queries.InsertInbox(ctx, db.InsertInboxParams{
+		ID:      id,
+		Emailid: sql.NullInt64{Int64: emailID, Valid: true},
+		Address: sql.NullString{String: "alice@example.com", Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunFakeClock(t *testing.T) {
+	ctx := context.Background()
+	queries, _ := storagetest.SQLite(t)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	insertInbox(t, queries, "early", now.Add(-time.Minute))
+	insertInbox(t, queries, "late", now.Add(time.Minute))
+
+	hub := pubsub.NewHub()
+	sub := hub.Subscribe("alice@example.com")
+	defer sub.Close()
+	cleaner := cleanup.New(&config.Config{Cleanup: config.CleanupConfig{BatchSize: 1}}, queries, hub)
+	cleaner.Now = func() time.Time { return now }
+	if last, err := cleaner.Last(ctx); err != nil || last != nil {
+		t.Fatalf("Last before the first run = %+v, %v; want nil", last, err)
+	}
+
+	result, err := cleaner.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Emails != 1 || result.Inboxes != 1 {
+		t.Fatalf("first run removed %d emails and %d inboxes, want 1 and 1", result.Emails, result.Inboxes)
+	}
+	if e := <-sub.Events(); e.Type != pubsub.EventExpired || e.InboxID != "early" {
+		t.Errorf("event = %+v, want expiry of early", e)
+	}
+	if _, err := queries.GetInboxByID(ctx, "late"); err != nil {
+		t.Errorf("late inbox removed before it expired: %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if result, err = cleaner.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if result.Emails != 1 {
+		t.Errorf("second run removed %d emails, want 1", result.Emails)
+	}
+	// Another process, such as a prefork child, reports the same run.
+	other := cleanup.New(&config.Config{}, queries, nil)
+	if last, err := other.Last(ctx); err != nil || last == nil || !last.StartedAt.Equal(now) || last.Emails != 1 {
+		t.Errorf("Last = %+v, %v; want the run started at %v", last, err, now)
+	}
+}
+
+// TestRunLegacyTimestamps checks that expiry times stored as DATETIME text
+// with different UTC offsets are compared as instants after migrating.
+func TestRunLegacyTimestamps(t *testing.T) {
+	ctx := context.Background()
+	queries, database, err := storage.Open(config.DatabaseConfig{
+		Path:        filepath.Join(t.TempDir(), "mail.db"),
+		BusyTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	migrations, err := migrate.Migrations(storage.BackendSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrate.GetStatus(ctx, database, storage.BackendSQLite); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.ExecContext(ctx, migrations[0].SQL); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.ExecContext(ctx, "INSERT INTO schema_version (version, appliedAt) VALUES (1, 0)"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 12:00 UTC is 14:00 at +02:00 and 07:00 at -05:00, so comparing the
+	// text would order these wrongly.
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	legacy := map[string]string{
+		"expired-east": "2026-01-01 13:59:00+02:00",
+		"expired-west": "2026-01-01 06:59:00.5-05:00",
+		"alive-west":   "2026-01-01 07:01:00-05:00",
+		"alive-utc":    "2026-01-01T12:01:00Z",
+	}
+	for id, expiresAt := range legacy {
+		res, err := database.ExecContext(ctx, "INSERT INTO Email (subject, expiresAt) VALUES (?, ?)", id, expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		emailID, _ := res.LastInsertId()
+		_, err = database.ExecContext(ctx, "INSERT INTO Inbox (id, emailId, address) VALUES (?, ?, ?)", id, emailID, "alice@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := migrate.Up(ctx, database, storage.BackendSQLite); err != nil {
+		t.Fatal(err)
+	}
+
+	cleaner := cleanup.New(&config.Config{}, queries, nil)
+	cleaner.Now = func() time.Time { return now }
+	result, err := cleaner.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Emails != 2 {
+		t.Errorf("removed %d emails, want 2", result.Emails)
+	}
+	for _, id := range []string{"alive-west", "alive-utc"} {
+		if _, err := queries.GetInboxByID(ctx, id); err != nil {
+			t.Errorf("%s removed: %v", id, err)
+		}
+	}
+	for _, id := range []string{"expired-east", "expired-west"} {
+		if _, err := queries.GetInboxByID(ctx, id); err == nil {
+			t.Errorf("%s not removed", id)
+		}
+	}
+}
+
+func TestRunLeased(t *testing.T) {
+	ctx := context.Background()
+	queries, _ := storagetest.SQLite(t)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	first := cleanup.New(&config.Config{}, queries, nil)
+	first.Now = clock
+	second := cleanup.New(&config.Config{}, queries, nil)
+	second.Now = clock
+
+	if _, ran, err := first.RunLeased(ctx, time.Minute); err != nil || !ran {
+		t.Fatalf("first RunLeased = %v, %v; want a run", ran, err)
+	}
+	if _, ran, err := second.RunLeased(ctx, time.Minute); err != nil || ran {
+		t.Fatalf("second RunLeased while leased = %v, %v; want no run", ran, err)
+	}
+	now = now.Add(30 * time.Second)
+	if _, ran, err := first.RunLeased(ctx, time.Minute); err != nil || !ran {
+		t.Fatalf("renewing RunLeased = %v, %v; want a run", ran, err)
+	}
+	now = now.Add(time.Minute)
+	if _, ran, err := second.RunLeased(ctx, time.Minute); err != nil || !ran {
+		t.Fatalf("second RunLeased after expiry = %v, %v; want a run", ran, err)
+	}
+	if _, ran, err := first.RunLeased(ctx, time.Minute); err != nil || ran {
+		t.Errorf("first RunLeased after takeover = %v, %v; want no run", ran, err)
+	}
+}
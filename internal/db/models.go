@@ -8,11 +8,56 @@ import (
 	"database/sql"
 )
 
+type Address struct {
+	Address   string
+	Strategy  string
+	Createdat int64
+	Expiresat int64
+}
+
+type Addressclaim struct {
+	Address   string
+	Tokenhash string
+	Createdat sql.NullInt64
+}
+
+type Addressretention struct {
+	Address   string
+	Retention int64
+}
+
+type Adminkey struct {
+	ID         string
+	Name       string
+	Keyhash    string
+	Createdat  int64
+	Lastusedat sql.NullInt64
+}
+
+type Attachment struct {
+	ID          string
+	Filename    sql.NullString
+	Contenttype sql.NullString
+	Size        int64
+	Contentid   sql.NullString
+	Disposition sql.NullString
+	Content     []byte
+	Emailid     sql.NullInt64
+}
+
+type Domain struct {
+	Name      string
+	Status    string
+	Metadata  string
+	Createdat int64
+	Updatedat int64
+}
+
 type Email struct {
 	ID        int64
 	Subject   sql.NullString
-	Createdat sql.NullInt64
-	Expiresat sql.NullTime
+	Createdat int64
+	Expiresat int64
 }
 
 type Emailaddress struct {
@@ -22,6 +67,21 @@ type Emailaddress struct {
 	Emailid sql.NullInt64
 }
 
+type Emailsearch struct {
+	Address     string
+	Subject     string
+	Sender      string
+	Textcontent string
+	Htmltext    string
+}
+
+type Event struct {
+	ID        int64
+	Origin    string
+	Payload   string
+	Createdat int64
+}
+
 type Inbox struct {
 	ID          string
 	Address     sql.NullString
@@ -30,3 +90,48 @@ type Inbox struct {
 	Createdat   sql.NullInt64
 	Emailid     sql.NullInt64
 }
+
+type Lease struct {
+	Name      string
+	Holder    string
+	Expiresat int64
+	Result    sql.NullString
+}
+
+type Ratelimit struct {
+	Key         string
+	Hits        int64
+	Windowstart int64
+	Expiresat   int64
+}
+
+type Rawmessage struct {
+	Emailid     int64
+	Compression string
+	Size        int64
+	Content     []byte
+}
+
+type Webhook struct {
+	ID          string
+	Url         string
+	Secret      string
+	Pattern     string
+	Includebody bool
+	Createdat   sql.NullInt64
+	Tokenhash   sql.NullString
+}
+
+type Webhookdelivery struct {
+	ID             int64
+	Inboxid        string
+	Payload        string
+	Status         string
+	Attempts       int64
+	Responsestatus sql.NullInt64
+	Lasterror      sql.NullString
+	Nextattemptat  int64
+	Deliveredat    sql.NullInt64
+	Createdat      sql.NullInt64
+	Webhookid      string
+}
@@ -0,0 +1,1736 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: queries.sql
+
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+const addressInUse = `-- name: AddressInUse :one
+SELECT EXISTS (SELECT 1 FROM Address WHERE Address.address = $1)
+  OR EXISTS (SELECT 1 FROM Inbox WHERE Inbox.address = $1)
+  OR EXISTS (SELECT 1 FROM AddressClaim WHERE AddressClaim.address = $1)
+`
+
+func (q *Queries) AddressInUse(ctx context.Context, address string) (sql.NullBool, error) {
+	row := q.db.QueryRowContext(ctx, addressInUse, address)
+	var column_1 sql.NullBool
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const countInboxesForAddress = `-- name: CountInboxesForAddress :one
+SELECT COUNT(*) FROM Inbox WHERE address = $1
+`
+
+func (q *Queries) CountInboxesForAddress(ctx context.Context, address sql.NullString) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countInboxesForAddress, address)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteAdminKey = `-- name: DeleteAdminKey :execrows
+DELETE FROM AdminKey WHERE id = $1
+`
+
+func (q *Queries) DeleteAdminKey(ctx context.Context, id string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteAdminKey, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteByInboxID = `-- name: DeleteByInboxID :exec
+DELETE FROM Inbox WHERE id = $1
+`
+
+func (q *Queries) DeleteByInboxID(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, deleteByInboxID, id)
+	return err
+}
+
+const deleteDomain = `-- name: DeleteDomain :execrows
+DELETE FROM Domain WHERE name = $1
+`
+
+func (q *Queries) DeleteDomain(ctx context.Context, name string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteDomain, name)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteEmailsByID = `-- name: DeleteEmailsByID :execrows
+DELETE FROM Email WHERE id = ANY($1::bigint[])
+`
+
+func (q *Queries) DeleteEmailsByID(ctx context.Context, ids []int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteEmailsByID, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteEventsBefore = `-- name: DeleteEventsBefore :execrows
+DELETE FROM Event WHERE createdAt < $1
+`
+
+func (q *Queries) DeleteEventsBefore(ctx context.Context, before int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteEventsBefore, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteExpiredAddresses = `-- name: DeleteExpiredAddresses :execrows
+DELETE FROM Address WHERE expiresAt <= $1
+`
+
+func (q *Queries) DeleteExpiredAddresses(ctx context.Context, expiresat int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredAddresses, expiresat)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteExpiredRateLimits = `-- name: DeleteExpiredRateLimits :execrows
+DELETE FROM RateLimit WHERE expiresAt <= $1
+`
+
+func (q *Queries) DeleteExpiredRateLimits(ctx context.Context, expiresat int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredRateLimits, expiresat)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteInboxesByID = `-- name: DeleteInboxesByID :execrows
+DELETE FROM Inbox WHERE id = ANY($1::text[])
+`
+
+func (q *Queries) DeleteInboxesByID(ctx context.Context, ids []string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteInboxesByID, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteOrphanedEmails = `-- name: DeleteOrphanedEmails :execrows
+DELETE FROM Email
+WHERE id = ANY($1::bigint[])
+  AND NOT EXISTS (SELECT 1 FROM Inbox WHERE Inbox.emailId = Email.id)
+`
+
+func (q *Queries) DeleteOrphanedEmails(ctx context.Context, ids []int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteOrphanedEmails, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :execrows
+DELETE FROM Webhook WHERE id = $1
+`
+
+func (q *Queries) DeleteWebhook(ctx context.Context, id string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteWebhook, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getActiveDomainNames = `-- name: GetActiveDomainNames :many
+SELECT name FROM Domain
+WHERE status = 'active'
+ORDER BY createdAt, name
+`
+
+func (q *Queries) GetActiveDomainNames(ctx context.Context) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getActiveDomainNames)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		items = append(items, name)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAddress = `-- name: GetAddress :one
+SELECT address, strategy, createdAt, expiresAt
+FROM Address
+WHERE address = $1
+`
+
+func (q *Queries) GetAddress(ctx context.Context, address string) (Address, error) {
+	row := q.db.QueryRowContext(ctx, getAddress, address)
+	var i Address
+	err := row.Scan(
+		&i.Address,
+		&i.Strategy,
+		&i.Createdat,
+		&i.Expiresat,
+	)
+	return i, err
+}
+
+const getAddressClaim = `-- name: GetAddressClaim :one
+SELECT address, tokenHash, createdAt
+FROM AddressClaim
+WHERE address = $1
+`
+
+func (q *Queries) GetAddressClaim(ctx context.Context, address string) (Addressclaim, error) {
+	row := q.db.QueryRowContext(ctx, getAddressClaim, address)
+	var i Addressclaim
+	err := row.Scan(&i.Address, &i.Tokenhash, &i.Createdat)
+	return i, err
+}
+
+const getAddressRetention = `-- name: GetAddressRetention :one
+SELECT retention FROM AddressRetention WHERE address = $1
+`
+
+func (q *Queries) GetAddressRetention(ctx context.Context, address string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getAddressRetention, address)
+	var retention int64
+	err := row.Scan(&retention)
+	return retention, err
+}
+
+const getAddressSummaries = `-- name: GetAddressSummaries :many
+SELECT
+  Inbox.address,
+  COUNT(*) AS messages,
+  MAX(Email.createdAt)::bigint AS lastReceivedAt
+FROM Inbox
+JOIN Email ON Inbox.emailId = Email.id
+WHERE Inbox.address IS NOT NULL
+  AND (Inbox.address LIKE '%@' || $1::text OR $1::text IS NULL)
+GROUP BY Inbox.address
+ORDER BY lastReceivedAt DESC, Inbox.address
+LIMIT $3::bigint OFFSET $2::bigint
+`
+
+type GetAddressSummariesParams struct {
+	Domain sql.NullString
+	Offset int64
+	Limit  int64
+}
+
+type GetAddressSummariesRow struct {
+	Address        sql.NullString
+	Messages       int64
+	Lastreceivedat int64
+}
+
+func (q *Queries) GetAddressSummaries(ctx context.Context, arg GetAddressSummariesParams) ([]GetAddressSummariesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getAddressSummaries, arg.Domain, arg.Offset, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetAddressSummariesRow
+	for rows.Next() {
+		var i GetAddressSummariesRow
+		if err := rows.Scan(&i.Address, &i.Messages, &i.Lastreceivedat); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAdminKeyByHash = `-- name: GetAdminKeyByHash :one
+SELECT id, name, createdAt, lastUsedAt
+FROM AdminKey
+WHERE keyHash = $1
+`
+
+type GetAdminKeyByHashRow struct {
+	ID         string
+	Name       string
+	Createdat  int64
+	Lastusedat sql.NullInt64
+}
+
+func (q *Queries) GetAdminKeyByHash(ctx context.Context, keyhash string) (GetAdminKeyByHashRow, error) {
+	row := q.db.QueryRowContext(ctx, getAdminKeyByHash, keyhash)
+	var i GetAdminKeyByHashRow
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Createdat,
+		&i.Lastusedat,
+	)
+	return i, err
+}
+
+const getAdminKeys = `-- name: GetAdminKeys :many
+SELECT id, name, createdAt, lastUsedAt
+FROM AdminKey
+ORDER BY createdAt
+`
+
+type GetAdminKeysRow struct {
+	ID         string
+	Name       string
+	Createdat  int64
+	Lastusedat sql.NullInt64
+}
+
+func (q *Queries) GetAdminKeys(ctx context.Context) ([]GetAdminKeysRow, error) {
+	rows, err := q.db.QueryContext(ctx, getAdminKeys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetAdminKeysRow
+	for rows.Next() {
+		var i GetAdminKeysRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Createdat,
+			&i.Lastusedat,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAttachmentForInbox = `-- name: GetAttachmentForInbox :one
+SELECT
+  Attachment.id,
+  Attachment.filename,
+  Attachment.contentType,
+  Attachment.size,
+  Attachment.disposition,
+  Attachment.content
+FROM Attachment
+JOIN Inbox ON Attachment.emailId = Inbox.emailId
+WHERE Inbox.id = $1 AND Attachment.id = $2
+`
+
+type GetAttachmentForInboxParams struct {
+	InboxID      string
+	AttachmentID string
+}
+
+type GetAttachmentForInboxRow struct {
+	ID          string
+	Filename    sql.NullString
+	Contenttype sql.NullString
+	Size        int64
+	Disposition sql.NullString
+	Content     []byte
+}
+
+func (q *Queries) GetAttachmentForInbox(ctx context.Context, arg GetAttachmentForInboxParams) (GetAttachmentForInboxRow, error) {
+	row := q.db.QueryRowContext(ctx, getAttachmentForInbox, arg.InboxID, arg.AttachmentID)
+	var i GetAttachmentForInboxRow
+	err := row.Scan(
+		&i.ID,
+		&i.Filename,
+		&i.Contenttype,
+		&i.Size,
+		&i.Disposition,
+		&i.Content,
+	)
+	return i, err
+}
+
+const getAttachmentsByInboxID = `-- name: GetAttachmentsByInboxID :many
+SELECT
+  Attachment.id,
+  Attachment.filename,
+  Attachment.contentType,
+  Attachment.size,
+  Attachment.contentId,
+  Attachment.disposition
+FROM Attachment
+JOIN Inbox ON Attachment.emailId = Inbox.emailId
+WHERE Inbox.id = $1
+ORDER BY Attachment.seq
+`
+
+type GetAttachmentsByInboxIDRow struct {
+	ID          string
+	Filename    sql.NullString
+	Contenttype sql.NullString
+	Size        int64
+	Contentid   sql.NullString
+	Disposition sql.NullString
+}
+
+func (q *Queries) GetAttachmentsByInboxID(ctx context.Context, id string) ([]GetAttachmentsByInboxIDRow, error) {
+	rows, err := q.db.QueryContext(ctx, getAttachmentsByInboxID, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetAttachmentsByInboxIDRow
+	for rows.Next() {
+		var i GetAttachmentsByInboxIDRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Filename,
+			&i.Contenttype,
+			&i.Size,
+			&i.Contentid,
+			&i.Disposition,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDomain = `-- name: GetDomain :one
+SELECT name, status, metadata, createdAt, updatedAt
+FROM Domain
+WHERE name = $1
+`
+
+func (q *Queries) GetDomain(ctx context.Context, name string) (Domain, error) {
+	row := q.db.QueryRowContext(ctx, getDomain, name)
+	var i Domain
+	err := row.Scan(
+		&i.Name,
+		&i.Status,
+		&i.Metadata,
+		&i.Createdat,
+		&i.Updatedat,
+	)
+	return i, err
+}
+
+const getDomainList = `-- name: GetDomainList :many
+SELECT name, status, metadata, createdAt, updatedAt
+FROM Domain
+ORDER BY name
+`
+
+func (q *Queries) GetDomainList(ctx context.Context) ([]Domain, error) {
+	rows, err := q.db.QueryContext(ctx, getDomainList)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Domain
+	for rows.Next() {
+		var i Domain
+		if err := rows.Scan(
+			&i.Name,
+			&i.Status,
+			&i.Metadata,
+			&i.Createdat,
+			&i.Updatedat,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDueWebhookDeliveries = `-- name: GetDueWebhookDeliveries :many
+
+WITH due AS (
+  SELECT WebhookDelivery.id FROM WebhookDelivery
+  WHERE WebhookDelivery.status = 'pending' AND WebhookDelivery.nextAttemptAt <= $1
+  ORDER BY WebhookDelivery.nextAttemptAt
+  LIMIT $2::bigint
+  FOR UPDATE SKIP LOCKED
+), leased AS (
+  UPDATE WebhookDelivery SET nextAttemptAt = $1 + 300000
+  FROM due
+  WHERE WebhookDelivery.id = due.id
+  RETURNING WebhookDelivery.id, WebhookDelivery.payload, WebhookDelivery.attempts, WebhookDelivery.webhookId
+)
+SELECT
+  leased.id,
+  leased.payload,
+  leased.attempts,
+  Webhook.url,
+  Webhook.secret
+FROM leased
+JOIN Webhook ON leased.webhookId = Webhook.id
+`
+
+type GetDueWebhookDeliveriesParams struct {
+	Nextattemptat int64
+	Limit         int64
+}
+
+type GetDueWebhookDeliveriesRow struct {
+	ID       int64
+	Payload  string
+	Attempts int64
+	Url      string
+	Secret   string
+}
+
+// Due deliveries are leased for five minutes by pushing nextAttemptAt
+// forward, so replicas sharing the database do not post them twice.
+func (q *Queries) GetDueWebhookDeliveries(ctx context.Context, arg GetDueWebhookDeliveriesParams) ([]GetDueWebhookDeliveriesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getDueWebhookDeliveries, arg.Nextattemptat, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetDueWebhookDeliveriesRow
+	for rows.Next() {
+		var i GetDueWebhookDeliveriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Payload,
+			&i.Attempts,
+			&i.Url,
+			&i.Secret,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getEmailRecipients = `-- name: GetEmailRecipients :many
+SELECT address FROM Inbox WHERE emailId = $1
+`
+
+func (q *Queries) GetEmailRecipients(ctx context.Context, emailID sql.NullInt64) ([]sql.NullString, error) {
+	rows, err := q.db.QueryContext(ctx, getEmailRecipients, emailID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []sql.NullString
+	for rows.Next() {
+		var address sql.NullString
+		if err := rows.Scan(&address); err != nil {
+			return nil, err
+		}
+		items = append(items, address)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getEmailTimesForAddress = `-- name: GetEmailTimesForAddress :many
+SELECT Email.id, Email.createdAt
+FROM Email
+JOIN Inbox ON Email.id = Inbox.emailId
+WHERE Inbox.address = $1
+`
+
+type GetEmailTimesForAddressRow struct {
+	ID        int64
+	Createdat int64
+}
+
+func (q *Queries) GetEmailTimesForAddress(ctx context.Context, address sql.NullString) ([]GetEmailTimesForAddressRow, error) {
+	rows, err := q.db.QueryContext(ctx, getEmailTimesForAddress, address)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetEmailTimesForAddressRow
+	for rows.Next() {
+		var i GetEmailTimesForAddressRow
+		if err := rows.Scan(&i.ID, &i.Createdat); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getEmailsForAddress = `-- name: GetEmailsForAddress :many
+SELECT
+  Inbox.id,
+  Email.id as emailId,
+  Email.subject,
+  Email.createdAt,
+  Email.expiresAt,
+  (SELECT address FROM EmailAddress WHERE emailId = Email.id AND type = 'from' LIMIT 1) as fromAddress,
+  COALESCE((SELECT string_agg(address, ', ') FROM EmailAddress WHERE emailId = Email.id AND type = 'to'), '')::text as toAddress
+FROM Email
+JOIN Inbox ON Email.id = Inbox.emailId
+WHERE Inbox.address = $1
+  AND (Email.createdAt >= $2 OR $2 IS NULL)
+  AND (Email.createdAt < $3 OR $3 IS NULL)
+  AND ((Email.subject ILIKE '%' || $4::text || '%' ESCAPE '\') OR $4::text IS NULL)
+  AND (EXISTS (
+    SELECT 1 FROM EmailAddress
+    WHERE emailId = Email.id AND type = 'from' AND (address ILIKE '%' || $5::text || '%' ESCAPE '\')
+  ) OR $5::text IS NULL)
+  AND (Email.createdAt < $6
+    OR (Email.createdAt = $6 AND Email.id < $7)
+    OR $6 IS NULL)
+ORDER BY Email.createdAt DESC, Email.id DESC
+LIMIT $8::bigint
+`
+
+type GetEmailsForAddressParams struct {
+	Address         sql.NullString
+	Since           sql.NullInt64
+	Until           sql.NullInt64
+	Subject         sql.NullString
+	FromAddress     sql.NullString
+	CursorCreatedAt sql.NullInt64
+	CursorID        sql.NullInt64
+	Limit           int64
+}
+
+type GetEmailsForAddressRow struct {
+	ID          string
+	Emailid     int64
+	Subject     sql.NullString
+	Createdat   int64
+	Expiresat   int64
+	Fromaddress sql.NullString
+	Toaddress   string
+}
+
+func (q *Queries) GetEmailsForAddress(ctx context.Context, arg GetEmailsForAddressParams) ([]GetEmailsForAddressRow, error) {
+	rows, err := q.db.QueryContext(ctx, getEmailsForAddress,
+		arg.Address,
+		arg.Since,
+		arg.Until,
+		arg.Subject,
+		arg.FromAddress,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetEmailsForAddressRow
+	for rows.Next() {
+		var i GetEmailsForAddressRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Emailid,
+			&i.Subject,
+			&i.Createdat,
+			&i.Expiresat,
+			&i.Fromaddress,
+			&i.Toaddress,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getEventsAfter = `-- name: GetEventsAfter :many
+SELECT id, origin, payload FROM Event
+WHERE id > $1
+ORDER BY id
+LIMIT $2::bigint
+`
+
+type GetEventsAfterParams struct {
+	After int64
+	Limit int64
+}
+
+type GetEventsAfterRow struct {
+	ID      int64
+	Origin  string
+	Payload string
+}
+
+func (q *Queries) GetEventsAfter(ctx context.Context, arg GetEventsAfterParams) ([]GetEventsAfterRow, error) {
+	rows, err := q.db.QueryContext(ctx, getEventsAfter, arg.After, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetEventsAfterRow
+	for rows.Next() {
+		var i GetEventsAfterRow
+		if err := rows.Scan(&i.ID, &i.Origin, &i.Payload); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getExpiredEmailIDs = `-- name: GetExpiredEmailIDs :many
+SELECT id FROM Email
+WHERE expiresAt <= $1
+ORDER BY expiresAt
+LIMIT $2::bigint
+`
+
+type GetExpiredEmailIDsParams struct {
+	Now   int64
+	Limit int64
+}
+
+func (q *Queries) GetExpiredEmailIDs(ctx context.Context, arg GetExpiredEmailIDsParams) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, getExpiredEmailIDs, arg.Now, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getInboxByID = `-- name: GetInboxByID :one
+SELECT
+  Inbox.id,
+  Inbox.address,
+  Inbox.textContent,
+  Inbox.htmlContent,
+  Email.subject,
+  Email.expiresAt,
+  Email.createdAt,
+  (SELECT address FROM EmailAddress WHERE emailId = Email.id AND type = 'from' LIMIT 1) as fromAddress,
+  COALESCE((SELECT string_agg(address, ', ') FROM EmailAddress WHERE emailId = Email.id AND type = 'to'), '')::text as toAddress
+FROM Inbox
+JOIN Email ON Inbox.emailId = Email.id
+WHERE Inbox.id = $1
+`
+
+type GetInboxByIDRow struct {
+	ID          string
+	Address     sql.NullString
+	Textcontent sql.NullString
+	Htmlcontent sql.NullString
+	Subject     sql.NullString
+	Expiresat   int64
+	Createdat   int64
+	Fromaddress sql.NullString
+	Toaddress   string
+}
+
+func (q *Queries) GetInboxByID(ctx context.Context, id string) (GetInboxByIDRow, error) {
+	row := q.db.QueryRowContext(ctx, getInboxByID, id)
+	var i GetInboxByIDRow
+	err := row.Scan(
+		&i.ID,
+		&i.Address,
+		&i.Textcontent,
+		&i.Htmlcontent,
+		&i.Subject,
+		&i.Expiresat,
+		&i.Createdat,
+		&i.Fromaddress,
+		&i.Toaddress,
+	)
+	return i, err
+}
+
+const getInboxRecipients = `-- name: GetInboxRecipients :many
+SELECT other.address
+FROM Inbox
+JOIN Inbox AS other ON other.emailId = Inbox.emailId
+WHERE Inbox.id = $1
+`
+
+func (q *Queries) GetInboxRecipients(ctx context.Context, inboxID string) ([]sql.NullString, error) {
+	rows, err := q.db.QueryContext(ctx, getInboxRecipients, inboxID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []sql.NullString
+	for rows.Next() {
+		var address sql.NullString
+		if err := rows.Scan(&address); err != nil {
+			return nil, err
+		}
+		items = append(items, address)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getInboxesByEmailIDs = `-- name: GetInboxesByEmailIDs :many
+SELECT id, address FROM Inbox
+WHERE emailId = ANY($1::bigint[])
+`
+
+type GetInboxesByEmailIDsRow struct {
+	ID      string
+	Address sql.NullString
+}
+
+func (q *Queries) GetInboxesByEmailIDs(ctx context.Context, emailIds []int64) ([]GetInboxesByEmailIDsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getInboxesByEmailIDs, pq.Array(emailIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetInboxesByEmailIDsRow
+	for rows.Next() {
+		var i GetInboxesByEmailIDsRow
+		if err := rows.Scan(&i.ID, &i.Address); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getInboxesForPurge = `-- name: GetInboxesForPurge :many
+SELECT Inbox.id, Inbox.address, Inbox.emailId
+FROM Inbox
+WHERE (Inbox.address = $1::text OR $1::text IS NULL)
+  AND (Inbox.address LIKE '%@' || $2::text OR $2::text IS NULL)
+  AND (EXISTS (
+    SELECT 1 FROM EmailAddress
+    WHERE EmailAddress.emailId = Inbox.emailId AND EmailAddress.type = 'from'
+      AND LOWER(EmailAddress.address) = $3::text
+  ) OR $3::text IS NULL)
+LIMIT $4::bigint
+`
+
+type GetInboxesForPurgeParams struct {
+	Address sql.NullString
+	Domain  sql.NullString
+	Sender  sql.NullString
+	Limit   int64
+}
+
+type GetInboxesForPurgeRow struct {
+	ID      string
+	Address sql.NullString
+	Emailid sql.NullInt64
+}
+
+func (q *Queries) GetInboxesForPurge(ctx context.Context, arg GetInboxesForPurgeParams) ([]GetInboxesForPurgeRow, error) {
+	rows, err := q.db.QueryContext(ctx, getInboxesForPurge,
+		arg.Address,
+		arg.Domain,
+		arg.Sender,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetInboxesForPurgeRow
+	for rows.Next() {
+		var i GetInboxesForPurgeRow
+		if err := rows.Scan(&i.ID, &i.Address, &i.Emailid); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLastEventID = `-- name: GetLastEventID :one
+SELECT CAST(COALESCE(MAX(id), 0) AS BIGINT) FROM Event
+`
+
+func (q *Queries) GetLastEventID(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getLastEventID)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const getLeaseResult = `-- name: GetLeaseResult :one
+SELECT result FROM Lease WHERE name = $1
+`
+
+func (q *Queries) GetLeaseResult(ctx context.Context, name string) (sql.NullString, error) {
+	row := q.db.QueryRowContext(ctx, getLeaseResult, name)
+	var result sql.NullString
+	err := row.Scan(&result)
+	return result, err
+}
+
+const getNextEmailForAddress = `-- name: GetNextEmailForAddress :one
+SELECT
+  Inbox.id,
+  Email.id as emailId,
+  Email.subject,
+  Email.createdAt,
+  Email.expiresAt,
+  (SELECT address FROM EmailAddress WHERE emailId = Email.id AND type = 'from' LIMIT 1) as fromAddress,
+  COALESCE((SELECT string_agg(address, ', ') FROM EmailAddress WHERE emailId = Email.id AND type = 'to'), '')::text as toAddress
+FROM Email
+JOIN Inbox ON Email.id = Inbox.emailId
+WHERE Inbox.address = $1
+  AND (Email.createdAt > $2
+    OR (Email.createdAt = $2 AND Email.id > $3))
+  AND ((Email.subject ILIKE '%' || $4::text || '%' ESCAPE '\') OR $4::text IS NULL)
+  AND (EXISTS (
+    SELECT 1 FROM EmailAddress
+    WHERE emailId = Email.id AND type = 'from' AND (address ILIKE '%' || $5::text || '%' ESCAPE '\')
+  ) OR $5::text IS NULL)
+ORDER BY Email.createdAt ASC, Email.id ASC
+LIMIT 1
+`
+
+type GetNextEmailForAddressParams struct {
+	Address        sql.NullString
+	AfterCreatedAt int64
+	AfterID        int64
+	Subject        sql.NullString
+	FromAddress    sql.NullString
+}
+
+type GetNextEmailForAddressRow struct {
+	ID          string
+	Emailid     int64
+	Subject     sql.NullString
+	Createdat   int64
+	Expiresat   int64
+	Fromaddress sql.NullString
+	Toaddress   string
+}
+
+func (q *Queries) GetNextEmailForAddress(ctx context.Context, arg GetNextEmailForAddressParams) (GetNextEmailForAddressRow, error) {
+	row := q.db.QueryRowContext(ctx, getNextEmailForAddress,
+		arg.Address,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.Subject,
+		arg.FromAddress,
+	)
+	var i GetNextEmailForAddressRow
+	err := row.Scan(
+		&i.ID,
+		&i.Emailid,
+		&i.Subject,
+		&i.Createdat,
+		&i.Expiresat,
+		&i.Fromaddress,
+		&i.Toaddress,
+	)
+	return i, err
+}
+
+const getRawMessageByInboxID = `-- name: GetRawMessageByInboxID :one
+SELECT
+  RawMessage.compression,
+  RawMessage.size,
+  RawMessage.content
+FROM RawMessage
+JOIN Inbox ON RawMessage.emailId = Inbox.emailId
+WHERE Inbox.id = $1
+`
+
+type GetRawMessageByInboxIDRow struct {
+	Compression string
+	Size        int64
+	Content     []byte
+}
+
+func (q *Queries) GetRawMessageByInboxID(ctx context.Context, id string) (GetRawMessageByInboxIDRow, error) {
+	row := q.db.QueryRowContext(ctx, getRawMessageByInboxID, id)
+	var i GetRawMessageByInboxIDRow
+	err := row.Scan(&i.Compression, &i.Size, &i.Content)
+	return i, err
+}
+
+const getStats = `-- name: GetStats :one
+SELECT
+  (SELECT COUNT(*) FROM Email)::bigint AS emails,
+  (SELECT COUNT(*) FROM Inbox)::bigint AS inboxes,
+  (SELECT COUNT(DISTINCT address) FROM Inbox)::bigint AS addresses,
+  (SELECT COUNT(*) FROM Address)::bigint AS generatedAddresses,
+  (SELECT COUNT(*) FROM AddressClaim)::bigint AS claimedAddresses,
+  (SELECT COUNT(*) FROM Attachment)::bigint AS attachments,
+  (SELECT COALESCE(SUM(size), 0) FROM Attachment)::bigint AS attachmentBytes,
+  (SELECT COALESCE(SUM(size), 0) FROM RawMessage)::bigint AS rawBytes,
+  (SELECT COUNT(*) FROM Webhook)::bigint AS webhooks,
+  (SELECT COUNT(*) FROM WebhookDelivery WHERE status = 'pending')::bigint AS pendingDeliveries,
+  (SELECT COUNT(*) FROM WebhookDelivery WHERE status = 'failed')::bigint AS failedDeliveries,
+  (SELECT COALESCE(MIN(createdAt), 0) FROM Email)::bigint AS oldestEmailAt,
+  (SELECT COALESCE(MAX(createdAt), 0) FROM Email)::bigint AS newestEmailAt
+`
+
+type GetStatsRow struct {
+	Emails             int64
+	Inboxes            int64
+	Addresses          int64
+	Generatedaddresses int64
+	Claimedaddresses   int64
+	Attachments        int64
+	Attachmentbytes    int64
+	Rawbytes           int64
+	Webhooks           int64
+	Pendingdeliveries  int64
+	Faileddeliveries   int64
+	Oldestemailat      int64
+	Newestemailat      int64
+}
+
+func (q *Queries) GetStats(ctx context.Context) (GetStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getStats)
+	var i GetStatsRow
+	err := row.Scan(
+		&i.Emails,
+		&i.Inboxes,
+		&i.Addresses,
+		&i.Generatedaddresses,
+		&i.Claimedaddresses,
+		&i.Attachments,
+		&i.Attachmentbytes,
+		&i.Rawbytes,
+		&i.Webhooks,
+		&i.Pendingdeliveries,
+		&i.Faileddeliveries,
+		&i.Oldestemailat,
+		&i.Newestemailat,
+	)
+	return i, err
+}
+
+const getWebhook = `-- name: GetWebhook :one
+SELECT id, url, secret, pattern, includeBody, createdAt, tokenHash
+FROM Webhook
+WHERE id = $1
+`
+
+func (q *Queries) GetWebhook(ctx context.Context, id string) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, getWebhook, id)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Secret,
+		&i.Pattern,
+		&i.Includebody,
+		&i.Createdat,
+		&i.Tokenhash,
+	)
+	return i, err
+}
+
+const getWebhookDeliveries = `-- name: GetWebhookDeliveries :many
+SELECT id, inboxId, status, attempts, responseStatus, lastError, nextAttemptAt, deliveredAt, createdAt
+FROM WebhookDelivery
+WHERE webhookId = $1
+ORDER BY id DESC
+LIMIT $2::bigint
+`
+
+type GetWebhookDeliveriesParams struct {
+	Webhookid string
+	Limit     int64
+}
+
+type GetWebhookDeliveriesRow struct {
+	ID             int64
+	Inboxid        string
+	Status         string
+	Attempts       int64
+	Responsestatus sql.NullInt64
+	Lasterror      sql.NullString
+	Nextattemptat  int64
+	Deliveredat    sql.NullInt64
+	Createdat      sql.NullInt64
+}
+
+func (q *Queries) GetWebhookDeliveries(ctx context.Context, arg GetWebhookDeliveriesParams) ([]GetWebhookDeliveriesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhookDeliveries, arg.Webhookid, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetWebhookDeliveriesRow
+	for rows.Next() {
+		var i GetWebhookDeliveriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Inboxid,
+			&i.Status,
+			&i.Attempts,
+			&i.Responsestatus,
+			&i.Lasterror,
+			&i.Nextattemptat,
+			&i.Deliveredat,
+			&i.Createdat,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhooks = `-- name: GetWebhooks :many
+SELECT id, url, secret, pattern, includeBody, createdAt, tokenHash
+FROM Webhook
+`
+
+func (q *Queries) GetWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhooks)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Secret,
+			&i.Pattern,
+			&i.Includebody,
+			&i.Createdat,
+			&i.Tokenhash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hitRateLimit = `-- name: HitRateLimit :one
+INSERT INTO RateLimit (key, hits, windowStart, expiresAt)
+VALUES ($1, 1, $2, $3)
+ON CONFLICT (key) DO UPDATE SET
+  hits = CASE WHEN RateLimit.expiresAt <= excluded.windowStart THEN 1 ELSE RateLimit.hits + 1 END,
+  windowStart = CASE WHEN RateLimit.expiresAt <= excluded.windowStart THEN excluded.windowStart ELSE RateLimit.windowStart END,
+  expiresAt = CASE WHEN RateLimit.expiresAt <= excluded.windowStart THEN excluded.expiresAt ELSE RateLimit.expiresAt END
+RETURNING hits, expiresAt
+`
+
+type HitRateLimitParams struct {
+	Key         string
+	WindowStart int64
+	ExpiresAt   int64
+}
+
+type HitRateLimitRow struct {
+	Hits      int64
+	Expiresat int64
+}
+
+// Counts a request against key and returns the count and end of the
+// current window. A window that has ended starts over.
+func (q *Queries) HitRateLimit(ctx context.Context, arg HitRateLimitParams) (HitRateLimitRow, error) {
+	row := q.db.QueryRowContext(ctx, hitRateLimit, arg.Key, arg.WindowStart, arg.ExpiresAt)
+	var i HitRateLimitRow
+	err := row.Scan(&i.Hits, &i.Expiresat)
+	return i, err
+}
+
+const insertAddress = `-- name: InsertAddress :execrows
+INSERT INTO Address (address, strategy, createdAt, expiresAt)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (address) DO NOTHING
+`
+
+type InsertAddressParams struct {
+	Address   string
+	Strategy  string
+	Createdat int64
+	Expiresat int64
+}
+
+func (q *Queries) InsertAddress(ctx context.Context, arg InsertAddressParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, insertAddress,
+		arg.Address,
+		arg.Strategy,
+		arg.Createdat,
+		arg.Expiresat,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const insertAddressClaim = `-- name: InsertAddressClaim :execrows
+INSERT INTO AddressClaim (address, tokenHash)
+VALUES ($1, $2)
+ON CONFLICT (address) DO NOTHING
+`
+
+type InsertAddressClaimParams struct {
+	Address   string
+	Tokenhash string
+}
+
+func (q *Queries) InsertAddressClaim(ctx context.Context, arg InsertAddressClaimParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, insertAddressClaim, arg.Address, arg.Tokenhash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const insertAdminKey = `-- name: InsertAdminKey :exec
+INSERT INTO AdminKey (id, name, keyHash, createdAt)
+VALUES ($1, $2, $3, $4)
+`
+
+type InsertAdminKeyParams struct {
+	ID        string
+	Name      string
+	Keyhash   string
+	Createdat int64
+}
+
+func (q *Queries) InsertAdminKey(ctx context.Context, arg InsertAdminKeyParams) error {
+	_, err := q.db.ExecContext(ctx, insertAdminKey,
+		arg.ID,
+		arg.Name,
+		arg.Keyhash,
+		arg.Createdat,
+	)
+	return err
+}
+
+const insertAttachment = `-- name: InsertAttachment :exec
+INSERT INTO Attachment (id, emailId, filename, contentType, size, contentId, disposition, content)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+`
+
+type InsertAttachmentParams struct {
+	ID          string
+	Emailid     sql.NullInt64
+	Filename    sql.NullString
+	Contenttype sql.NullString
+	Size        int64
+	Contentid   sql.NullString
+	Disposition sql.NullString
+	Content     []byte
+}
+
+func (q *Queries) InsertAttachment(ctx context.Context, arg InsertAttachmentParams) error {
+	_, err := q.db.ExecContext(ctx, insertAttachment,
+		arg.ID,
+		arg.Emailid,
+		arg.Filename,
+		arg.Contenttype,
+		arg.Size,
+		arg.Contentid,
+		arg.Disposition,
+		arg.Content,
+	)
+	return err
+}
+
+const insertDomain = `-- name: InsertDomain :execrows
+INSERT INTO Domain (name, status, metadata, createdAt, updatedAt)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (name) DO NOTHING
+`
+
+type InsertDomainParams struct {
+	Name      string
+	Status    string
+	Metadata  string
+	Createdat int64
+	Updatedat int64
+}
+
+func (q *Queries) InsertDomain(ctx context.Context, arg InsertDomainParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, insertDomain,
+		arg.Name,
+		arg.Status,
+		arg.Metadata,
+		arg.Createdat,
+		arg.Updatedat,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const insertEmail = `-- name: InsertEmail :one
+INSERT INTO Email (subject, createdAt, expiresAt)
+VALUES ($1, $2, $3)
+RETURNING id
+`
+
+type InsertEmailParams struct {
+	Subject   sql.NullString
+	Createdat int64
+	Expiresat int64
+}
+
+func (q *Queries) InsertEmail(ctx context.Context, arg InsertEmailParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, insertEmail, arg.Subject, arg.Createdat, arg.Expiresat)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const insertEmailAddress = `-- name: InsertEmailAddress :exec
+INSERT INTO EmailAddress (emailId, type, address)
+VALUES ($1, $2, $3)
+`
+
+type InsertEmailAddressParams struct {
+	Emailid sql.NullInt64
+	Type    sql.NullString
+	Address sql.NullString
+}
+
+func (q *Queries) InsertEmailAddress(ctx context.Context, arg InsertEmailAddressParams) error {
+	_, err := q.db.ExecContext(ctx, insertEmailAddress, arg.Emailid, arg.Type, arg.Address)
+	return err
+}
+
+const insertEvent = `-- name: InsertEvent :exec
+INSERT INTO Event (origin, payload, createdAt)
+VALUES ($1, $2, $3)
+`
+
+type InsertEventParams struct {
+	Origin    string
+	Payload   string
+	CreatedAt int64
+}
+
+func (q *Queries) InsertEvent(ctx context.Context, arg InsertEventParams) error {
+	_, err := q.db.ExecContext(ctx, insertEvent, arg.Origin, arg.Payload, arg.CreatedAt)
+	return err
+}
+
+const insertInbox = `-- name: InsertInbox :exec
+INSERT INTO Inbox (id, emailId, address, textContent, htmlContent)
+VALUES ($1, $2, $3, $4, $5)
+`
+
+type InsertInboxParams struct {
+	ID          string
+	Emailid     sql.NullInt64
+	Address     sql.NullString
+	Textcontent sql.NullString
+	Htmlcontent sql.NullString
+}
+
+func (q *Queries) InsertInbox(ctx context.Context, arg InsertInboxParams) error {
+	_, err := q.db.ExecContext(ctx, insertInbox,
+		arg.ID,
+		arg.Emailid,
+		arg.Address,
+		arg.Textcontent,
+		arg.Htmlcontent,
+	)
+	return err
+}
+
+const insertLease = `-- name: InsertLease :execrows
+INSERT INTO Lease (name, holder, expiresAt)
+VALUES ($1, $2, $3)
+ON CONFLICT (name) DO NOTHING
+`
+
+type InsertLeaseParams struct {
+	Name      string
+	Holder    string
+	ExpiresAt int64
+}
+
+func (q *Queries) InsertLease(ctx context.Context, arg InsertLeaseParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, insertLease, arg.Name, arg.Holder, arg.ExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const insertRawMessage = `-- name: InsertRawMessage :exec
+INSERT INTO RawMessage (emailId, compression, size, content)
+VALUES ($1, $2, $3, $4)
+`
+
+type InsertRawMessageParams struct {
+	Emailid     int64
+	Compression string
+	Size        int64
+	Content     []byte
+}
+
+func (q *Queries) InsertRawMessage(ctx context.Context, arg InsertRawMessageParams) error {
+	_, err := q.db.ExecContext(ctx, insertRawMessage,
+		arg.Emailid,
+		arg.Compression,
+		arg.Size,
+		arg.Content,
+	)
+	return err
+}
+
+const insertSearchEntry = `-- name: InsertSearchEntry :exec
+INSERT INTO EmailSearch (inboxId, address, subject, sender, textContent, htmlText)
+SELECT Inbox.id, COALESCE(Inbox.address, ''), $1::text, $2::text,
+  COALESCE(Inbox.textContent, ''), $3::text
+FROM Inbox
+WHERE Inbox.id = $4
+ON CONFLICT (inboxId) DO NOTHING
+`
+
+type InsertSearchEntryParams struct {
+	Subject  string
+	Sender   string
+	HtmlText string
+	InboxID  string
+}
+
+func (q *Queries) InsertSearchEntry(ctx context.Context, arg InsertSearchEntryParams) error {
+	_, err := q.db.ExecContext(ctx, insertSearchEntry,
+		arg.Subject,
+		arg.Sender,
+		arg.HtmlText,
+		arg.InboxID,
+	)
+	return err
+}
+
+const insertWebhook = `-- name: InsertWebhook :exec
+INSERT INTO Webhook (id, url, secret, pattern, includeBody, tokenHash)
+VALUES ($1, $2, $3, $4, $5, $6)
+`
+
+type InsertWebhookParams struct {
+	ID          string
+	Url         string
+	Secret      string
+	Pattern     string
+	Includebody bool
+	Tokenhash   sql.NullString
+}
+
+func (q *Queries) InsertWebhook(ctx context.Context, arg InsertWebhookParams) error {
+	_, err := q.db.ExecContext(ctx, insertWebhook,
+		arg.ID,
+		arg.Url,
+		arg.Secret,
+		arg.Pattern,
+		arg.Includebody,
+		arg.Tokenhash,
+	)
+	return err
+}
+
+const insertWebhookDelivery = `-- name: InsertWebhookDelivery :exec
+INSERT INTO WebhookDelivery (webhookId, inboxId, payload, nextAttemptAt)
+VALUES ($1, $2, $3, $4)
+`
+
+type InsertWebhookDeliveryParams struct {
+	Webhookid     string
+	Inboxid       string
+	Payload       string
+	Nextattemptat int64
+}
+
+func (q *Queries) InsertWebhookDelivery(ctx context.Context, arg InsertWebhookDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, insertWebhookDelivery,
+		arg.Webhookid,
+		arg.Inboxid,
+		arg.Payload,
+		arg.Nextattemptat,
+	)
+	return err
+}
+
+const leaseWebhookDeliveries = `-- name: LeaseWebhookDeliveries :exec
+UPDATE WebhookDelivery SET nextAttemptAt = $1
+WHERE id = ANY($2::bigint[])
+`
+
+type LeaseWebhookDeliveriesParams struct {
+	Until int64
+	Ids   []int64
+}
+
+func (q *Queries) LeaseWebhookDeliveries(ctx context.Context, arg LeaseWebhookDeliveriesParams) error {
+	_, err := q.db.ExecContext(ctx, leaseWebhookDeliveries, arg.Until, pq.Array(arg.Ids))
+	return err
+}
+
+const setLeaseResult = `-- name: SetLeaseResult :exec
+INSERT INTO Lease (name, holder, expiresAt, result)
+VALUES ($1, '', 0, $2)
+ON CONFLICT (name) DO UPDATE SET result = excluded.result
+`
+
+type SetLeaseResultParams struct {
+	Name   string
+	Result sql.NullString
+}
+
+func (q *Queries) SetLeaseResult(ctx context.Context, arg SetLeaseResultParams) error {
+	_, err := q.db.ExecContext(ctx, setLeaseResult, arg.Name, arg.Result)
+	return err
+}
+
+const takeLease = `-- name: TakeLease :execrows
+UPDATE Lease SET holder = $1, expiresAt = $2
+WHERE name = $3 AND (holder = $1 OR expiresAt <= $4)
+`
+
+type TakeLeaseParams struct {
+	Holder    string
+	ExpiresAt int64
+	Name      string
+	Now       int64
+}
+
+func (q *Queries) TakeLease(ctx context.Context, arg TakeLeaseParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, takeLease,
+		arg.Holder,
+		arg.ExpiresAt,
+		arg.Name,
+		arg.Now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateAdminKeyLastUsed = `-- name: UpdateAdminKeyLastUsed :exec
+UPDATE AdminKey SET lastUsedAt = $1 WHERE id = $2
+`
+
+type UpdateAdminKeyLastUsedParams struct {
+	Lastusedat sql.NullInt64
+	ID         string
+}
+
+func (q *Queries) UpdateAdminKeyLastUsed(ctx context.Context, arg UpdateAdminKeyLastUsedParams) error {
+	_, err := q.db.ExecContext(ctx, updateAdminKeyLastUsed, arg.Lastusedat, arg.ID)
+	return err
+}
+
+const updateDomain = `-- name: UpdateDomain :execrows
+UPDATE Domain SET status = $1, metadata = $2, updatedAt = $3
+WHERE name = $4
+`
+
+type UpdateDomainParams struct {
+	Status    string
+	Metadata  string
+	Updatedat int64
+	Name      string
+}
+
+func (q *Queries) UpdateDomain(ctx context.Context, arg UpdateDomainParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateDomain,
+		arg.Status,
+		arg.Metadata,
+		arg.Updatedat,
+		arg.Name,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateEmailExpiry = `-- name: UpdateEmailExpiry :exec
+UPDATE Email SET expiresAt = $1 WHERE id = $2
+`
+
+type UpdateEmailExpiryParams struct {
+	ExpiresAt int64
+	ID        int64
+}
+
+func (q *Queries) UpdateEmailExpiry(ctx context.Context, arg UpdateEmailExpiryParams) error {
+	_, err := q.db.ExecContext(ctx, updateEmailExpiry, arg.ExpiresAt, arg.ID)
+	return err
+}
+
+const updateInboxEmailExpiry = `-- name: UpdateInboxEmailExpiry :exec
+UPDATE Email SET expiresAt = $1
+WHERE id = (SELECT emailId FROM Inbox WHERE Inbox.id = $2)
+`
+
+type UpdateInboxEmailExpiryParams struct {
+	ExpiresAt int64
+	InboxID   string
+}
+
+func (q *Queries) UpdateInboxEmailExpiry(ctx context.Context, arg UpdateInboxEmailExpiryParams) error {
+	_, err := q.db.ExecContext(ctx, updateInboxEmailExpiry, arg.ExpiresAt, arg.InboxID)
+	return err
+}
+
+const updateWebhookDelivery = `-- name: UpdateWebhookDelivery :exec
+UPDATE WebhookDelivery
+SET status = $1, attempts = $2, responseStatus = $3, lastError = $4, nextAttemptAt = $5, deliveredAt = $6
+WHERE id = $7
+`
+
+type UpdateWebhookDeliveryParams struct {
+	Status         string
+	Attempts       int64
+	Responsestatus sql.NullInt64
+	Lasterror      sql.NullString
+	Nextattemptat  int64
+	Deliveredat    sql.NullInt64
+	ID             int64
+}
+
+func (q *Queries) UpdateWebhookDelivery(ctx context.Context, arg UpdateWebhookDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, updateWebhookDelivery,
+		arg.Status,
+		arg.Attempts,
+		arg.Responsestatus,
+		arg.Lasterror,
+		arg.Nextattemptat,
+		arg.Deliveredat,
+		arg.ID,
+	)
+	return err
+}
+
+const upsertAddressRetention = `-- name: UpsertAddressRetention :exec
+INSERT INTO AddressRetention (address, retention)
+VALUES ($1, $2)
+ON CONFLICT (address) DO UPDATE SET retention = excluded.retention
+`
+
+type UpsertAddressRetentionParams struct {
+	Address   string
+	Retention int64
+}
+
+func (q *Queries) UpsertAddressRetention(ctx context.Context, arg UpsertAddressRetentionParams) error {
+	_, err := q.db.ExecContext(ctx, upsertAddressRetention, arg.Address, arg.Retention)
+	return err
+}
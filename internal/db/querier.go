@@ -0,0 +1,82 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+type Querier interface {
+	AddressInUse(ctx context.Context, address string) (sql.NullBool, error)
+	CountInboxesForAddress(ctx context.Context, address sql.NullString) (int64, error)
+	DeleteAdminKey(ctx context.Context, id string) (int64, error)
+	DeleteByInboxID(ctx context.Context, id string) error
+	DeleteDomain(ctx context.Context, name string) (int64, error)
+	DeleteEmailsByID(ctx context.Context, ids []int64) (int64, error)
+	DeleteEventsBefore(ctx context.Context, before int64) (int64, error)
+	DeleteExpiredAddresses(ctx context.Context, expiresat int64) (int64, error)
+	DeleteExpiredRateLimits(ctx context.Context, expiresat int64) (int64, error)
+	DeleteInboxesByID(ctx context.Context, ids []string) (int64, error)
+	DeleteOrphanedEmails(ctx context.Context, ids []int64) (int64, error)
+	DeleteWebhook(ctx context.Context, id string) (int64, error)
+	GetActiveDomainNames(ctx context.Context) ([]string, error)
+	GetAddress(ctx context.Context, address string) (Address, error)
+	GetAddressClaim(ctx context.Context, address string) (Addressclaim, error)
+	GetAddressRetention(ctx context.Context, address string) (int64, error)
+	GetAddressSummaries(ctx context.Context, arg GetAddressSummariesParams) ([]GetAddressSummariesRow, error)
+	GetAdminKeyByHash(ctx context.Context, keyhash string) (GetAdminKeyByHashRow, error)
+	GetAdminKeys(ctx context.Context) ([]GetAdminKeysRow, error)
+	GetAttachmentForInbox(ctx context.Context, arg GetAttachmentForInboxParams) (GetAttachmentForInboxRow, error)
+	GetAttachmentsByInboxID(ctx context.Context, id string) ([]GetAttachmentsByInboxIDRow, error)
+	GetDomain(ctx context.Context, name string) (Domain, error)
+	GetDomainList(ctx context.Context) ([]Domain, error)
+	GetDueWebhookDeliveries(ctx context.Context, arg GetDueWebhookDeliveriesParams) ([]GetDueWebhookDeliveriesRow, error)
+	GetEmailRecipients(ctx context.Context, emailID sql.NullInt64) ([]sql.NullString, error)
+	GetEmailTimesForAddress(ctx context.Context, address sql.NullString) ([]GetEmailTimesForAddressRow, error)
+	GetEmailsForAddress(ctx context.Context, arg GetEmailsForAddressParams) ([]GetEmailsForAddressRow, error)
+	GetEventsAfter(ctx context.Context, arg GetEventsAfterParams) ([]GetEventsAfterRow, error)
+	GetExpiredEmailIDs(ctx context.Context, arg GetExpiredEmailIDsParams) ([]int64, error)
+	GetInboxByID(ctx context.Context, id string) (GetInboxByIDRow, error)
+	GetInboxRecipients(ctx context.Context, inboxID string) ([]sql.NullString, error)
+	GetInboxesByEmailIDs(ctx context.Context, emailIds []sql.NullInt64) ([]GetInboxesByEmailIDsRow, error)
+	GetInboxesForPurge(ctx context.Context, arg GetInboxesForPurgeParams) ([]GetInboxesForPurgeRow, error)
+	GetLastEventID(ctx context.Context) (int64, error)
+	GetLeaseResult(ctx context.Context, name string) (sql.NullString, error)
+	GetNextEmailForAddress(ctx context.Context, arg GetNextEmailForAddressParams) (GetNextEmailForAddressRow, error)
+	GetRawMessageByInboxID(ctx context.Context, id string) (GetRawMessageByInboxIDRow, error)
+	GetStats(ctx context.Context) (GetStatsRow, error)
+	GetWebhook(ctx context.Context, id string) (Webhook, error)
+	GetWebhookDeliveries(ctx context.Context, arg GetWebhookDeliveriesParams) ([]GetWebhookDeliveriesRow, error)
+	GetWebhooks(ctx context.Context) ([]Webhook, error)
+	// Counts a request against key and returns the count and end of the
+	// current window. A window that has ended starts over.
+	HitRateLimit(ctx context.Context, arg HitRateLimitParams) (HitRateLimitRow, error)
+	InsertAddress(ctx context.Context, arg InsertAddressParams) (int64, error)
+	InsertAddressClaim(ctx context.Context, arg InsertAddressClaimParams) (int64, error)
+	InsertAdminKey(ctx context.Context, arg InsertAdminKeyParams) error
+	InsertAttachment(ctx context.Context, arg InsertAttachmentParams) error
+	InsertDomain(ctx context.Context, arg InsertDomainParams) (int64, error)
+	InsertEmail(ctx context.Context, arg InsertEmailParams) (int64, error)
+	InsertEmailAddress(ctx context.Context, arg InsertEmailAddressParams) error
+	InsertEvent(ctx context.Context, arg InsertEventParams) error
+	InsertInbox(ctx context.Context, arg InsertInboxParams) error
+	InsertLease(ctx context.Context, arg InsertLeaseParams) (int64, error)
+	InsertRawMessage(ctx context.Context, arg InsertRawMessageParams) error
+	InsertSearchEntry(ctx context.Context, arg InsertSearchEntryParams) error
+	InsertWebhook(ctx context.Context, arg InsertWebhookParams) error
+	InsertWebhookDelivery(ctx context.Context, arg InsertWebhookDeliveryParams) error
+	LeaseWebhookDeliveries(ctx context.Context, arg LeaseWebhookDeliveriesParams) error
+	SetLeaseResult(ctx context.Context, arg SetLeaseResultParams) error
+	TakeLease(ctx context.Context, arg TakeLeaseParams) (int64, error)
+	UpdateAdminKeyLastUsed(ctx context.Context, arg UpdateAdminKeyLastUsedParams) error
+	UpdateDomain(ctx context.Context, arg UpdateDomainParams) (int64, error)
+	UpdateEmailExpiry(ctx context.Context, arg UpdateEmailExpiryParams) error
+	UpdateInboxEmailExpiry(ctx context.Context, arg UpdateInboxEmailExpiryParams) error
+	UpdateWebhookDelivery(ctx context.Context, arg UpdateWebhookDeliveryParams) error
+	UpsertAddressRetention(ctx context.Context, arg UpsertAddressRetentionParams) error
+}
+
+var _ Querier = (*Queries)(nil)
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// The FTS5 MATCH operator is not understood by sqlc, so the search query is
+// maintained by hand next to the generated code. Matches in the highlight and
+// snippet columns are wrapped in the control characters \x02 and \x03.
+
+const searchEmailsForAddress = `
+SELECT
+  Inbox.id,
+  Email.subject,
+  Email.createdAt,
+  Email.expiresAt,
+  (SELECT address FROM EmailAddress WHERE emailId = Email.id AND type = 'from') as fromAddress,
+  CAST(COALESCE((SELECT GROUP_CONCAT(address, ', ') FROM EmailAddress WHERE emailId = Email.id AND type = 'to'), '') AS TEXT) as toAddress,
+  highlight(EmailSearch, 1, char(2), char(3)) as subjectHighlight,
+  snippet(EmailSearch, -1, char(2), char(3), '…', 16) as snippet
+FROM EmailSearch
+JOIN Inbox ON Inbox.rowid = EmailSearch.rowid
+JOIN Email ON Email.id = Inbox.emailId
+WHERE EmailSearch MATCH ? AND EmailSearch.address = ?
+ORDER BY bm25(EmailSearch, 0, 10.0, 5.0, 1.0, 1.0)
+LIMIT ?
+`
+
+type SearchEmailsForAddressParams struct {
+	Query   string
+	Address string
+	Limit   int64
+}
+
+type SearchEmailsForAddressRow struct {
+	ID               string
+	Subject          sql.NullString
+	Createdat        int64
+	Expiresat        int64
+	Fromaddress      sql.NullString
+	Toaddress        string
+	Subjecthighlight sql.NullString
+	Snippet          sql.NullString
+}
+
+func (q *Queries) SearchEmailsForAddress(ctx context.Context, arg SearchEmailsForAddressParams) ([]SearchEmailsForAddressRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchEmailsForAddress, arg.Query, arg.Address, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchEmailsForAddressRow
+	for rows.Next() {
+		var i SearchEmailsForAddressRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Subject,
+			&i.Createdat,
+			&i.Expiresat,
+			&i.Fromaddress,
+			&i.Toaddress,
+			&i.Subjecthighlight,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnindexedInboxes = `
+SELECT
+  Inbox.id,
+  COALESCE(Email.subject, '') as subject,
+  COALESCE((SELECT address FROM EmailAddress WHERE emailId = Email.id AND type = 'from' LIMIT 1), '') as sender,
+  COALESCE(Inbox.htmlContent, '') as htmlContent
+FROM Inbox
+JOIN Email ON Email.id = Inbox.emailId
+WHERE NOT EXISTS (SELECT 1 FROM EmailSearch WHERE EmailSearch.rowid = Inbox.rowid)
+LIMIT ?
+`
+
+type GetUnindexedInboxesRow struct {
+	ID          string
+	Subject     string
+	Sender      string
+	Htmlcontent string
+}
+
+// GetUnindexedInboxes lists inbox entries stored before the search index
+// existed.
+func (q *Queries) GetUnindexedInboxes(ctx context.Context, limit int64) ([]GetUnindexedInboxesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUnindexedInboxes, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUnindexedInboxesRow
+	for rows.Next() {
+		var i GetUnindexedInboxesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Subject,
+			&i.Sender,
+			&i.Htmlcontent,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
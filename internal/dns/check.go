@@ -0,0 +1,129 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Resolver is the part of *net.Resolver used by Check, so that lookups can
+// be replaced.
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupCNAME(ctx context.Context, host string) (string, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// NewResolver returns a resolver that queries the DNS server at addr
+// ("host:port"), or the system resolver when addr is empty.
+func NewResolver(addr string) *net.Resolver {
+	if addr == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: 5 * time.Second}
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+// Check results.
+const (
+	StatusOK       = "ok"
+	StatusMissing  = "missing"
+	StatusMismatch = "mismatch"
+	StatusError    = "error"
+)
+
+// Result is the outcome of looking up one record. Found holds the values
+// published for the name and type, or the lookup error.
+type Result struct {
+	Record
+	Status string   `json:"status"`
+	Found  []string `json:"found,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// Check looks up every record with resolver and compares the published
+// values with the recommended ones.
+func Check(ctx context.Context, resolver Resolver, records []Record) []Result {
+	results := make([]Result, 0, len(records))
+	for _, r := range records {
+		found, err := lookup(ctx, resolver, r)
+		result := Result{Record: r, Found: found}
+		var dnsErr *net.DNSError
+		switch {
+		case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+			result.Status = StatusMissing
+		case err != nil:
+			result.Status, result.Error = StatusError, err.Error()
+		case slices.Contains(found, expected(r)):
+			result.Status = StatusOK
+		case len(found) == 0:
+			result.Status = StatusMissing
+		default:
+			result.Status = StatusMismatch
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// expected is the recommended value in the form lookup reports it.
+func expected(r Record) string {
+	if r.Type == "MX" {
+		return mxValue(r.Priority, r.Value)
+	}
+	return strings.ToLower(r.Value)
+}
+
+// lookup returns the published values for the record's name and type. TXT
+// records only count when they use the same version tag (v=spf1, v=DMARC1,
+// ...), so unrelated verification records are ignored.
+func lookup(ctx context.Context, resolver Resolver, r Record) ([]string, error) {
+	switch r.Type {
+	case "MX":
+		mxs, err := resolver.LookupMX(ctx, r.Name)
+		var found []string
+		for _, mx := range mxs {
+			found = append(found, mxValue(int(mx.Pref), mx.Host))
+		}
+		return found, err
+	case "TXT":
+		txts, err := resolver.LookupTXT(ctx, r.Name)
+		tag, _, _ := strings.Cut(r.Value, ";")
+		tag, _, _ = strings.Cut(tag, " ")
+		var found []string
+		for _, txt := range txts {
+			if strings.HasPrefix(strings.ToLower(txt), strings.ToLower(tag)) {
+				found = append(found, strings.ToLower(txt))
+			}
+		}
+		return found, err
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, r.Name)
+		if err != nil {
+			return nil, err
+		}
+		// Without a CNAME record the resolver returns the name itself.
+		cname = strings.ToLower(strings.TrimSuffix(cname, "."))
+		if cname == strings.ToLower(strings.TrimSuffix(r.Name, ".")) {
+			return nil, nil
+		}
+		return []string{cname}, nil
+	default:
+		addrs, err := resolver.LookupHost(ctx, r.Name)
+		return addrs, err
+	}
+}
+
+func mxValue(priority int, host string) string {
+	return strings.ToLower(strings.TrimSuffix(host, ".")) + " " + strconv.Itoa(priority)
+}
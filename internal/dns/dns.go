@@ -0,0 +1,146 @@
+// Package dns contains the recommended DNS records for the configured domains.
+package dns
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/postfix"
+)
+
+// Record is one DNS record. Name and Value are fully qualified names without
+// the trailing dot; Zone is the configured domain the record belongs to.
+type Record struct {
+	Zone     string `json:"zone"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	Priority int    `json:"priority,omitempty"`
+	TTL      int    `json:"ttl"`
+}
+
+// Hostname returns the name of the mail server: the SMTP listener hostname
+// when the listener is enabled and named, otherwise myhostname of the
+// generated Postfix configuration.
+func Hostname(cfg *config.Config) (string, error) {
+	if cfg.SMTP.Enabled && cfg.SMTP.Hostname != "" {
+		return cfg.SMTP.Hostname, nil
+	}
+	c, err := postfix.DefaultConfig(cfg)
+	if err != nil {
+		return "", err
+	}
+	if c.MyHostname == "" {
+		return "", fmt.Errorf("no mail hostname: configure at least one domain")
+	}
+	return c.MyHostname, nil
+}
+
+// MTASTSPolicy returns the policy served at
+// https://mta-sts.<domain>/.well-known/mta-sts.txt.
+func MTASTSPolicy(cfg *config.Config, hostname string) string {
+	return fmt.Sprintf("version: STSv1\nmode: %s\nmx: %s\nmax_age: %d\n",
+		cfg.DNS.MTASTSMode, hostname, cfg.DNS.MTASTSMaxAge)
+}
+
+// Records returns the recommended records for every configured domain:
+// MX, SPF, DMARC, MTA-STS and TLS-RPT, plus A/AAAA for the mail host when
+// the server addresses are configured. Reports are only requested from a
+// configured report_email: without it DMARC has no rua tag and TLS-RPT,
+// which requires one, is left out.
+func Records(cfg *config.Config) ([]Record, error) {
+	hostname, err := Hostname(cfg)
+	if err != nil {
+		return nil, err
+	}
+	policy := MTASTSPolicy(cfg, hostname)
+	sum := sha256.Sum256([]byte(policy))
+	policyID := hex.EncodeToString(sum[:8])
+	ttl := cfg.DNS.TTL
+
+	var records []Record
+	for _, domain := range cfg.ActiveDomains() {
+		domain = strings.ToLower(domain)
+		add := func(name, typ, value string) {
+			records = append(records, Record{Zone: domain, Name: name, Type: typ, Value: value, TTL: ttl})
+		}
+
+		records = append(records, Record{Zone: domain, Name: domain, Type: "MX", Value: hostname, Priority: 10, TTL: ttl})
+		if hostname == domain || strings.HasSuffix(hostname, "."+domain) {
+			if cfg.DNS.IPv4 != "" {
+				add(hostname, "A", cfg.DNS.IPv4)
+			}
+			if cfg.DNS.IPv6 != "" {
+				add(hostname, "AAAA", cfg.DNS.IPv6)
+			}
+		}
+		add(domain, "TXT", "v=spf1 mx ~all")
+
+		report := cfg.DNS.ReportEmail
+		dmarc := "v=DMARC1; p=" + cfg.DNS.DMARCPolicy
+		if report != "" {
+			dmarc += "; rua=mailto:" + report
+		}
+		add("_dmarc."+domain, "TXT", dmarc)
+		add("_mta-sts."+domain, "TXT", "v=STSv1; id="+policyID)
+		add("mta-sts."+domain, "CNAME", hostname)
+		if report != "" {
+			add("_smtp._tls."+domain, "TXT", "v=TLSRPTv1; rua=mailto:"+report)
+		}
+	}
+	return records, nil
+}
+
+// Zone formats records as a BIND zone file snippet.
+func Zone(records []Record) string {
+	var b strings.Builder
+	zone := ""
+	for _, r := range records {
+		if r.Zone != zone {
+			if zone != "" {
+				b.WriteString("\n")
+			}
+			zone = r.Zone
+			fmt.Fprintf(&b, "; %s\n", zone)
+		}
+		value := r.Value
+		switch r.Type {
+		case "MX":
+			value = fmt.Sprintf("%d %s.", r.Priority, r.Value)
+		case "CNAME":
+			value += "."
+		case "TXT":
+			value = fmt.Sprintf("%q", r.Value)
+		}
+		fmt.Fprintf(&b, "%s.\t%d\tIN\t%s\t%s\n", r.Name, r.TTL, r.Type, value)
+	}
+	return b.String()
+}
+
+// CloudflareRecord is the body of a Cloudflare "create DNS record" request
+// (POST /zones/{zone_id}/dns_records).
+type CloudflareRecord struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Content  string `json:"content"`
+	Priority *int   `json:"priority,omitempty"`
+	TTL      int    `json:"ttl"`
+	Proxied  bool   `json:"proxied"`
+}
+
+// Cloudflare groups records by zone as Cloudflare API request bodies.
+func Cloudflare(records []Record) map[string][]CloudflareRecord {
+	zones := make(map[string][]CloudflareRecord)
+	for _, r := range records {
+		cf := CloudflareRecord{Type: r.Type, Name: r.Name, Content: r.Value, TTL: r.TTL}
+		if r.Type == "MX" {
+			priority := r.Priority
+			cf.Priority = &priority
+		}
+		zones[r.Zone] = append(zones[r.Zone], cf)
+	}
+	return zones
+}
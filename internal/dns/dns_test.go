@@ -0,0 +1,174 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/pageton/temp-mail/config"
+)
+
+// stubResolver answers lookups from maps keyed by name. Names without an
+// entry are not found.
+type stubResolver struct {
+	mx    map[string][]*net.MX
+	txt   map[string][]string
+	cname map[string]string
+	host  map[string][]string
+	err   error // Returned for every lookup when set
+}
+
+func notFound(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (s stubResolver) LookupMX(_ context.Context, name string) ([]*net.MX, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if mx, ok := s.mx[name]; ok {
+		return mx, nil
+	}
+	return nil, notFound(name)
+}
+
+func (s stubResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if txt, ok := s.txt[name]; ok {
+		return txt, nil
+	}
+	return nil, notFound(name)
+}
+
+func (s stubResolver) LookupCNAME(_ context.Context, host string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	if cname, ok := s.cname[host]; ok {
+		return cname, nil
+	}
+	return "", notFound(host)
+}
+
+func (s stubResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if addrs, ok := s.host[host]; ok {
+		return addrs, nil
+	}
+	return nil, notFound(host)
+}
+
+func testConfig(reportEmail string) *config.Config {
+	return &config.Config{
+		Domains: config.DomainsConfig{Aliases: []string{"example.com"}},
+		DNS: config.DNSConfig{
+			TTL:          3600,
+			DMARCPolicy:  "none",
+			ReportEmail:  reportEmail,
+			MTASTSMode:   "testing",
+			MTASTSMaxAge: 86400,
+			IPv4:         "203.0.113.10",
+		},
+	}
+}
+
+func recordValues(records []Record) map[string]string {
+	values := make(map[string]string)
+	for _, r := range records {
+		values[r.Type+" "+r.Name] = r.Value
+	}
+	return values
+}
+
+func TestRecordsReportEmail(t *testing.T) {
+	records, err := Records(testConfig(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := recordValues(records)
+	if got := values["TXT _dmarc.example.com"]; got != "v=DMARC1; p=none" {
+		t.Errorf("DMARC without report_email = %q, want no rua", got)
+	}
+	if got, ok := values["TXT _smtp._tls.example.com"]; ok {
+		t.Errorf("TLS-RPT without report_email = %q, want none", got)
+	}
+
+	records, err = Records(testConfig("reports@example.net"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values = recordValues(records)
+	if got := values["TXT _dmarc.example.com"]; got != "v=DMARC1; p=none; rua=mailto:reports@example.net" {
+		t.Errorf("DMARC = %q", got)
+	}
+	if got := values["TXT _smtp._tls.example.com"]; got != "v=TLSRPTv1; rua=mailto:reports@example.net" {
+		t.Errorf("TLS-RPT = %q", got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	records, err := Records(testConfig("reports@example.net"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := recordValues(records)
+
+	resolver := stubResolver{
+		// Trailing dots and case differ from the recommended values.
+		mx: map[string][]*net.MX{"example.com": {{Host: "Mail.Example.com.", Pref: 10}}},
+		txt: map[string][]string{
+			// The verification record is ignored, the SPF record differs.
+			"example.com":        {"google-site-verification=abc", "v=spf1 -all"},
+			"_dmarc.example.com": {values["TXT _dmarc.example.com"]},
+			// _mta-sts.example.com is not published.
+			"_smtp._tls.example.com": {"unrelated"},
+		},
+		cname: map[string]string{"mta-sts.example.com": "mail.example.com."},
+		host:  map[string][]string{"mail.example.com": {"203.0.113.10"}},
+	}
+	want := map[string]string{
+		"MX example.com":             StatusOK,
+		"A mail.example.com":         StatusOK,
+		"TXT example.com":            StatusMismatch,
+		"TXT _dmarc.example.com":     StatusOK,
+		"TXT _mta-sts.example.com":   StatusMissing,
+		"CNAME mta-sts.example.com":  StatusOK,
+		"TXT _smtp._tls.example.com": StatusMissing,
+	}
+	results := Check(ctx, resolver, records)
+	if len(results) != len(want) {
+		t.Fatalf("Check returned %d results, want %d", len(results), len(want))
+	}
+	for _, r := range results {
+		key := r.Type + " " + r.Name
+		if r.Status != want[key] {
+			t.Errorf("%s: status %s (found %v), want %s", key, r.Status, r.Found, want[key])
+		}
+	}
+	for _, r := range results {
+		if r.Type == "TXT" && r.Name == "example.com" && (len(r.Found) != 1 || r.Found[0] != "v=spf1 -all") {
+			t.Errorf("SPF found = %v, want only the SPF record", r.Found)
+		}
+	}
+
+	// net.Resolver answers a name without a CNAME record with the name.
+	resolver.cname = map[string]string{"mta-sts.example.com": "MTA-STS.example.com."}
+	for _, r := range Check(ctx, resolver, records) {
+		if r.Type == "CNAME" && (r.Status != StatusMissing || len(r.Found) != 0) {
+			t.Errorf("CNAME without a record: %s (found %v), want %s", r.Status, r.Found, StatusMissing)
+		}
+	}
+
+	failing := stubResolver{err: errors.New("connection refused")}
+	for _, r := range Check(ctx, failing, records) {
+		if r.Status != StatusError || r.Error != "connection refused" {
+			t.Errorf("%s %s with a failing resolver: %s %q", r.Type, r.Name, r.Status, r.Error)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Package domains contains the domains added at runtime through the admin API.
+package domains
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/db"
+)
+
+// Domain statuses. Mail is only accepted for active domains; pending domains
+// are waiting for their DNS records, disabled ones no longer receive mail.
+const (
+	StatusPending  = "pending"
+	StatusActive   = "active"
+	StatusDisabled = "disabled"
+)
+
+var (
+	ErrInvalidName   = errors.New("invalid domain name")
+	ErrInvalidStatus = errors.New("status must be pending, active or disabled")
+)
+
+// Normalize lowercases name, drops a trailing dot and checks that the result
+// is a valid host name with at least two labels.
+func Normalize(name string) (string, error) {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	labels := strings.Split(name, ".")
+	if len(name) > 253 || len(labels) < 2 {
+		return "", ErrInvalidName
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return "", ErrInvalidName
+		}
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+				return "", ErrInvalidName
+			}
+		}
+	}
+	return name, nil
+}
+
+// CheckStatus returns ErrInvalidStatus unless status is a known status.
+func CheckStatus(status string) error {
+	switch status {
+	case StatusPending, StatusActive, StatusDisabled:
+		return nil
+	}
+	return ErrInvalidStatus
+}
+
+// Load reads the active domains from the database into cfg, where HasDomain
+// and ActiveDomains pick them up.
+func Load(ctx context.Context, queries db.Querier, cfg *config.Config) error {
+	names, err := queries.GetActiveDomainNames(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.SetManagedDomains(names)
+	return nil
+}
+
+// refresh wakes Watch up before its next tick.
+var refresh = make(chan struct{}, 1)
+
+// Refresh makes Watch in this process reload the domains right away.
+func Refresh() {
+	select {
+	case refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Watch reloads the active domains every interval and on Refresh, so that
+// changes made by another prefork process or replica are picked up. When
+// onChange is not nil it is called right away and then whenever the active
+// domains differ from the set it last handled, and called again at the next
+// reload when it fails.
+func Watch(
+	ctx context.Context,
+	queries db.Querier,
+	cfg *config.Config,
+	interval time.Duration,
+	onChange func(active []string) error,
+) {
+	var handled []string
+	reload := func() {
+		if err := Load(ctx, queries, cfg); err != nil {
+			log.Println("Error loading domains:", err)
+			return
+		}
+		active := cfg.ActiveDomains()
+		if onChange == nil || (handled != nil && slices.Equal(active, handled)) {
+			return
+		}
+		if err := onChange(active); err != nil {
+			log.Println("Error applying domain change:", err)
+			return
+		}
+		handled = active
+	}
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		if onChange != nil {
+			reload()
+		}
+		for {
+			select {
+			case <-ticker.C:
+				reload()
+			case <-refresh:
+				reload()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
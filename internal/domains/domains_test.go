@@ -0,0 +1,74 @@
+package domains_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/domains"
+	"github.com/pageton/temp-mail/internal/storage/storagetest"
+)
+
+func TestWatchOnChange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queries, _ := storagetest.SQLite(t)
+	cfg := &config.Config{Domains: config.DomainsConfig{Aliases: []string{"example.com"}}}
+
+	changes := make(chan []string)
+	calls := 0
+	domains.Watch(ctx, queries, cfg, time.Hour, func(active []string) error {
+		changes <- active
+		calls++
+		if calls == 2 {
+			return errors.New("postfix reload failed")
+		}
+		return nil
+	})
+	wait := func() []string {
+		t.Helper()
+		select {
+		case active := <-changes:
+			return active
+		case <-time.After(5 * time.Second):
+			t.Fatal("onChange not called")
+			return nil
+		}
+	}
+
+	next := func() []string {
+		t.Helper()
+		domains.Refresh()
+		return wait()
+	}
+
+	// The first reload always calls onChange, since Postfix may be out of
+	// date from before the start.
+	if active := wait(); !slices.Equal(active, []string{"example.com"}) {
+		t.Errorf("first onChange(%v), want the configured domain", active)
+	}
+
+	_, err := queries.InsertDomain(ctx, db.InsertDomainParams{Name: "example.net", Status: domains.StatusActive, Metadata: "{}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"example.com", "example.net"}
+	if active := next(); !slices.Equal(active, want) {
+		t.Errorf("onChange(%v), want %v", active, want)
+	}
+	// The failed change is handled again at the next reload.
+	if active := next(); !slices.Equal(active, want) {
+		t.Errorf("retried onChange(%v), want %v", active, want)
+	}
+
+	domains.Refresh()
+	select {
+	case active := <-changes:
+		t.Errorf("onChange(%v) without a change", active)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,261 @@
+// Package ingest contains the parse-and-store path shared by the webhook and the SMTP listener.
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jhillyerd/enmime/v2"
+	"github.com/lucsky/cuid"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/pubsub"
+	"github.com/pageton/temp-mail/internal/retention"
+	"github.com/pageton/temp-mail/internal/search"
+	"github.com/pageton/temp-mail/internal/storage"
+	"github.com/pageton/temp-mail/internal/utils"
+	"github.com/pageton/temp-mail/internal/webhooks"
+)
+
+var (
+	ErrParse        = errors.New("error parsing email")
+	ErrNoRecipients = errors.New("no recipients")
+)
+
+// parser keeps the text part empty for HTML-only mail so that the stored
+// message reflects which parts were actually sent.
+var parser = enmime.NewParser(enmime.DisableTextConversion(true))
+
+// Store parses a raw RFC 5322 message and stores it in the inbox of every
+// recipient on a configured domain, keeping the original message alongside.
+// envelope holds the recipients reported by the MTA; when it is empty they are
+// taken from the message headers. Every stored inbox entry is published to
+// hub after the transaction holding it commits. It returns the ID of the
+// stored Email row.
+func Store(
+	ctx context.Context,
+	cfg *config.Config,
+	queries storage.Store,
+	hub *pubsub.Hub,
+	raw []byte,
+	envelope []string,
+) (int64, error) {
+	env, err := parser.ReadEnvelope(bytes.NewReader(raw))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrParse, err)
+	}
+	inboxAddresses := recipients(cfg, env, envelope)
+	if len(inboxAddresses) == 0 {
+		return 0, ErrNoRecipients
+	}
+	var emailID int64
+	var events []pubsub.Event
+	err = queries.InTx(ctx, func(tx storage.Store) error {
+		var err error
+		emailID, events, err = insert(ctx, cfg, tx, env, raw, inboxAddresses)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	// Subscribers only hear about mail that was committed.
+	for _, e := range events {
+		hub.Publish(e)
+	}
+
+	return emailID, nil
+}
+
+// insert writes the message, its inbox entries, attachments and webhook
+// deliveries, and returns the events to publish once they are committed.
+func insert(
+	ctx context.Context,
+	cfg *config.Config,
+	queries storage.Store,
+	env *enmime.Envelope,
+	raw []byte,
+	inboxAddresses []string,
+) (int64, []pubsub.Event, error) {
+	subject := env.GetHeader("Subject")
+	textBody := env.Text
+	htmlBody := env.HTML
+
+	toAddresses := utils.ParseEmailAddresses(env.GetHeader("To"))
+	ccAddresses := utils.ParseEmailAddresses(env.GetHeader("Cc"))
+	fromAddresses := utils.ParseEmailAddresses(env.GetHeader("From"))
+	createdAt := time.Now()
+	expiresAt, err := retention.ExpiresAt(ctx, cfg, queries, inboxAddresses, createdAt)
+	if err != nil {
+		return 0, nil, fmt.Errorf("getting retention: %w", err)
+	}
+	emailID, err := queries.InsertEmail(
+		ctx,
+		db.InsertEmailParams{
+			Subject:   sql.NullString{String: subject, Valid: subject != ""},
+			Createdat: createdAt.UnixMilli(),
+			Expiresat: expiresAt.UnixMilli(),
+		},
+	)
+	if err != nil {
+		return 0, nil, fmt.Errorf("inserting email: %w", err)
+	}
+	compression := CompressionNone
+	if cfg.Database.CompressRaw {
+		compression = CompressionGzip
+	}
+	content, err := compressRaw(compression, raw)
+	if err != nil {
+		return 0, nil, fmt.Errorf("compressing raw message: %w", err)
+	}
+	err = queries.InsertRawMessage(
+		ctx,
+		db.InsertRawMessageParams{
+			Emailid:     emailID,
+			Compression: compression,
+			Size:        int64(len(raw)),
+			Content:     content,
+		},
+	)
+	if err != nil {
+		return 0, nil, fmt.Errorf("inserting raw message: %w", err)
+	}
+	recipientGroups := []struct {
+		Type   string
+		Values []string
+	}{
+		{Type: "from", Values: fromAddresses},
+		{Type: "to", Values: toAddresses},
+		{Type: "cc", Values: ccAddresses},
+	}
+
+	for _, group := range recipientGroups {
+		for _, addr := range group.Values {
+			err = queries.InsertEmailAddress(
+				ctx,
+				db.InsertEmailAddressParams{
+					Emailid: sql.NullInt64{Int64: emailID, Valid: true},
+					Type:    sql.NullString{String: group.Type, Valid: true},
+					Address: sql.NullString{String: addr, Valid: true},
+				},
+			)
+			if err != nil {
+				return 0, nil, fmt.Errorf("inserting email address: %w", err)
+			}
+		}
+	}
+	var fromAddress string
+	if len(fromAddresses) > 0 {
+		fromAddress = fromAddresses[0]
+	}
+	var events []pubsub.Event
+	for _, toAddress := range inboxAddresses {
+		inboxID := cuid.New()
+		err = queries.InsertInbox(
+			ctx,
+			db.InsertInboxParams{
+				ID:          inboxID,
+				Emailid:     sql.NullInt64{Int64: emailID, Valid: true},
+				Address:     sql.NullString{String: toAddress, Valid: true},
+				Textcontent: sql.NullString{String: textBody, Valid: textBody != ""},
+				Htmlcontent: sql.NullString{String: htmlBody, Valid: htmlBody != ""},
+			},
+		)
+		if err != nil {
+			return 0, nil, fmt.Errorf("inserting inbox: %w", err)
+		}
+		err = search.Index(ctx, queries, inboxID, subject, env.GetHeader("From"), htmlBody)
+		if err != nil {
+			return 0, nil, fmt.Errorf("indexing inbox: %w", err)
+		}
+		events = append(events, pubsub.Event{
+			Type:        pubsub.EventReceived,
+			Address:     toAddress,
+			InboxID:     inboxID,
+			EmailID:     emailID,
+			Subject:     subject,
+			FromAddress: fromAddress,
+			ToAddress:   strings.Join(toAddresses, ", "),
+			CreatedAt:   time.UnixMilli(createdAt.UnixMilli()),
+			ExpiresAt:   expiresAt,
+		})
+	}
+	partGroups := []struct {
+		Disposition string
+		Parts       []*enmime.Part
+	}{
+		{Disposition: "attachment", Parts: env.Attachments},
+		{Disposition: "inline", Parts: env.Inlines},
+		{Disposition: "inline", Parts: env.OtherParts},
+	}
+
+	for _, group := range partGroups {
+		for _, part := range group.Parts {
+			err = queries.InsertAttachment(
+				ctx,
+				db.InsertAttachmentParams{
+					ID:          cuid.New(),
+					Emailid:     sql.NullInt64{Int64: emailID, Valid: true},
+					Filename:    sql.NullString{String: part.FileName, Valid: part.FileName != ""},
+					Contenttype: sql.NullString{String: part.ContentType, Valid: part.ContentType != ""},
+					Size:        int64(len(part.Content)),
+					Contentid: sql.NullString{
+						String: strings.Trim(part.ContentID, "<>"),
+						Valid:  part.ContentID != "",
+					},
+					Disposition: sql.NullString{String: group.Disposition, Valid: true},
+					Content:     part.Content,
+				},
+			)
+			if err != nil {
+				return 0, nil, fmt.Errorf("inserting attachment: %w", err)
+			}
+		}
+	}
+
+	if err = webhooks.Enqueue(ctx, queries, events, textBody, htmlBody); err != nil {
+		return 0, nil, fmt.Errorf("queueing webhooks: %w", err)
+	}
+	return emailID, events, nil
+}
+
+// recipients returns the inbox addresses for a message. Envelope recipients
+// win over X-Original-To, which wins over Delivered-To, To and Cc, so that
+// Bcc and mailing-list mail reaches the right inbox. Addresses outside the
+// configured domains are dropped.
+func recipients(cfg *config.Config, env *enmime.Envelope, envelope []string) []string {
+	candidates := envelope
+	if len(candidates) == 0 {
+		candidates = parseHeaders(env, "X-Original-To")
+	}
+	if len(candidates) == 0 {
+		candidates = parseHeaders(env, "Delivered-To", "To", "Cc")
+	}
+
+	var result []string
+	seen := make(map[string]bool)
+	for _, addr := range candidates {
+		addr = strings.ToLower(strings.Trim(strings.TrimSpace(addr), "<>"))
+		if seen[addr] || !cfg.HasDomain(utils.EmailDomain(addr)) {
+			continue
+		}
+		seen[addr] = true
+		result = append(result, addr)
+	}
+	return result
+}
+
+func parseHeaders(env *enmime.Envelope, keys ...string) []string {
+	var result []string
+	for _, key := range keys {
+		for _, value := range env.GetHeaderValues(key) {
+			result = append(result, utils.ParseEmailAddresses(value)...)
+		}
+	}
+	return result
+}
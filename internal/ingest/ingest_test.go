@@ -0,0 +1,237 @@
+package ingest_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/ingest"
+	"github.com/pageton/temp-mail/internal/pubsub"
+	"github.com/pageton/temp-mail/internal/storage"
+	"github.com/pageton/temp-mail/internal/storage/storagetest"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Domains: config.DomainsConfig{
+			Aliases:          []string{"example.com"},
+			DefaultRetention: time.Hour,
+		},
+	}
+}
+
+func TestStoreFixtures(t *testing.T) {
+	tests := []struct {
+		file        string
+		address     string
+		subject     string
+		text        string // Substring of the text part, "" when there is none
+		html        string // Substring of the HTML part, "" when there is none
+		attachments []string
+	}{
+		{
+			file:    "text-only.eml",
+			address: "alice@example.com",
+			subject: "Your order #1042 has shipped",
+			text:    "arrive in 2–3 days",
+		},
+		{
+			file:    "html-only.eml",
+			address: "bob@example.com",
+			subject: "Weekly digest",
+			html:    "<h1>Digest</h1>",
+		},
+		{
+			file:    "empty-subject.eml",
+			address: "carol@example.com",
+			text:    "No subject on this one.",
+		},
+		{
+			file:    "alternative.eml",
+			address: "dave@example.com",
+			subject: "Verify your email ✔",
+			text:    "Your code is 482913.",
+			html:    "<b>482913</b>",
+		},
+		{
+			file:        "mixed-attachment.eml",
+			address:     "erin@example.com",
+			subject:     "Invoice",
+			text:        "Invoice attached.",
+			html:        "<p>Invoice attached.</p>",
+			attachments: []string{"invoice.pdf"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			ctx := context.Background()
+			queries, _ := storagetest.SQLite(t)
+			hub := pubsub.NewHub()
+			sub := hub.Subscribe(tt.address)
+			defer sub.Close()
+
+			raw, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = ingest.Store(ctx, testConfig(), queries, hub, raw, nil); err != nil {
+				t.Fatalf("Store: %v", err)
+			}
+
+			var event pubsub.Event
+			select {
+			case event = <-sub.Events():
+			default:
+				t.Fatal("no event published")
+			}
+			inbox, err := queries.GetInboxByID(ctx, event.InboxID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if inbox.Subject.String != tt.subject || inbox.Subject.Valid != (tt.subject != "") {
+				t.Errorf("subject = %+v, want %q", inbox.Subject, tt.subject)
+			}
+			checkPart(t, "text", inbox.Textcontent, tt.text)
+			checkPart(t, "html", inbox.Htmlcontent, tt.html)
+
+			attachments, err := queries.GetAttachmentsByInboxID(ctx, inbox.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, a := range attachments {
+				names = append(names, a.Filename.String)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.attachments, ",") {
+				t.Errorf("attachments = %v, want %v", names, tt.attachments)
+			}
+
+			rawRow, err := queries.GetRawMessageByInboxID(ctx, inbox.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored, err := ingest.DecompressRaw(rawRow.Compression, rawRow.Content)
+			if err != nil || string(stored) != string(raw) {
+				t.Errorf("raw message not stored unchanged (err %v)", err)
+			}
+		})
+	}
+}
+
+func checkPart(t *testing.T, name string, got sql.NullString, want string) {
+	t.Helper()
+	if want == "" {
+		if got.Valid {
+			t.Errorf("%s = %q, want none", name, got.String)
+		}
+		return
+	}
+	if !strings.Contains(got.String, want) {
+		t.Errorf("%s = %q, want it to contain %q", name, got.String, want)
+	}
+}
+
+func TestStoreRecipients(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("testdata", "mixed-attachment.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		envelope []string
+		want     []string
+		err      error
+	}{
+		{name: "headers, foreign domain dropped", want: []string{"erin@example.com"}},
+		{name: "envelope wins", envelope: []string{"Bcc@Example.com"}, want: []string{"bcc@example.com"}},
+		{name: "only foreign recipients", envelope: []string{"x@elsewhere.example.net"}, err: ingest.ErrNoRecipients},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			queries, _ := storagetest.SQLite(t)
+			_, err := ingest.Store(ctx, testConfig(), queries, pubsub.NewHub(), raw, tt.envelope)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			for _, address := range tt.want {
+				rows, err := queries.GetEmailsForAddress(ctx, db.GetEmailsForAddressParams{
+					Address: sql.NullString{String: address, Valid: true},
+					Limit:   10,
+				})
+				if err != nil || len(rows) != 1 {
+					t.Errorf("%s: %d emails (err %v), want 1", address, len(rows), err)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreUnparseable(t *testing.T) {
+	queries, _ := storagetest.SQLite(t)
+	_, err := ingest.Store(context.Background(), testConfig(), queries, pubsub.NewHub(), []byte("\x00\x01 not mail"), nil)
+	if !errors.Is(err, ingest.ErrParse) {
+		t.Fatalf("err = %v, want ErrParse", err)
+	}
+}
+
+// failingStore fails every webhook delivery insert made inside InTx.
+type failingStore struct {
+	storage.Store
+}
+
+func (f failingStore) InTx(ctx context.Context, fn func(storage.Store) error) error {
+	return f.Store.InTx(ctx, func(tx storage.Store) error {
+		return fn(failingStore{tx})
+	})
+}
+
+func (f failingStore) InsertWebhookDelivery(context.Context, db.InsertWebhookDeliveryParams) error {
+	return errors.New("queue unavailable")
+}
+
+func TestStoreRollsBack(t *testing.T) {
+	ctx := context.Background()
+	queries, database := storagetest.SQLite(t)
+	err := queries.InsertWebhook(ctx, db.InsertWebhookParams{
+		ID:      "hook",
+		Url:     "https://hooks.example.net/",
+		Secret:  "secret",
+		Pattern: "example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(filepath.Join("testdata", "mixed-attachment.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := pubsub.NewHub()
+	sub := hub.Subscribe("erin@example.com")
+	defer sub.Close()
+
+	if _, err := ingest.Store(ctx, testConfig(), failingStore{queries}, hub, raw, nil); err == nil {
+		t.Fatal("Store succeeded although queueing the webhook failed")
+	}
+	select {
+	case e := <-sub.Events():
+		t.Errorf("event %+v published for a rolled back message", e)
+	default:
+	}
+	for _, table := range []string{"Email", "Inbox", "Attachment", "RawMessage", "EmailAddress"} {
+		var n int
+		if err := database.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("%s has %d rows after rollback", table, n)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// Compression schemes for stored raw messages.
+const (
+	CompressionNone = ""
+	CompressionGzip = "gzip"
+)
+
+// compressRaw encodes raw with the given compression scheme.
+func compressRaw(compression string, raw []byte) ([]byte, error) {
+	switch compression {
+	case CompressionNone:
+		return raw, nil
+	case CompressionGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(raw); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown compression %q", compression)
+	}
+}
+
+// DecompressRaw decodes a stored raw message back into its RFC 5322 form.
+func DecompressRaw(compression string, content []byte) ([]byte, error) {
+	switch compression {
+	case CompressionNone:
+		return content, nil
+	case CompressionGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	default:
+		return nil, fmt.Errorf("unknown compression %q", compression)
+	}
+}
@@ -0,0 +1,215 @@
+// Package migrate contains the versioned schema migrations for the application.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pageton/temp-mail/internal/sqlc"
+)
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer
+// version of the application.
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary supports")
+
+// Migration is one numbered file in internal/sqlc/migrations, or
+// internal/sqlc/postgres/migrations for PostgreSQL, named <version>_<name>.sql.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Status describes the schema version of a database.
+type Status struct {
+	Current int
+	Latest  int
+	Pending []Migration
+}
+
+const createVersionTable = `
+CREATE TABLE IF NOT EXISTS schema_version (
+  version INTEGER PRIMARY KEY,
+  appliedAt BIGINT NOT NULL
+)`
+
+// lockID is the PostgreSQL advisory lock held while migrating, so replicas
+// starting together apply each migration once.
+const lockID = 7_462_001
+
+// queryer is implemented by *sql.DB and *sql.Conn.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func dir(backend string) string {
+	if backend == "postgres" {
+		return "postgres/migrations"
+	}
+	return "migrations"
+}
+
+// Migrations returns the embedded migrations for backend ("sqlite" or
+// "postgres") ordered by version.
+func Migrations(backend string) ([]Migration, error) {
+	files, err := fs.Glob(sqlc.Migrations, dir(backend)+"/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, file := range files {
+		base := strings.TrimSuffix(path.Base(file), ".sql")
+		number, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name must start with a positive version", file)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, file, version)
+		}
+		seen[version] = file
+		content, err := fs.ReadFile(sqlc.Migrations, file)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(content)})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// GetStatus returns the current and latest schema versions and the
+// migrations that have not been applied yet.
+func GetStatus(ctx context.Context, db *sql.DB, backend string) (Status, error) {
+	return getStatus(ctx, db, backend)
+}
+
+func getStatus(ctx context.Context, db queryer, backend string) (Status, error) {
+	migrations, err := Migrations(backend)
+	if err != nil {
+		return Status{}, err
+	}
+	if _, err = db.ExecContext(ctx, createVersionTable); err != nil {
+		return Status{}, err
+	}
+	var status Status
+	err = db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&status.Current)
+	if err != nil {
+		return Status{}, err
+	}
+	for _, m := range migrations {
+		status.Latest = max(status.Latest, m.Version)
+		if m.Version > status.Current {
+			status.Pending = append(status.Pending, m)
+		}
+	}
+	return status, nil
+}
+
+// Check returns ErrSchemaTooNew when the database is ahead of the embedded
+// migrations.
+func Check(ctx context.Context, db *sql.DB, backend string) (Status, error) {
+	return check(ctx, db, backend)
+}
+
+func check(ctx context.Context, db queryer, backend string) (Status, error) {
+	status, err := getStatus(ctx, db, backend)
+	if err != nil {
+		return status, err
+	}
+	if status.Current > status.Latest {
+		return status, fmt.Errorf("%w: database is at version %d, latest known is %d",
+			ErrSchemaTooNew, status.Current, status.Latest)
+	}
+	return status, nil
+}
+
+// Up applies all pending migrations and returns them. Each migration runs in
+// its own transaction. On SQLite foreign keys are disabled, so that tables
+// can be rebuilt, and checked before the migration commits; on PostgreSQL an
+// advisory lock serializes concurrent callers.
+func Up(ctx context.Context, db *sql.DB, backend string) ([]Migration, error) {
+	// PRAGMA foreign_keys and advisory locks are per connection.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if backend == "postgres" {
+		if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+			return nil, err
+		}
+		defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockID)
+	}
+
+	status, err := check(ctx, conn, backend)
+	if err != nil {
+		return nil, err
+	}
+	if len(status.Pending) == 0 {
+		return nil, nil
+	}
+
+	if backend != "postgres" {
+		// Ignored inside a transaction, so it is set on the connection.
+		if _, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+			return nil, err
+		}
+		defer conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON")
+	}
+
+	var applied []Migration
+	for _, m := range status.Pending {
+		if err := apply(ctx, conn, backend, m); err != nil {
+			return applied, fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+func apply(ctx context.Context, conn *sql.Conn, backend string, m Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, m.SQL); err != nil {
+		return err
+	}
+
+	insert := "INSERT INTO schema_version (version, appliedAt) VALUES (?, ?)"
+	if backend == "postgres" {
+		insert = "INSERT INTO schema_version (version, appliedAt) VALUES ($1, $2)"
+	} else {
+		rows, err := tx.QueryContext(ctx, "PRAGMA foreign_key_check")
+		if err != nil {
+			return err
+		}
+		violation := rows.Next()
+		if err = rows.Close(); err != nil {
+			return err
+		}
+		if violation {
+			return errors.New("foreign key violations after migration")
+		}
+	}
+	if _, err = tx.ExecContext(ctx, insert, m.Version, time.Now().UnixMilli()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -2,68 +2,111 @@
 package postfix
 
 import (
+	"embed"
+	"errors"
 	"fmt"
 	"os"
-	"strconv"
+	"reflect"
 	"strings"
 	"text/template"
 
 	"github.com/pageton/temp-mail/config"
 )
 
+// Paths of the files written by Setup.
+const (
+	MainCFPath        = "/etc/postfix/main.cf"
+	VirtualRegexpPath = "/etc/postfix/virtual_regexp"
+	AliasesPath       = "/etc/aliases"
+	ForwardScriptPath = "/usr/local/bin/forward-to-webhook.sh"
+)
+
+// Config holds the main.cf settings. Every field can be overridden in the
+// [postfix] section of config.toml using its main.cf parameter name.
 type Config struct {
 	// General
-	Banner             string
-	Biff               string
-	AppendDotMyDomain  string
-	ReadmeDirectory    string
-	CompatibilityLevel string
+	Banner             string `toml:"smtpd_banner"`
+	Biff               string `toml:"biff"`
+	AppendDotMyDomain  string `toml:"append_dot_mydomain"`
+	ReadmeDirectory    string `toml:"readme_directory"`
+	CompatibilityLevel string `toml:"compatibility_level"`
 
 	// Server TLS
-	TlSCertFile           string
-	TlSKeyFile            string
-	TlSSecurityLevel      string
-	RelayRestrictions     string
-	RecipientRestrictions string
+	TlSCertFile           string `toml:"smtpd_tls_cert_file"`
+	TlSKeyFile            string `toml:"smtpd_tls_key_file"`
+	TlSSecurityLevel      string `toml:"smtpd_tls_security_level"`
+	RelayRestrictions     string `toml:"smtpd_relay_restrictions"`
+	RecipientRestrictions string `toml:"smtpd_recipient_restrictions"`
 
 	// Client TLS
-	SMTPTLSCAPath        string
-	SMTPTLSSecurityLevel string
-	SMTPTLSCacheDB       string
+	SMTPTLSCAPath        string `toml:"smtp_tls_CApath"`
+	SMTPTLSSecurityLevel string `toml:"smtp_tls_security_level"`
+	SMTPTLSCacheDB       string `toml:"smtp_tls_session_cache_database"`
 
 	// Mail server
-	MyHostname          string
-	AliasMaps           string
-	AliasDatabase       string
-	MyOrigin            string
-	MyDestination       string
-	RelayHost           string
-	Mynetworks          string
-	MailboxCommand      string
-	MailboxSizeLimit    string
-	RecipientDelimiter  string
-	VirtualAliasDomains string
-	VirtualAliasMaps    string
-	InetInterfaces      string
-	InetProtocols       string
+	MyHostname          string `toml:"myhostname"`
+	AliasMaps           string `toml:"alias_maps"`
+	AliasDatabase       string `toml:"alias_database"`
+	MyOrigin            string `toml:"myorigin"`
+	MyDestination       string `toml:"mydestination"`
+	RelayHost           string `toml:"relayhost"`
+	Mynetworks          string `toml:"mynetworks"`
+	MailboxCommand      string `toml:"mailbox_command"`
+	MailboxSizeLimit    string `toml:"mailbox_size_limit"`
+	RecipientDelimiter  string `toml:"recipient_delimiter"`
+	VirtualAliasDomains string `toml:"virtual_alias_domains"`
+	VirtualAliasMaps    string `toml:"virtual_alias_maps"`
+	InetInterfaces      string `toml:"inet_interfaces"`
+	InetProtocols       string `toml:"inet_protocols"`
 }
 
-func GenerateMainCF(cfg Config, outputPath string) error {
-	tmpl, err := template.ParseFiles("internal/postfix/templates/main.cf.tmpl")
-	if err != nil {
-		return err
-	}
+//go:embed templates/main.cf.tmpl
+var templates embed.FS
 
-	f, err := os.Create(outputPath)
-	if err != nil {
-		return err
+var mainCFTemplate = template.Must(template.ParseFS(templates, "templates/main.cf.tmpl"))
+
+// RenderMainCF returns main.cf for cfg.
+func RenderMainCF(cfg Config) (string, error) {
+	var b strings.Builder
+	if err := mainCFTemplate.Execute(&b, cfg); err != nil {
+		return "", err
 	}
-	defer f.Close()
+	return b.String(), nil
+}
 
-	return tmpl.Execute(f, cfg)
+// Validate checks cfg before it is written: the settings a working setup
+// needs are present, values fit on one line, and the TLS certificate and
+// key exist unless TLS is disabled.
+func Validate(cfg Config) error {
+	if cfg.MyHostname == "" {
+		return errors.New("myhostname is empty: configure at least one domain")
+	}
+	if cfg.VirtualAliasDomains == "" {
+		return errors.New("virtual_alias_domains is empty: configure at least one domain")
+	}
+	fields := reflect.ValueOf(cfg)
+	for i := range fields.NumField() {
+		if strings.ContainsAny(fields.Field(i).String(), "\r\n") {
+			name := fields.Type().Field(i).Tag.Get("toml")
+			return fmt.Errorf("%s must not contain line breaks", name)
+		}
+	}
+	if cfg.TlSSecurityLevel != "none" {
+		for _, file := range []string{cfg.TlSCertFile, cfg.TlSKeyFile} {
+			if file == "" {
+				return errors.New("smtpd_tls_cert_file and smtpd_tls_key_file are required unless smtpd_tls_security_level is none")
+			}
+			if _, err := os.Stat(file); err != nil {
+				return fmt.Errorf("TLS file: %w", err)
+			}
+		}
+	}
+	return nil
 }
 
-func GenerateVirtualRegexpFile(domains []string, filePath string) error {
+// RenderVirtualRegexp returns the virtual alias map that sends every address
+// on domains to the catchall alias of its domain.
+func RenderVirtualRegexp(domains []string) string {
 	var lines []string
 
 	for _, d := range domains {
@@ -72,60 +115,72 @@ func GenerateVirtualRegexpFile(domains []string, filePath string) error {
 		lines = append(lines, line)
 	}
 
-	content := strings.Join(lines, "\n") + "\n"
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// InstalledDomains returns the domains of the installed virtual_regexp, in
+// the order RenderVirtualRegexp wrote them.
+func InstalledDomains() ([]string, error) {
+	content, err := os.ReadFile(VirtualRegexpPath)
+	if err != nil {
+		return nil, err
+	}
+	return parseVirtualRegexp(string(content)), nil
+}
 
-	return os.WriteFile(filePath, []byte(content), 0o644)
+func parseVirtualRegexp(content string) []string {
+	var domains []string
+	for _, line := range strings.Split(content, "\n") {
+		if _, domain, ok := strings.Cut(line, " catchall@"); ok {
+			domains = append(domains, strings.TrimSpace(domain))
+		}
+	}
+	return domains
 }
 
-func GenerateAliasesFile(filePath string) error {
-	content := `# See man 5 aliases for format
+// RenderAliases returns /etc/aliases, piping the catchall alias to the
+// forward script.
+func RenderAliases() string {
+	return `# See man 5 aliases for format
 postmaster:    root
-catchall: "|/usr/local/bin/forward-to-webhook.sh"
+catchall: "|` + ForwardScriptPath + `"
 `
-
-	return os.WriteFile(filePath, []byte(content), 0o644)
 }
 
-func GenerateForwardScript(filePath string, cfg *config.Config) error {
+// RenderForwardScript returns the pipe command for the catchall alias. It
+// hands the message to the "forward" subcommand of executable, which signs
+// the request itself, so the secret never appears in the script or in the
+// process list.
+func RenderForwardScript(executable, configPath string) string {
 	content := `#!/bin/bash
-sed '/Content-Disposition: attachment/,/^\s*$/d; /Content-Disposition: inline/,/^\s*$/d' | \
-curl -X POST -H "Content-Type: text/plain" -H "Secret: %s" --data-binary @- http://localhost:%d/webhook
+exec %s forward -config %s
 `
-	script := fmt.Sprintf(content, strconv.Itoa(cfg.Server.Secret), cfg.Server.Port)
-
-	if err := os.WriteFile(filePath, []byte(script), 0o755); err != nil {
-		return err
-	}
-	return nil
+	return fmt.Sprintf(content, shellQuote(executable), shellQuote(configPath))
 }
 
-// DefaultConfig returns the default configuration for Postfix.
-func DefaultConfig() Config {
-	cfg, err := config.LoadConfig("config.toml")
-	if err != nil {
-		panic(err)
-	}
-	var domains string
-	var mailSubdomains string
-
-	if len(cfg.Domains.Aliases) > 0 {
-		domains = strings.Join(cfg.Domains.Aliases, ", ")
+// shellQuote quotes s as a single word for the shell, which expands nothing
+// inside single quotes.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
 
-		var prefixed []string
-		for _, d := range cfg.Domains.Aliases[1:] {
-			prefixed = append(prefixed, "mail."+d)
+// DefaultConfig returns the Postfix configuration for the active domains,
+// with the overrides from its [postfix] section applied.
+func DefaultConfig(cfg *config.Config) (Config, error) {
+	active := cfg.ActiveDomains()
+	domains := strings.Join(active, ", ")
+	var myHostname string
+	var destinations []string
+
+	if len(active) > 0 {
+		myHostname = "mail." + active[0]
+		destinations = append(destinations, "$myhostname")
+		for _, d := range active[1:] {
+			destinations = append(destinations, "mail."+d)
 		}
-		mailSubdomains = strings.Join(prefixed, ", ")
-	}
-	err = GenerateVirtualRegexpFile(cfg.Domains.Aliases, "/etc/postfix/virtual_regexp")
-	if err != nil {
-		panic(err)
-	}
-	err = GenerateForwardScript("/usr/local/bin/forward-to-webhook.sh", cfg)
-	if err != nil {
-		panic(err)
+		destinations = append(destinations, domains, "localhost."+active[0], "localhost")
 	}
-	return Config{
+	c := Config{
 		// General
 		Banner:             "$myhostname ESMTP $mail_name (Ubuntu)",
 		Biff:               "no",
@@ -146,24 +201,23 @@ func DefaultConfig() Config {
 		SMTPTLSCacheDB:       "btree:${data_directory}/smtp_scache",
 
 		// Mail server
-		MyHostname:    fmt.Sprintf("mail.%s", cfg.Domains.Aliases[0]),
-		AliasMaps:     "hash:/etc/aliases",
-		AliasDatabase: "hash:/etc/aliases",
-		MyOrigin:      "/etc/mailname",
-		MyDestination: fmt.Sprintf(
-			"$myhostname, %s, %s, localhost.%s, localhost",
-			mailSubdomains,
-			domains,
-			cfg.Domains.Aliases[0],
-		),
+		MyHostname:          myHostname,
+		AliasMaps:           "hash:/etc/aliases",
+		AliasDatabase:       "hash:/etc/aliases",
+		MyOrigin:            "/etc/mailname",
+		MyDestination:       strings.Join(destinations, ", "),
 		RelayHost:           "",
 		Mynetworks:          "127.0.0.0/8 [::ffff:127.0.0.0]/104 [::1]/128",
 		MailboxCommand:      "procmail -a \"$EXTENSION\"",
 		MailboxSizeLimit:    "0",
 		RecipientDelimiter:  "+",
 		VirtualAliasDomains: domains,
-		VirtualAliasMaps:    "regexp:/etc/postfix/virtual_regexp",
+		VirtualAliasMaps:    "regexp:" + VirtualRegexpPath,
 		InetInterfaces:      "all",
 		InetProtocols:       "all",
 	}
+	if err := cfg.DecodePostfix(&c); err != nil {
+		return Config{}, err
+	}
+	return c, nil
 }
@@ -0,0 +1,123 @@
+package postfix
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pageton/temp-mail/config"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// golden compares got with testdata/name, or rewrites it with -update.
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from the golden file (run go test -update):\n%s",
+			name, unifiedDiff(path, "got", string(want), got))
+	}
+}
+
+func renderGolden(t *testing.T) []File {
+	t.Helper()
+	cfg, err := config.LoadConfig(filepath.Join("testdata", "config.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := Render(cfg, "/etc/temp-mail/config.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestRenderGolden(t *testing.T) {
+	var b strings.Builder
+	for _, f := range renderGolden(t) {
+		fmt.Fprintf(&b, "==> %s (%o) <==\n%s\n", f.Path, f.Mode, f.Content)
+	}
+	golden(t, "render.golden", b.String())
+}
+
+func TestDiffGolden(t *testing.T) {
+	installed, err := os.ReadFile(filepath.Join("testdata", "installed-main.cf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mainCF File
+	for _, f := range renderGolden(t) {
+		if f.Path == MainCFPath {
+			mainCF = f
+		}
+	}
+	golden(t, "diff.golden", unifiedDiff(MainCFPath, MainCFPath, string(installed), mainCF.Content))
+
+	if diff := unifiedDiff(MainCFPath, MainCFPath, mainCF.Content, mainCF.Content); diff != "" {
+		t.Errorf("diff of identical files = %q, want none", diff)
+	}
+	if diff := unifiedDiff("/dev/null", AliasesPath, "", RenderAliases()); !strings.HasPrefix(diff, "--- /dev/null\n") {
+		t.Errorf("diff of a new file starts with %q", strings.SplitN(diff, "\n", 2)[0])
+	}
+}
+
+func TestRenderForwardScriptGolden(t *testing.T) {
+	script := RenderForwardScript("/opt/$(touch pwned)/`id`/temp-mail", `/etc/it's "temp-mail"\config.toml`)
+	golden(t, "forward.golden", script)
+}
+
+func TestRenderDefaultBinary(t *testing.T) {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfig(filepath.Join("testdata", "config.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Server.ForwardBinary = ""
+	files, err := Render(cfg, "config.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := files[len(files)-1]
+	if script.Path != ForwardScriptPath || !strings.Contains(script.Content, executable) {
+		t.Errorf("forward script = %q, want it to run %s", script.Content, executable)
+	}
+}
+
+func TestRenderValidates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("[postfix]\nsmtpd_tls_security_level = \"none\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Render(cfg, path); err == nil || !strings.Contains(err.Error(), "myhostname is empty") {
+		t.Errorf("Render without domains = %v, want a validation error", err)
+	}
+}
+
+func TestParseVirtualRegexp(t *testing.T) {
+	domains := []string{"example.com", "mail.example.org"}
+	if got := parseVirtualRegexp(RenderVirtualRegexp(domains)); !slices.Equal(got, domains) {
+		t.Errorf("parseVirtualRegexp = %v, want %v", got, domains)
+	}
+}
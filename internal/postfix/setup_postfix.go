@@ -2,10 +2,26 @@
 package postfix
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pageton/temp-mail/config"
 )
 
+// BackupDir is where Apply keeps a copy of the files it replaces, one
+// timestamped directory per run.
+const BackupDir = "/var/backups/temp-mail/postfix"
+
+const manifestName = "manifest.json"
+
 func RestartPostfix() error {
 	cmd := exec.Command("sudo", "systemctl", "restart", "postfix")
 	cmd.Stdout = nil
@@ -20,54 +36,272 @@ func RunNewAliases() error {
 	return cmd.Run()
 }
 
-func MakeForwardScriptExecutable(path string) error {
-	cmd := exec.Command("sudo", "chmod", "+x", path)
-	return cmd.Run()
+// Check returns an error when postfix or procmail is not installed.
+func Check() error {
+	if _, err := exec.LookPath("postfix"); err != nil {
+		return err
+	}
+	if _, err := exec.LookPath("procmail"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// File is one rendered configuration file.
+type File struct {
+	Path    string
+	Content string
+	Mode    os.FileMode
 }
 
-func SetupPostfix() error {
-	forwardScriptPath := "/usr/local/bin/forward-to-webhook.sh"
-	_, err := exec.LookPath("postfix")
+// Render returns the Postfix configuration files for cfg without writing
+// them. The forward script runs server.forward_binary, or the running binary
+// when it is not set, and passes configPath to its forward subcommand.
+// Configurations that Validate rejects are not rendered.
+func Render(cfg *config.Config, configPath string) ([]File, error) {
+	executable := cfg.Server.ForwardBinary
+	if executable == "" {
+		var err error
+		if executable, err = os.Executable(); err != nil {
+			return nil, err
+		}
+	}
+	executable, err := filepath.Abs(executable)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	_, err = exec.LookPath("procmail")
+	configPath, err = filepath.Abs(configPath)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	c, err := DefaultConfig(cfg)
+	if err != nil {
+		return nil, err
 	}
+	if err = Validate(c); err != nil {
+		return nil, fmt.Errorf("invalid Postfix configuration: %w", err)
+	}
+	mainCF, err := RenderMainCF(c)
+	if err != nil {
+		return nil, err
+	}
+	return []File{
+		{MainCFPath, mainCF, 0o644},
+		{VirtualRegexpPath, RenderVirtualRegexp(cfg.ActiveDomains()), 0o644},
+		{AliasesPath, RenderAliases(), 0o644},
+		{ForwardScriptPath, RenderForwardScript(executable, configPath), 0o755},
+	}, nil
+}
 
-	// Remove existing files
+// WriteDir writes files below dir, keeping their absolute paths, so that
+// dir/etc/postfix/main.cf can be inspected or copied by other tooling.
+func WriteDir(files []File, dir string) error {
+	for _, f := range files {
+		if err := writeFile(filepath.Join(dir, f.Path), f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	files := []string{
-		"/etc/postfix/main.cf",
-		"/etc/postfix/virtual_regexp",
-		"/etc/aliases",
-		forwardScriptPath,
+// Diff returns a unified diff from the installed files to files, or "" when
+// everything is up to date. Missing files are diffed against /dev/null.
+func Diff(files []File) (string, error) {
+	var diff string
+	for _, f := range files {
+		installed, err := os.ReadFile(f.Path)
+		oldName := f.Path
+		if errors.Is(err, fs.ErrNotExist) {
+			oldName = "/dev/null"
+		} else if err != nil {
+			return "", err
+		}
+		diff += unifiedDiff(oldName, f.Path, string(installed), f.Content)
 	}
+	return diff, nil
+}
 
+// backupEntry records one file replaced by Apply. Files that did not exist
+// are removed again on rollback.
+type backupEntry struct {
+	Path    string      `json:"path"`
+	Existed bool        `json:"existed"`
+	Mode    os.FileMode `json:"mode"`
+}
+
+// Apply backs up the installed files to a new directory below backupRoot,
+// writes files, rebuilds the aliases and restarts Postfix. When any step
+// fails the backup is restored. It returns the backup directory.
+func Apply(files []File, backupRoot string) (string, error) {
+	backup, err := backupFiles(files, backupRoot)
+	if err != nil {
+		return "", fmt.Errorf("backing up: %w", err)
+	}
+	if err = install(files); err != nil {
+		if rerr := Rollback(backup); rerr != nil {
+			return backup, fmt.Errorf("%w (rollback failed: %v)", err, rerr)
+		}
+		return backup, fmt.Errorf("%w (previous configuration restored)", err)
+	}
+	return backup, nil
+}
+
+func install(files []File) error {
 	for _, f := range files {
-		if err = os.Remove(f); err != nil && !os.IsNotExist(err) {
+		if err := writeFile(f.Path, f); err != nil {
 			return err
 		}
 	}
+	if err := RunNewAliases(); err != nil {
+		return fmt.Errorf("newaliases: %w", err)
+	}
+	if err := RestartPostfix(); err != nil {
+		return fmt.Errorf("restarting postfix: %w", err)
+	}
+	return nil
+}
 
-	cfg := DefaultConfig()
-	GenerateMainCF(cfg, "/etc/postfix/main.cf")
-	GenerateAliasesFile("/etc/aliases")
+func backupFiles(files []File, backupRoot string) (string, error) {
+	dir := filepath.Join(backupRoot, time.Now().UTC().Format("20060102T150405.000Z"))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	var manifest []backupEntry
+	for _, f := range files {
+		entry := backupEntry{Path: f.Path}
+		info, err := os.Stat(f.Path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return "", err
+		default:
+			content, err := os.ReadFile(f.Path)
+			if err != nil {
+				return "", err
+			}
+			entry.Existed, entry.Mode = true, info.Mode().Perm()
+			err = writeFile(filepath.Join(dir, f.Path), File{Content: string(content), Mode: entry.Mode})
+			if err != nil {
+				return "", err
+			}
+		}
+		manifest = append(manifest, entry)
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return dir, os.WriteFile(filepath.Join(dir, manifestName), data, 0o600)
+}
 
-	err = RestartPostfix()
+// Rollback restores the files saved in backup by Apply, removes the ones
+// Apply created, and restarts Postfix.
+func Rollback(backup string) error {
+	data, err := os.ReadFile(filepath.Join(backup, manifestName))
 	if err != nil {
 		return err
 	}
-	err = RunNewAliases()
-	if err != nil {
+	var manifest []backupEntry
+	if err = json.Unmarshal(data, &manifest); err != nil {
 		return err
 	}
+	for _, entry := range manifest {
+		if !entry.Existed {
+			if err := os.Remove(entry.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(backup, entry.Path))
+		if err != nil {
+			return err
+		}
+		if err = writeFile(entry.Path, File{Content: string(content), Mode: entry.Mode}); err != nil {
+			return err
+		}
+	}
+	if err = RunNewAliases(); err != nil {
+		return fmt.Errorf("newaliases: %w", err)
+	}
+	return RestartPostfix()
+}
 
-	err = MakeForwardScriptExecutable(forwardScriptPath)
+// LatestBackup returns the newest backup directory below backupRoot.
+func LatestBackup(backupRoot string) (string, error) {
+	entries, err := os.ReadDir(backupRoot)
 	if err != nil {
-		return err
+		return "", err
 	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("no backups in %s", backupRoot)
+	}
+	sort.Strings(names)
+	return filepath.Join(backupRoot, names[len(names)-1]), nil
+}
 
+// writeFile replaces path atomically with the content and mode of f.
+func writeFile(path string, f File) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(f.Content), f.Mode); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file and applies the umask.
+	if err := os.Chmod(tmp, f.Mode); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
 	return nil
 }
+
+// Setup validates and renders the Postfix configuration for cfg and applies
+// it, keeping a backup below backupRoot. Nothing is written when validation
+// fails. It returns the backup directory.
+func Setup(cfg *config.Config, configPath, backupRoot string) (string, error) {
+	if err := Check(); err != nil {
+		return "", err
+	}
+	files, err := Render(cfg, configPath)
+	if err != nil {
+		return "", err
+	}
+	return Apply(files, backupRoot)
+}
+
+var applyDomainsMu sync.Mutex
+
+// ApplyDomains rewrites main.cf and virtual_regexp for the current active
+// domains and restarts Postfix. It is used when domains change at runtime,
+// where the forward script and aliases stay as they are.
+func ApplyDomains(cfg *config.Config, backupRoot string) (string, error) {
+	applyDomainsMu.Lock()
+	defer applyDomainsMu.Unlock()
+
+	c, err := DefaultConfig(cfg)
+	if err != nil {
+		return "", err
+	}
+	if err = Validate(c); err != nil {
+		return "", fmt.Errorf("invalid Postfix configuration: %w", err)
+	}
+	mainCF, err := RenderMainCF(c)
+	if err != nil {
+		return "", err
+	}
+	return Apply([]File{
+		{MainCFPath, mainCF, 0o644},
+		{VirtualRegexpPath, RenderVirtualRegexp(cfg.ActiveDomains()), 0o644},
+	}, backupRoot)
+}
@@ -0,0 +1,163 @@
+// Package pubsub contains the in-process event hub for the application.
+package pubsub
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventReceived EventType = "email.received"
+	EventDeleted  EventType = "email.deleted"
+	EventExpired  EventType = "email.expired"
+	// EventDomainsChanged tells every process that the domains added
+	// through the admin API changed. It carries no address and only reaches
+	// subscriptions from SubscribeDomainChanges.
+	EventDomainsChanged EventType = "domains.changed"
+)
+
+// Event describes a change to one inbox entry. Deletion and expiry events
+// only carry the address and inbox ID.
+type Event struct {
+	Type        EventType
+	Address     string
+	InboxID     string
+	EmailID     int64
+	Subject     string
+	FromAddress string
+	ToAddress   string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// subscriptionBuffer is how many events a subscriber may fall behind before
+// further events are dropped for it.
+const subscriptionBuffer = 64
+
+// Hub fans events out to subscribers. Subscriptions match events by address
+// or by the domain of the address.
+type Hub struct {
+	mu    sync.RWMutex
+	subs  map[*Subscription]struct{}
+	relay *Relay // Forwards published events to other processes, if set
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe returns a subscription for the given addresses and domains.
+// The caller must Close it when done.
+func (h *Hub) Subscribe(topics ...string) *Subscription {
+	s := &Subscription{
+		hub:    h,
+		events: make(chan Event, subscriptionBuffer),
+		topics: make(map[string]struct{}),
+	}
+	s.Add(topics...)
+
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+// SubscribeDomainChanges returns a subscription for EventDomainsChanged.
+// The caller must Close it when done.
+func (h *Hub) SubscribeDomainChanges() *Subscription {
+	s := h.Subscribe()
+	s.domainChanges = true
+	return s
+}
+
+// Publish delivers e to every matching subscriber without blocking, and
+// to the other processes when the hub has a Relay. A nil hub discards
+// events.
+func (h *Hub) Publish(e Event) {
+	if h == nil {
+		return
+	}
+	h.deliver(e)
+	if h.relay != nil {
+		h.relay.send(e)
+	}
+}
+
+// deliver delivers e to the subscribers of this process.
+func (h *Hub) deliver(e Event) {
+	address := strings.ToLower(e.Address)
+	domain := address[strings.LastIndex(address, "@")+1:]
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for s := range h.subs {
+		if e.Type == EventDomainsChanged {
+			if !s.domainChanges {
+				continue
+			}
+		} else if !s.matches(address, domain) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			// Slow subscriber; drop rather than stall ingestion.
+		}
+	}
+}
+
+// Subscription receives the events for a changing set of topics.
+type Subscription struct {
+	hub    *Hub
+	events chan Event
+	once   sync.Once
+
+	mu     sync.RWMutex
+	topics map[string]struct{}
+
+	domainChanges bool // Receives EventDomainsChanged only
+}
+
+// Events returns the channel of matching events. It is closed by Close.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Add subscribes to more addresses or domains.
+func (s *Subscription) Add(topics ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range topics {
+		s.topics[strings.ToLower(t)] = struct{}{}
+	}
+}
+
+// Remove unsubscribes from addresses or domains.
+func (s *Subscription) Remove(topics ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range topics {
+		delete(s.topics, strings.ToLower(t))
+	}
+}
+
+// Close detaches the subscription from the hub and closes its channel.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.mu.Lock()
+		delete(s.hub.subs, s)
+		s.hub.mu.Unlock()
+		close(s.events)
+	})
+}
+
+func (s *Subscription) matches(address, domain string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, byAddress := s.topics[address]
+	_, byDomain := s.topics[domain]
+	return byAddress || byDomain
+}
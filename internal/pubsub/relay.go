@@ -0,0 +1,161 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lucsky/cuid"
+
+	"github.com/pageton/temp-mail/internal/db"
+)
+
+const (
+	// relayInterval is how often a Relay reads the events of other processes.
+	relayInterval = 500 * time.Millisecond
+	// relayBatch is the most events read per poll.
+	relayBatch = 500
+	// relayGapTimeout is how long a Relay waits for a skipped event ID to
+	// show up. IDs are taken when an insert starts, so on PostgreSQL a lower
+	// ID can commit after a higher one; rolled back inserts leave gaps that
+	// never fill.
+	relayGapTimeout = 10 * time.Second
+)
+
+// RelayRetention is how long relayed events are kept in the database. It
+// bounds how far a Relay that fell behind can catch up.
+const RelayRetention = time.Minute
+
+// Relay shares the events of a Hub with the hubs of other processes using
+// the same database: prefork processes and replicas. Published events are
+// written to the Event table and every Relay polls it for the events of the
+// others. Like the hub, it is best effort: events that arrive while a
+// process is starting or after it fell RelayRetention behind are missed.
+type Relay struct {
+	hub     *Hub
+	queries db.Querier
+	origin  string // Identifies this process in the Event table
+	last    int64  // Highest event ID read
+	// missing holds the IDs below last that have not been read yet, with
+	// when they were first missed.
+	missing map[int64]time.Time
+}
+
+// NewRelay attaches a relay to hub. Events published on hub from then on are
+// forwarded; call Start to receive those of the other processes.
+func NewRelay(ctx context.Context, hub *Hub, queries db.Querier) (*Relay, error) {
+	last, err := queries.GetLastEventID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r := &Relay{
+		hub:     hub,
+		queries: queries,
+		origin:  cuid.New(),
+		last:    last,
+		missing: make(map[int64]time.Time),
+	}
+	hub.relay = r
+	return r, nil
+}
+
+// Start polls for the events of other processes until ctx is done.
+func (r *Relay) Start(ctx context.Context) {
+	ticker := time.NewTicker(relayInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := r.Poll(ctx); err != nil {
+					log.Println("Error reading relayed events:", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Poll delivers the events published by other processes since the last
+// poll to the subscribers of this one. Events that commit after an event
+// with a higher ID are delivered by a later poll, each event only once.
+func (r *Relay) Poll(ctx context.Context) error {
+	now := time.Now()
+	after := r.last
+	for id := range r.missing {
+		after = min(after, id-1)
+	}
+	for {
+		rows, err := r.queries.GetEventsAfter(ctx, db.GetEventsAfterParams{
+			After: after,
+			Limit: relayBatch,
+		})
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			after = row.ID
+			r.receive(row, now)
+		}
+		if len(rows) < relayBatch {
+			break
+		}
+	}
+	for id, missed := range r.missing {
+		if now.Sub(missed) > relayGapTimeout {
+			delete(r.missing, id)
+		}
+	}
+	return nil
+}
+
+// receive delivers row unless it was read before.
+func (r *Relay) receive(row db.GetEventsAfterRow, now time.Time) {
+	switch {
+	case row.ID > r.last:
+		// A larger jump is not explained by concurrent inserts.
+		if row.ID-r.last <= relayBatch {
+			for id := r.last + 1; id < row.ID; id++ {
+				r.missing[id] = now
+			}
+		}
+		r.last = row.ID
+	case r.isMissing(row.ID):
+		delete(r.missing, row.ID)
+	default:
+		return
+	}
+	if row.Origin == r.origin {
+		return
+	}
+	var e Event
+	if err := json.Unmarshal([]byte(row.Payload), &e); err != nil {
+		log.Println("Error decoding relayed event:", err)
+		return
+	}
+	r.hub.deliver(e)
+}
+
+func (r *Relay) isMissing(id int64) bool {
+	_, ok := r.missing[id]
+	return ok
+}
+
+// send writes e to the Event table for the other processes.
+func (r *Relay) send(e Event) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		log.Println("Error encoding event:", err)
+		return
+	}
+	err = r.queries.InsertEvent(context.Background(), db.InsertEventParams{
+		Origin:    r.origin,
+		Payload:   string(payload),
+		CreatedAt: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		log.Println("Error relaying event:", err)
+	}
+}
@@ -0,0 +1,161 @@
+package pubsub_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pageton/temp-mail/internal/pubsub"
+	"github.com/pageton/temp-mail/internal/storage/storagetest"
+)
+
+// TestRelay stands in for two prefork processes: two hubs with relays on one
+// database.
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+	queries, _ := storagetest.SQLite(t)
+	parent, child := pubsub.NewHub(), pubsub.NewHub()
+	parentRelay, err := pubsub.NewRelay(ctx, parent, queries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	childRelay, err := pubsub.NewRelay(ctx, child, queries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentSub := parent.Subscribe("alice@example.com")
+	defer parentSub.Close()
+	childSub := child.Subscribe("example.com")
+	defer childSub.Close()
+
+	sent := pubsub.Event{
+		Type:      pubsub.EventReceived,
+		Address:   "alice@example.com",
+		InboxID:   "inbox",
+		Subject:   "Hello",
+		CreatedAt: time.UnixMilli(1_700_000_000_000).UTC(),
+	}
+	parent.Publish(sent)
+	if got := <-parentSub.Events(); got.InboxID != sent.InboxID {
+		t.Errorf("local event = %+v, want %+v", got, sent)
+	}
+
+	if err := childRelay.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-childSub.Events():
+		if got != sent {
+			t.Errorf("relayed event = %+v, want %+v", got, sent)
+		}
+	default:
+		t.Fatal("event not relayed to the other hub")
+	}
+
+	// A relay skips its own events and reads each event once.
+	if err := parentRelay.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := childRelay.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-parentSub.Events():
+		t.Errorf("own event delivered again: %+v", e)
+	case e := <-childSub.Events():
+		t.Errorf("event relayed twice: %+v", e)
+	default:
+	}
+}
+
+// TestRelayLateCommit inserts events with explicit IDs to stand in for a
+// lower ID that commits after a higher one has been read.
+func TestRelayLateCommit(t *testing.T) {
+	ctx := context.Background()
+	queries, database := storagetest.SQLite(t)
+	hub := pubsub.NewHub()
+	relay, err := pubsub.NewRelay(ctx, hub, queries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := hub.Subscribe("example.com")
+	defer sub.Close()
+
+	insert := func(id int64, inboxID string) {
+		t.Helper()
+		payload := fmt.Sprintf(`{"type":"received","address":"alice@example.com","inboxId":%q}`, inboxID)
+		_, err := database.ExecContext(ctx,
+			"INSERT INTO Event (id, origin, payload, createdAt) VALUES (?, 'other', ?, 0)", id, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	poll := func() []string {
+		t.Helper()
+		if err := relay.Poll(ctx); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for {
+			select {
+			case e := <-sub.Events():
+				got = append(got, e.InboxID)
+			default:
+				return got
+			}
+		}
+	}
+
+	insert(3, "third")
+	if got := poll(); len(got) != 1 || got[0] != "third" {
+		t.Fatalf("first poll = %v, want third", got)
+	}
+	insert(1, "first")
+	if got := poll(); len(got) != 1 || got[0] != "first" {
+		t.Errorf("poll after the late commit = %v, want first", got)
+	}
+	insert(2, "second")
+	insert(4, "fourth")
+	if got := poll(); len(got) != 2 || got[0] != "second" || got[1] != "fourth" {
+		t.Errorf("last poll = %v, want second and fourth", got)
+	}
+	if got := poll(); len(got) != 0 {
+		t.Errorf("poll without new events = %v, want none", got)
+	}
+}
+
+func TestRelayDomainChanges(t *testing.T) {
+	ctx := context.Background()
+	queries, _ := storagetest.SQLite(t)
+	parent, child := pubsub.NewHub(), pubsub.NewHub()
+	parentRelay, err := pubsub.NewRelay(ctx, parent, queries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pubsub.NewRelay(ctx, child, queries); err != nil {
+		t.Fatal(err)
+	}
+	changes := parent.SubscribeDomainChanges()
+	defer changes.Close()
+	mail := parent.Subscribe("example.com", "")
+	defer mail.Close()
+
+	child.Publish(pubsub.Event{Type: pubsub.EventDomainsChanged})
+	if err := parentRelay.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-changes.Events():
+		if e.Type != pubsub.EventDomainsChanged {
+			t.Errorf("event = %+v, want a domain change", e)
+		}
+	default:
+		t.Fatal("domain change not relayed")
+	}
+	select {
+	case e := <-mail.Events():
+		t.Errorf("domain change delivered to a mail subscription: %+v", e)
+	default:
+	}
+}
@@ -0,0 +1,143 @@
+// Package retention contains the mail retention rules for the application.
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/utils"
+)
+
+// For returns how long mail for address is kept: the address override set
+// through the API, else the retention of its domain.
+func For(ctx context.Context, cfg *config.Config, queries db.Querier, address string) (time.Duration, error) {
+	ms, err := queries.GetAddressRetention(ctx, address)
+	if errors.Is(err, sql.ErrNoRows) {
+		return cfg.RetentionFor(utils.EmailDomain(address)), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// ExpiresAt returns when a message received at createdAt for all of
+// addresses expires. A message shared by several inboxes is kept for the
+// longest of their retentions.
+func ExpiresAt(
+	ctx context.Context,
+	cfg *config.Config,
+	queries db.Querier,
+	addresses []string,
+	createdAt time.Time,
+) (time.Time, error) {
+	var longest time.Duration
+	for _, address := range addresses {
+		d, err := For(ctx, cfg, queries, address)
+		if err != nil {
+			return time.Time{}, err
+		}
+		longest = max(longest, d)
+	}
+	return createdAt.Add(longest), nil
+}
+
+// SetForAddress overrides the retention of address for future mail and
+// moves the expiry of its stored mail to match. A message shared with other
+// recipients is kept for the longest of their retentions, so shortening it
+// for one address does not delete it for the others. It returns the number
+// of messages updated.
+func SetForAddress(
+	ctx context.Context,
+	cfg *config.Config,
+	queries db.Querier,
+	address string,
+	d time.Duration,
+) (int, error) {
+	err := queries.UpsertAddressRetention(ctx, db.UpsertAddressRetentionParams{
+		Address:   address,
+		Retention: d.Milliseconds(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	emails, err := queries.GetEmailTimesForAddress(ctx, sql.NullString{String: address, Valid: true})
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range emails {
+		recipients, err := queries.GetEmailRecipients(ctx, sql.NullInt64{Int64: e.ID, Valid: true})
+		if err != nil {
+			return 0, err
+		}
+		expiresAt, err := sharedExpiry(ctx, cfg, queries, recipients, address, time.UnixMilli(e.Createdat), d)
+		if err != nil {
+			return 0, err
+		}
+		err = queries.UpdateEmailExpiry(ctx, db.UpdateEmailExpiryParams{
+			ID:        e.ID,
+			ExpiresAt: expiresAt.UnixMilli(),
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(emails), nil
+}
+
+// SetForInbox keeps the message of an inbox entry for d after createdAt, or
+// for longer when another recipient's retention requires it. It returns the
+// new expiry.
+func SetForInbox(
+	ctx context.Context,
+	cfg *config.Config,
+	queries db.Querier,
+	inboxID, address string,
+	createdAt time.Time,
+	d time.Duration,
+) (time.Time, error) {
+	recipients, err := queries.GetInboxRecipients(ctx, inboxID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	expiresAt, err := sharedExpiry(ctx, cfg, queries, recipients, address, createdAt, d)
+	if err != nil {
+		return time.Time{}, err
+	}
+	err = queries.UpdateInboxEmailExpiry(ctx, db.UpdateInboxEmailExpiryParams{
+		InboxID:   inboxID,
+		ExpiresAt: expiresAt.UnixMilli(),
+	})
+	return expiresAt, err
+}
+
+// sharedExpiry returns when a message received at createdAt expires when
+// address keeps it for d and every other recipient for its own retention.
+func sharedExpiry(
+	ctx context.Context,
+	cfg *config.Config,
+	queries db.Querier,
+	recipients []sql.NullString,
+	address string,
+	createdAt time.Time,
+	d time.Duration,
+) (time.Time, error) {
+	var others []string
+	for _, r := range recipients {
+		if r.Valid && r.String != address {
+			others = append(others, r.String)
+		}
+	}
+	expiresAt, err := ExpiresAt(ctx, cfg, queries, others, createdAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if own := createdAt.Add(d); own.After(expiresAt) {
+		expiresAt = own
+	}
+	return expiresAt, nil
+}
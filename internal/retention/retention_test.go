@@ -0,0 +1,89 @@
+package retention_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/retention"
+	"github.com/pageton/temp-mail/internal/storage"
+	"github.com/pageton/temp-mail/internal/storage/storagetest"
+)
+
+// storeShared stores one message received at createdAt for both alice and
+// bob and returns the inbox IDs.
+func storeShared(t *testing.T, queries storage.Store, createdAt time.Time) (string, string) {
+	t.Helper()
+	ctx := context.Background()
+	emailID, err := queries.InsertEmail(ctx, db.InsertEmailParams{
+		Createdat: createdAt.UnixMilli(),
+		Expiresat: createdAt.Add(time.Hour).UnixMilli(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"alice", "bob"} {
+		err := queries.InsertInbox(ctx, db.InsertInboxParams{
+			ID:      id,
+			Emailid: sql.NullInt64{Int64: emailID, Valid: true},
+			Address: sql.NullString{String: id + "@example.com", Valid: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return "alice", "bob"
+}
+
+func expiry(t *testing.T, queries storage.Store, inboxID string) time.Time {
+	t.Helper()
+	inbox, err := queries.GetInboxByID(context.Background(), inboxID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return time.UnixMilli(inbox.Expiresat)
+}
+
+func TestSetKeepsSharedMail(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{Domains: config.DomainsConfig{DefaultRetention: time.Hour}}
+	createdAt := time.UnixMilli(time.Now().UnixMilli())
+
+	t.Run("address", func(t *testing.T) {
+		queries, _ := storagetest.SQLite(t)
+		alice, bob := storeShared(t, queries, createdAt)
+
+		if _, err := retention.SetForAddress(ctx, cfg, queries, "alice@example.com", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := expiry(t, queries, bob), createdAt.Add(time.Hour); !got.Equal(want) {
+			t.Errorf("shortened expiry = %v, want bob's %v", got, want)
+		}
+
+		if _, err := retention.SetForAddress(ctx, cfg, queries, "alice@example.com", 2*time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := expiry(t, queries, alice), createdAt.Add(2*time.Hour); !got.Equal(want) {
+			t.Errorf("extended expiry = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("inbox", func(t *testing.T) {
+		queries, _ := storagetest.SQLite(t)
+		alice, _ := storeShared(t, queries, createdAt)
+		if _, err := retention.SetForAddress(ctx, cfg, queries, "bob@example.com", 3*time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := retention.SetForInbox(ctx, cfg, queries, alice, "alice@example.com", createdAt, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := createdAt.Add(3 * time.Hour); !got.Equal(want) || !expiry(t, queries, alice).Equal(want) {
+			t.Errorf("expiry = %v, want bob's %v", got, want)
+		}
+	})
+}
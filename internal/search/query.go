@@ -0,0 +1,137 @@
+package search
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+var ErrEmptyQuery = errors.New("empty search query")
+
+// fields maps the prefixes accepted in search queries to index columns.
+var fields = map[string]string{
+	"from":    "sender",
+	"subject": "subject",
+}
+
+// Term is one element of a parsed search query: either an operator ("OR",
+// "AND" or "NOT") or a word or phrase, optionally restricted to a column and
+// matched as a prefix.
+type Term struct {
+	Operator string
+	Column   string
+	Text     string
+	Prefix   bool
+}
+
+// Parse splits a user search query into terms. Supported syntax:
+//
+//	reset link          both words, anywhere
+//	"reset your password" exact phrase
+//	from:alice          term in the sender
+//	subject:"welcome"   phrase in the subject
+//	verif*              prefix match
+//	a OR b              either term
+func Parse(q string) ([]Term, error) {
+	var terms []Term
+	for _, token := range tokenize(q) {
+		if isOperator(token) {
+			// Of several operators in a row only the last one counts.
+			if n := len(terms); n > 0 && terms[n-1].Operator != "" {
+				terms[n-1].Operator = token
+			} else {
+				terms = append(terms, Term{Operator: token})
+			}
+			continue
+		}
+
+		column := ""
+		if name, value, ok := strings.Cut(token, ":"); ok {
+			if c, known := fields[strings.ToLower(name)]; known {
+				column, token = c, value
+			}
+		}
+
+		prefix := strings.HasSuffix(token, "*")
+		token = strings.Trim(strings.TrimSuffix(token, "*"), `"`)
+		// Both indexes split text on everything but letters and digits, so
+		// a term of only punctuation matches nothing and, as a PostgreSQL
+		// prefix, would not even parse.
+		if !strings.ContainsFunc(token, isWordRune) {
+			continue
+		}
+		terms = append(terms, Term{Column: column, Text: token, Prefix: prefix})
+	}
+
+	// Operators are only valid between two terms.
+	for len(terms) > 0 && terms[0].Operator != "" {
+		terms = terms[1:]
+	}
+	for len(terms) > 0 && terms[len(terms)-1].Operator != "" {
+		terms = terms[:len(terms)-1]
+	}
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	return terms, nil
+}
+
+// ParseQuery translates a user search query into an FTS5 expression. Terms
+// are matched as quoted strings so punctuation in addresses and links cannot
+// break the expression.
+func ParseQuery(q string) (string, error) {
+	terms, err := Parse(q)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, 0, len(terms))
+	for _, t := range terms {
+		if t.Operator != "" {
+			parts = append(parts, t.Operator)
+			continue
+		}
+		term := `"` + strings.ReplaceAll(t.Text, `"`, `""`) + `"`
+		if t.Prefix {
+			term += "*"
+		}
+		if t.Column != "" {
+			term = t.Column + " : " + term
+		}
+		parts = append(parts, term)
+	}
+	return strings.Join(parts, " "), nil
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isOperator(term string) bool {
+	return term == "OR" || term == "AND" || term == "NOT"
+}
+
+// tokenize splits q on whitespace, keeping double-quoted phrases together,
+// including phrases that follow a field prefix such as subject:"a b".
+func tokenize(q string) []string {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
@@ -0,0 +1,106 @@
+// Package search contains the full-text search index for received mail.
+package search
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync/atomic"
+
+	"github.com/inbucket/html2text"
+
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/sqlc"
+)
+
+var enabled atomic.Bool
+
+// Setup creates the SQLite search index. SQLite builds without FTS5 leave search
+// disabled instead of failing, so the rest of the service keeps working.
+func Setup(ctx context.Context, database *sql.DB) error {
+	if _, err := database.ExecContext(ctx, sqlc.SearchSchema); err != nil {
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			return nil
+		}
+		return err
+	}
+	enabled.Store(true)
+	return nil
+}
+
+// Enabled reports whether the SQLite search index is available.
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Indexer is the storage Index writes to.
+type Indexer interface {
+	InsertSearchEntry(ctx context.Context, arg db.InsertSearchEntryParams) error
+	SearchEnabled() bool
+}
+
+// Index adds a stored inbox entry to the search index.
+func Index(ctx context.Context, index Indexer, inboxID, subject, sender, html string) error {
+	if !index.SearchEnabled() {
+		return nil
+	}
+	var htmlText string
+	if html != "" {
+		text, err := html2text.FromString(html)
+		if err != nil {
+			return err
+		}
+		htmlText = text
+	}
+	return index.InsertSearchEntry(ctx, db.InsertSearchEntryParams{
+		InboxID:  inboxID,
+		Subject:  subject,
+		Sender:   sender,
+		HtmlText: htmlText,
+	})
+}
+
+const backfillBatch = 500
+
+// Backfill indexes inbox entries stored before the search index was enabled.
+// Older mail is indexed under its sender address only, since the original
+// From header is not kept for it. It returns the number of entries indexed.
+func Backfill(ctx context.Context, database *sql.DB) (int, error) {
+	if !Enabled() {
+		return 0, nil
+	}
+	queries := db.New(database)
+	total := 0
+	for {
+		rows, err := queries.GetUnindexedInboxes(ctx, backfillBatch)
+		if err != nil {
+			return total, err
+		}
+		if len(rows) == 0 {
+			return total, nil
+		}
+		tx, err := database.BeginTx(ctx, nil)
+		if err != nil {
+			return total, err
+		}
+		qtx := queries.WithTx(tx)
+		for _, row := range rows {
+			// Unconvertible HTML is indexed without its text so the entry
+			// is not picked up again on the next batch.
+			htmlText, _ := html2text.FromString(row.Htmlcontent)
+			if err := qtx.InsertSearchEntry(ctx, db.InsertSearchEntryParams{
+				InboxID:  row.ID,
+				Subject:  row.Subject,
+				Sender:   row.Sender,
+				HtmlText: htmlText,
+			}); err != nil {
+				tx.Rollback()
+				return total, err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return total, err
+		}
+		total += len(rows)
+	}
+}
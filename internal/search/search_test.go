@@ -0,0 +1,64 @@
+package search_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/search"
+	"github.com/pageton/temp-mail/internal/storage/storagetest"
+)
+
+func TestBackfill(t *testing.T) {
+	store, database := storagetest.SQLite(t)
+	if !search.Enabled() {
+		t.Skip("SQLite built without FTS5; run with -tags sqlite_fts5")
+	}
+	ctx := context.Background()
+
+	// Store a message the way releases without the index did.
+	emailID, err := store.InsertEmail(ctx, db.InsertEmailParams{
+		Subject:   sql.NullString{String: "Quarterly invoice", Valid: true},
+		Createdat: 1,
+		Expiresat: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.InsertEmailAddress(ctx, db.InsertEmailAddressParams{
+		Emailid: sql.NullInt64{Int64: emailID, Valid: true},
+		Type:    sql.NullString{String: "from", Valid: true},
+		Address: sql.NullString{String: "billing@example.com", Valid: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.InsertInbox(ctx, db.InsertInboxParams{
+		ID:          "inbox-1",
+		Emailid:     sql.NullInt64{Int64: emailID, Valid: true},
+		Address:     sql.NullString{String: "user@example.com", Valid: true},
+		Htmlcontent: sql.NullString{String: "<p>Amount <b>due</b></p>", Valid: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []int{1, 0} {
+		got, err := search.Backfill(ctx, database)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("Backfill indexed %d entries, want %d", got, want)
+		}
+	}
+
+	for _, query := range []string{"invoice", "due", "from:billing"} {
+		rows, err := store.Search(ctx, "user@example.com", query, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || rows[0].ID != "inbox-1" {
+			t.Errorf("Search(%q) = %+v, want inbox-1", query, rows)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Package smtpd contains the built-in SMTP listener for the application.
+package smtpd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/mail"
+	"time"
+
+	"github.com/emersion/go-smtp"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/ingest"
+	"github.com/pageton/temp-mail/internal/pubsub"
+	"github.com/pageton/temp-mail/internal/storage"
+	"github.com/pageton/temp-mail/internal/utils"
+)
+
+var errUnknownDomain = &smtp.SMTPError{
+	Code:         550,
+	EnhancedCode: smtp.EnhancedCode{5, 1, 2},
+	Message:      "Recipient domain not accepted here",
+}
+
+// Backend accepts mail for the configured domains and stores it through the
+// same ingest path as the webhook.
+type Backend struct {
+	cfg     *config.Config
+	queries storage.Store
+	hub     *pubsub.Hub
+}
+
+// NewServer returns an SMTP server configured from cfg.SMTP.
+func NewServer(cfg *config.Config, queries storage.Store, hub *pubsub.Hub) *smtp.Server {
+	s := smtp.NewServer(&Backend{cfg: cfg, queries: queries, hub: hub})
+	s.Addr = fmt.Sprintf("%s:%d", cfg.SMTP.Host, cfg.SMTP.Port)
+	s.Domain = cfg.SMTP.Hostname
+	if s.Domain == "" && len(cfg.Domains.Aliases) > 0 {
+		s.Domain = "mail." + cfg.Domains.Aliases[0]
+	}
+	s.MaxMessageBytes = cfg.SMTP.MaxMessageBytes
+	s.MaxRecipients = 50
+	s.ReadTimeout = 60 * time.Second
+	s.WriteTimeout = 60 * time.Second
+	return s
+}
+
+func (b *Backend) NewSession(_ *smtp.Conn) (smtp.Session, error) {
+	return &session{backend: b}, nil
+}
+
+type session struct {
+	backend *Backend
+	rcpts   []string
+}
+
+func (s *session) Mail(_ string, _ *smtp.MailOptions) error {
+	return nil
+}
+
+func (s *session) Rcpt(to string, _ *smtp.RcptOptions) error {
+	addr, err := mail.ParseAddress(to)
+	if err != nil {
+		return &smtp.SMTPError{
+			Code:         501,
+			EnhancedCode: smtp.EnhancedCode{5, 1, 3},
+			Message:      "Malformed recipient address",
+		}
+	}
+	if !s.backend.cfg.HasDomain(utils.EmailDomain(addr.Address)) {
+		return errUnknownDomain
+	}
+	s.rcpts = append(s.rcpts, addr.Address)
+	return nil
+}
+
+func (s *session) Data(r io.Reader) error {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	emailID, err := ingest.Store(
+		context.Background(),
+		s.backend.cfg,
+		s.backend.queries,
+		s.backend.hub,
+		raw,
+		s.rcpts,
+	)
+	if err != nil {
+		log.Println("Error storing email:", err)
+		if errors.Is(err, ingest.ErrParse) || errors.Is(err, ingest.ErrNoRecipients) {
+			return &smtp.SMTPError{
+				Code:         554,
+				EnhancedCode: smtp.EnhancedCode{5, 6, 0},
+				Message:      "Message could not be parsed",
+			}
+		}
+		return &smtp.SMTPError{
+			Code:         451,
+			EnhancedCode: smtp.EnhancedCode{4, 3, 0},
+			Message:      "Message could not be stored, try again later",
+		}
+	}
+	log.Printf("Stored email %d for %v", emailID, s.rcpts)
+	return nil
+}
+
+func (s *session) Reset() {
+	s.rcpts = nil
+}
+
+func (s *session) Logout() error {
+	return nil
+}
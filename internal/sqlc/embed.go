@@ -1,7 +1,13 @@
-// Package sqlc contains the SQLite schema embedded into the binary.
+// Package sqlc contains the database schemas embedded into the binary.
 package sqlc
 
-import _ "embed"
+import "embed"
 
-//go:embed schema.sql
-var Schema string
+// Migrations holds the numbered schema migrations, applied in order by the
+// migrate package: SQLite in migrations, PostgreSQL in postgres/migrations.
+//
+//go:embed migrations/*.sql postgres/migrations/*.sql
+var Migrations embed.FS
+
+//go:embed search.sql
+var SearchSchema string
@@ -0,0 +1,97 @@
+CREATE TABLE IF NOT EXISTS Email (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  subject TEXT,
+  createdAt INTEGER DEFAULT (strftime('%s', 'now') * 1000),
+  expiresAt DATETIME 
+);
+
+CREATE TABLE IF NOT EXISTS Inbox (
+  id TEXT PRIMARY KEY,
+  address TEXT,
+  textContent TEXT,
+  htmlContent TEXT,
+  createdAt INTEGER DEFAULT (strftime('%s', 'now') * 1000),
+
+  emailId INTEGER,
+  FOREIGN KEY (emailId) REFERENCES Email(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS EmailAddress (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  type TEXT, -- Can be 'from', 'to'
+  address TEXT,
+
+  emailId INTEGER,
+  FOREIGN KEY (emailId) REFERENCES Email(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS Attachment (
+  id TEXT PRIMARY KEY,
+  filename TEXT,
+  contentType TEXT,
+  size INTEGER NOT NULL DEFAULT 0,
+  contentId TEXT,
+  disposition TEXT, -- Can be 'attachment', 'inline'
+  content BLOB,
+
+  emailId INTEGER,
+  FOREIGN KEY (emailId) REFERENCES Email(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS RawMessage (
+  emailId INTEGER PRIMARY KEY,
+  compression TEXT NOT NULL DEFAULT '', -- Can be '', 'gzip'
+  size INTEGER NOT NULL DEFAULT 0, -- Uncompressed size in bytes
+  content BLOB NOT NULL,
+
+  FOREIGN KEY (emailId) REFERENCES Email(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS Webhook (
+  id TEXT PRIMARY KEY,
+  url TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  pattern TEXT NOT NULL, -- Address, address pattern with * and ?, or domain
+  includeBody BOOLEAN NOT NULL DEFAULT 0,
+  createdAt INTEGER DEFAULT (strftime('%s', 'now') * 1000)
+);
+
+CREATE TABLE IF NOT EXISTS WebhookDelivery (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  inboxId TEXT NOT NULL,
+  payload TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending', -- Can be 'pending', 'delivered', 'failed'
+  attempts INTEGER NOT NULL DEFAULT 0,
+  responseStatus INTEGER,
+  lastError TEXT,
+  nextAttemptAt INTEGER NOT NULL,
+  deliveredAt INTEGER,
+  createdAt INTEGER DEFAULT (strftime('%s', 'now') * 1000),
+
+  webhookId TEXT NOT NULL,
+  FOREIGN KEY (webhookId) REFERENCES Webhook(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS AddressClaim (
+  address TEXT PRIMARY KEY,
+  tokenHash TEXT NOT NULL, -- Hex SHA-256 of the bearer token
+  createdAt INTEGER DEFAULT (strftime('%s', 'now') * 1000)
+);
+
+CREATE TABLE IF NOT EXISTS Address (
+  address TEXT PRIMARY KEY,
+  strategy TEXT NOT NULL, -- Can be 'words', 'cuid', 'pronounceable', 'prefix'
+  createdAt INTEGER NOT NULL,
+  expiresAt INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS AddressRetention (
+  address TEXT PRIMARY KEY,
+  retention INTEGER NOT NULL -- Milliseconds, overrides the domain retention
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_id ON EmailAddress(emailId);
+CREATE INDEX IF NOT EXISTS idx_inbox_address ON Inbox(address);
+CREATE INDEX IF NOT EXISTS idx_attachment_email_id ON Attachment(emailId);
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_due ON WebhookDelivery(status, nextAttemptAt);
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_webhook_id ON WebhookDelivery(webhookId);
@@ -0,0 +1,26 @@
+-- Store Email.expiresAt as Unix milliseconds like every other timestamp,
+-- instead of a DATETIME string, and make both timestamps required.
+-- julianday() applies the UTC offset of each stored string, so values
+-- written with different offsets compare as instants afterwards.
+CREATE TABLE Email_new (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  subject TEXT,
+  createdAt INTEGER NOT NULL DEFAULT (strftime('%s', 'now') * 1000),
+  expiresAt INTEGER NOT NULL
+);
+
+INSERT INTO Email_new (id, subject, createdAt, expiresAt)
+SELECT
+  id,
+  subject,
+  COALESCE(createdAt, strftime('%s', 'now') * 1000),
+  COALESCE(
+    CAST(ROUND((julianday(expiresAt) - 2440587.5) * 86400000) AS INTEGER),
+    COALESCE(createdAt, strftime('%s', 'now') * 1000) + 3 * 24 * 60 * 60 * 1000
+  )
+FROM Email;
+
+DROP TABLE Email;
+ALTER TABLE Email_new RENAME TO Email;
+
+CREATE INDEX IF NOT EXISTS idx_email_expires_at ON Email(expiresAt);
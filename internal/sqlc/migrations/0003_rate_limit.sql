@@ -0,0 +1,8 @@
+-- Rate limiter state shared by prefork processes.
+CREATE TABLE IF NOT EXISTS RateLimit (
+  key TEXT PRIMARY KEY,
+  value BLOB NOT NULL,
+  expiresAt INTEGER NOT NULL -- Unix milliseconds
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_expires_at ON RateLimit(expiresAt);
@@ -0,0 +1,11 @@
+-- Domains added at runtime through the admin API, in addition to the
+-- aliases in config.toml.
+CREATE TABLE IF NOT EXISTS Domain (
+  name TEXT PRIMARY KEY,
+  status TEXT NOT NULL DEFAULT 'pending', -- pending, active or disabled
+  metadata TEXT NOT NULL DEFAULT '{}', -- JSON object
+  createdAt INTEGER NOT NULL, -- Unix milliseconds
+  updatedAt INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_domain_status ON Domain(status);
@@ -0,0 +1,9 @@
+-- API keys for the /admin routes, created with "temp-mail admin-key create".
+-- Only the SHA-256 hash of a key is stored.
+CREATE TABLE IF NOT EXISTS AdminKey (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  keyHash TEXT NOT NULL UNIQUE,
+  createdAt INTEGER NOT NULL, -- Unix milliseconds
+  lastUsedAt INTEGER
+);
@@ -0,0 +1,6 @@
+-- Webhooks are managed with a bearer token returned when they are created.
+-- Only its SHA-256 hash is stored. Webhooks created before have none and are
+-- managed with their signing secret instead.
+-- The column is spelled in lower case because sqlc does not fold the case of
+-- added columns; SQLite itself ignores it.
+ALTER TABLE Webhook ADD COLUMN tokenhash TEXT;
@@ -0,0 +1,7 @@
+-- Leases let one of several processes sharing the database run a periodic
+-- job, such as the expired mail cleanup, at a time.
+CREATE TABLE IF NOT EXISTS Lease (
+  name TEXT PRIMARY KEY,
+  holder TEXT NOT NULL,
+  expiresAt INTEGER NOT NULL -- Unix milliseconds
+);
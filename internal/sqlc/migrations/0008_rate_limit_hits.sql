@@ -0,0 +1,14 @@
+-- Count requests per rate limit window in the database, so that prefork
+-- processes and replicas increment one counter atomically instead of each
+-- writing back its own copy. The old counters are dropped; they expire
+-- within a minute anyway.
+DROP TABLE IF EXISTS RateLimit;
+
+CREATE TABLE RateLimit (
+  key TEXT PRIMARY KEY,
+  hits INTEGER NOT NULL,
+  windowStart INTEGER NOT NULL, -- Unix milliseconds
+  expiresAt INTEGER NOT NULL    -- Unix milliseconds, end of the window
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_expires_at ON RateLimit(expiresAt);
@@ -0,0 +1,3 @@
+-- The outcome of the last run of a leased job, as JSON, so that every
+-- process can report it and not only the one holding the lease.
+ALTER TABLE Lease ADD COLUMN result TEXT;
@@ -0,0 +1,115 @@
+-- PostgreSQL schema. Column types and nullability match the SQLite schema so
+-- both backends map to the same Go types; timestamps are Unix milliseconds.
+CREATE TABLE IF NOT EXISTS Email (
+  id BIGSERIAL PRIMARY KEY,
+  subject TEXT,
+  createdAt BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM now()) * 1000)::BIGINT,
+  expiresAt BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Inbox (
+  id TEXT PRIMARY KEY,
+  address TEXT,
+  textContent TEXT,
+  htmlContent TEXT,
+  createdAt BIGINT DEFAULT (EXTRACT(EPOCH FROM now()) * 1000)::BIGINT,
+
+  emailId BIGINT REFERENCES Email(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS EmailAddress (
+  id BIGSERIAL PRIMARY KEY,
+  type TEXT, -- Can be 'from', 'to'
+  address TEXT,
+
+  emailId BIGINT REFERENCES Email(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS Attachment (
+  id TEXT PRIMARY KEY,
+  filename TEXT,
+  contentType TEXT,
+  size BIGINT NOT NULL DEFAULT 0,
+  contentId TEXT,
+  disposition TEXT, -- Can be 'attachment', 'inline'
+  content BYTEA,
+  seq BIGSERIAL, -- Insertion order, the rowid of the SQLite table
+
+  emailId BIGINT REFERENCES Email(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS RawMessage (
+  emailId BIGINT PRIMARY KEY REFERENCES Email(id) ON DELETE CASCADE,
+  compression TEXT NOT NULL DEFAULT '', -- Can be '', 'gzip'
+  size BIGINT NOT NULL DEFAULT 0, -- Uncompressed size in bytes
+  content BYTEA NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Webhook (
+  id TEXT PRIMARY KEY,
+  url TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  pattern TEXT NOT NULL, -- Address, address pattern with * and ?, or domain
+  includeBody BOOLEAN NOT NULL DEFAULT FALSE,
+  createdAt BIGINT DEFAULT (EXTRACT(EPOCH FROM now()) * 1000)::BIGINT
+);
+
+CREATE TABLE IF NOT EXISTS WebhookDelivery (
+  id BIGSERIAL PRIMARY KEY,
+  inboxId TEXT NOT NULL,
+  payload TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending', -- Can be 'pending', 'delivered', 'failed'
+  attempts BIGINT NOT NULL DEFAULT 0,
+  responseStatus BIGINT,
+  lastError TEXT,
+  nextAttemptAt BIGINT NOT NULL,
+  deliveredAt BIGINT,
+  createdAt BIGINT DEFAULT (EXTRACT(EPOCH FROM now()) * 1000)::BIGINT,
+
+  webhookId TEXT NOT NULL REFERENCES Webhook(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS AddressClaim (
+  address TEXT PRIMARY KEY,
+  tokenHash TEXT NOT NULL, -- Hex SHA-256 of the bearer token
+  createdAt BIGINT DEFAULT (EXTRACT(EPOCH FROM now()) * 1000)::BIGINT
+);
+
+CREATE TABLE IF NOT EXISTS Address (
+  address TEXT PRIMARY KEY,
+  strategy TEXT NOT NULL, -- Can be 'words', 'cuid', 'pronounceable', 'prefix'
+  createdAt BIGINT NOT NULL,
+  expiresAt BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS AddressRetention (
+  address TEXT PRIMARY KEY,
+  retention BIGINT NOT NULL -- Milliseconds, overrides the domain retention
+);
+
+-- Full-text search index over received mail, filled from the same values as
+-- the SQLite FTS5 table. The document is weighted subject, sender, text, html.
+CREATE TABLE IF NOT EXISTS EmailSearch (
+  inboxId TEXT PRIMARY KEY REFERENCES Inbox(id) ON DELETE CASCADE,
+  address TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  sender TEXT NOT NULL,
+  textContent TEXT NOT NULL,
+  htmlText TEXT NOT NULL,
+  document TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', subject), 'A') ||
+    setweight(to_tsvector('simple', sender), 'B') ||
+    setweight(to_tsvector('simple', textContent), 'C') ||
+    setweight(to_tsvector('simple', htmlText), 'D')
+  ) STORED
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_id ON EmailAddress(emailId);
+CREATE INDEX IF NOT EXISTS idx_email_expires_at ON Email(expiresAt);
+CREATE INDEX IF NOT EXISTS idx_inbox_address ON Inbox(address);
+CREATE INDEX IF NOT EXISTS idx_inbox_email_id ON Inbox(emailId);
+CREATE INDEX IF NOT EXISTS idx_attachment_email_id ON Attachment(emailId);
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_due ON WebhookDelivery(status, nextAttemptAt);
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_webhook_id ON WebhookDelivery(webhookId);
+CREATE INDEX IF NOT EXISTS idx_email_search_address ON EmailSearch(address);
+CREATE INDEX IF NOT EXISTS idx_email_search_document ON EmailSearch USING GIN (document);
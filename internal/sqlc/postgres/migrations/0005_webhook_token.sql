@@ -0,0 +1,4 @@
+-- Webhooks are managed with a bearer token returned when they are created.
+-- Only its SHA-256 hash is stored. Webhooks created before have none and are
+-- managed with their signing secret instead.
+ALTER TABLE Webhook ADD COLUMN IF NOT EXISTS tokenHash TEXT;
@@ -0,0 +1,11 @@
+-- Events published by one process, read by the other prefork processes and
+-- replicas to reach their live update subscribers. Rows are removed by the
+-- cleaner shortly after they were written.
+CREATE TABLE IF NOT EXISTS Event (
+  id BIGSERIAL PRIMARY KEY,
+  origin TEXT NOT NULL, -- Publishing process
+  payload TEXT NOT NULL, -- JSON encoded pubsub.Event
+  createdAt BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_created_at ON Event(createdAt);
@@ -0,0 +1,405 @@
+-- name: InsertEmail :one
+INSERT INTO Email (subject, createdAt, expiresAt)
+VALUES ($1, $2, $3)
+RETURNING id;
+
+-- name: InsertInbox :exec
+INSERT INTO Inbox (id, emailId, address, textContent, htmlContent)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: InsertEmailAddress :exec
+INSERT INTO EmailAddress (emailId, type, address)
+VALUES ($1, $2, $3);
+
+-- name: InsertAttachment :exec
+INSERT INTO Attachment (id, emailId, filename, contentType, size, contentId, disposition, content)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+
+-- name: InsertRawMessage :exec
+INSERT INTO RawMessage (emailId, compression, size, content)
+VALUES ($1, $2, $3, $4);
+
+-- name: InsertSearchEntry :exec
+INSERT INTO EmailSearch (inboxId, address, subject, sender, textContent, htmlText)
+SELECT Inbox.id, COALESCE(Inbox.address, ''), sqlc.arg(subject)::text, sqlc.arg(sender)::text,
+  COALESCE(Inbox.textContent, ''), sqlc.arg(html_text)::text
+FROM Inbox
+WHERE Inbox.id = sqlc.arg(inbox_id)
+ON CONFLICT (inboxId) DO NOTHING;
+
+-- name: GetEmailsForAddress :many
+SELECT
+  Inbox.id,
+  Email.id as emailId,
+  Email.subject,
+  Email.createdAt,
+  Email.expiresAt,
+  (SELECT address FROM EmailAddress WHERE emailId = Email.id AND type = 'from' LIMIT 1) as fromAddress,
+  COALESCE((SELECT string_agg(address, ', ') FROM EmailAddress WHERE emailId = Email.id AND type = 'to'), '')::text as toAddress
+FROM Email
+JOIN Inbox ON Email.id = Inbox.emailId
+WHERE Inbox.address = sqlc.narg(address)
+  AND (Email.createdAt >= sqlc.narg(since) OR sqlc.narg(since) IS NULL)
+  AND (Email.createdAt < sqlc.narg(until) OR sqlc.narg(until) IS NULL)
+  AND ((Email.subject ILIKE '%' || sqlc.narg(subject)::text || '%' ESCAPE '\') OR sqlc.narg(subject)::text IS NULL)
+  AND (EXISTS (
+    SELECT 1 FROM EmailAddress
+    WHERE emailId = Email.id AND type = 'from' AND (address ILIKE '%' || sqlc.narg(from_address)::text || '%' ESCAPE '\')
+  ) OR sqlc.narg(from_address)::text IS NULL)
+  AND (Email.createdAt < sqlc.narg(cursor_created_at)
+    OR (Email.createdAt = sqlc.narg(cursor_created_at) AND Email.id < sqlc.narg(cursor_id))
+    OR sqlc.narg(cursor_created_at) IS NULL)
+ORDER BY Email.createdAt DESC, Email.id DESC
+LIMIT sqlc.arg('limit')::bigint;
+
+-- name: GetNextEmailForAddress :one
+SELECT
+  Inbox.id,
+  Email.id as emailId,
+  Email.subject,
+  Email.createdAt,
+  Email.expiresAt,
+  (SELECT address FROM EmailAddress WHERE emailId = Email.id AND type = 'from' LIMIT 1) as fromAddress,
+  COALESCE((SELECT string_agg(address, ', ') FROM EmailAddress WHERE emailId = Email.id AND type = 'to'), '')::text as toAddress
+FROM Email
+JOIN Inbox ON Email.id = Inbox.emailId
+WHERE Inbox.address = sqlc.narg(address)
+  AND (Email.createdAt > sqlc.arg(after_created_at)
+    OR (Email.createdAt = sqlc.arg(after_created_at) AND Email.id > sqlc.arg(after_id)))
+  AND ((Email.subject ILIKE '%' || sqlc.narg(subject)::text || '%' ESCAPE '\') OR sqlc.narg(subject)::text IS NULL)
+  AND (EXISTS (
+    SELECT 1 FROM EmailAddress
+    WHERE emailId = Email.id AND type = 'from' AND (address ILIKE '%' || sqlc.narg(from_address)::text || '%' ESCAPE '\')
+  ) OR sqlc.narg(from_address)::text IS NULL)
+ORDER BY Email.createdAt ASC, Email.id ASC
+LIMIT 1;
+
+-- name: GetInboxByID :one
+SELECT
+  Inbox.id,
+  Inbox.address,
+  Inbox.textContent,
+  Inbox.htmlContent,
+  Email.subject,
+  Email.expiresAt,
+  Email.createdAt,
+  (SELECT address FROM EmailAddress WHERE emailId = Email.id AND type = 'from' LIMIT 1) as fromAddress,
+  COALESCE((SELECT string_agg(address, ', ') FROM EmailAddress WHERE emailId = Email.id AND type = 'to'), '')::text as toAddress
+FROM Inbox
+JOIN Email ON Inbox.emailId = Email.id
+WHERE Inbox.id = $1;
+
+-- name: GetAttachmentsByInboxID :many
+SELECT
+  Attachment.id,
+  Attachment.filename,
+  Attachment.contentType,
+  Attachment.size,
+  Attachment.contentId,
+  Attachment.disposition
+FROM Attachment
+JOIN Inbox ON Attachment.emailId = Inbox.emailId
+WHERE Inbox.id = $1
+ORDER BY Attachment.seq;
+
+-- name: GetAttachmentForInbox :one
+SELECT
+  Attachment.id,
+  Attachment.filename,
+  Attachment.contentType,
+  Attachment.size,
+  Attachment.disposition,
+  Attachment.content
+FROM Attachment
+JOIN Inbox ON Attachment.emailId = Inbox.emailId
+WHERE Inbox.id = sqlc.arg(inbox_id) AND Attachment.id = sqlc.arg(attachment_id);
+
+-- name: GetRawMessageByInboxID :one
+SELECT
+  RawMessage.compression,
+  RawMessage.size,
+  RawMessage.content
+FROM RawMessage
+JOIN Inbox ON RawMessage.emailId = Inbox.emailId
+WHERE Inbox.id = $1;
+
+-- name: DeleteByInboxID :exec
+DELETE FROM Inbox WHERE id = $1;
+
+-- name: GetExpiredEmailIDs :many
+SELECT id FROM Email
+WHERE expiresAt <= sqlc.arg(now)
+ORDER BY expiresAt
+LIMIT sqlc.arg('limit')::bigint;
+
+-- name: GetInboxesByEmailIDs :many
+SELECT id, address FROM Inbox
+WHERE emailId = ANY(sqlc.arg(email_ids)::bigint[]);
+
+-- name: DeleteEmailsByID :execrows
+DELETE FROM Email WHERE id = ANY(sqlc.arg(ids)::bigint[]);
+
+-- name: DeleteExpiredAddresses :execrows
+DELETE FROM Address WHERE expiresAt <= $1;
+
+-- name: InsertWebhook :exec
+INSERT INTO Webhook (id, url, secret, pattern, includeBody, tokenHash)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: GetWebhook :one
+SELECT id, url, secret, pattern, includeBody, createdAt, tokenHash
+FROM Webhook
+WHERE id = $1;
+
+-- name: GetWebhooks :many
+SELECT id, url, secret, pattern, includeBody, createdAt, tokenHash
+FROM Webhook;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM Webhook WHERE id = $1;
+
+-- name: InsertWebhookDelivery :exec
+INSERT INTO WebhookDelivery (webhookId, inboxId, payload, nextAttemptAt)
+VALUES ($1, $2, $3, $4);
+
+-- Due deliveries are leased for five minutes by pushing nextAttemptAt
+-- forward, so replicas sharing the database do not post them twice.
+
+-- name: GetDueWebhookDeliveries :many
+WITH due AS (
+  SELECT WebhookDelivery.id FROM WebhookDelivery
+  WHERE WebhookDelivery.status = 'pending' AND WebhookDelivery.nextAttemptAt <= sqlc.arg(nextattemptat)
+  ORDER BY WebhookDelivery.nextAttemptAt
+  LIMIT sqlc.arg('limit')::bigint
+  FOR UPDATE SKIP LOCKED
+), leased AS (
+  UPDATE WebhookDelivery SET nextAttemptAt = sqlc.arg(nextattemptat) + 300000
+  FROM due
+  WHERE WebhookDelivery.id = due.id
+  RETURNING WebhookDelivery.id, WebhookDelivery.payload, WebhookDelivery.attempts, WebhookDelivery.webhookId
+)
+SELECT
+  leased.id,
+  leased.payload,
+  leased.attempts,
+  Webhook.url,
+  Webhook.secret
+FROM leased
+JOIN Webhook ON leased.webhookId = Webhook.id;
+
+-- name: LeaseWebhookDeliveries :exec
+UPDATE WebhookDelivery SET nextAttemptAt = sqlc.arg(until)
+WHERE id = ANY(sqlc.arg(ids)::bigint[]);
+
+-- name: UpdateWebhookDelivery :exec
+UPDATE WebhookDelivery
+SET status = $1, attempts = $2, responseStatus = $3, lastError = $4, nextAttemptAt = $5, deliveredAt = $6
+WHERE id = $7;
+
+-- name: GetWebhookDeliveries :many
+SELECT id, inboxId, status, attempts, responseStatus, lastError, nextAttemptAt, deliveredAt, createdAt
+FROM WebhookDelivery
+WHERE webhookId = $1
+ORDER BY id DESC
+LIMIT sqlc.arg('limit')::bigint;
+
+-- name: InsertAddressClaim :execrows
+INSERT INTO AddressClaim (address, tokenHash)
+VALUES ($1, $2)
+ON CONFLICT (address) DO NOTHING;
+
+-- name: GetAddressClaim :one
+SELECT address, tokenHash, createdAt
+FROM AddressClaim
+WHERE address = $1;
+
+-- name: InsertAddress :execrows
+INSERT INTO Address (address, strategy, createdAt, expiresAt)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (address) DO NOTHING;
+
+-- name: AddressInUse :one
+SELECT EXISTS (SELECT 1 FROM Address WHERE Address.address = sqlc.arg(address))
+  OR EXISTS (SELECT 1 FROM Inbox WHERE Inbox.address = sqlc.arg(address))
+  OR EXISTS (SELECT 1 FROM AddressClaim WHERE AddressClaim.address = sqlc.arg(address));
+
+-- name: CountInboxesForAddress :one
+SELECT COUNT(*) FROM Inbox WHERE address = $1;
+
+-- name: GetAddress :one
+SELECT address, strategy, createdAt, expiresAt
+FROM Address
+WHERE address = $1;
+
+-- name: UpsertAddressRetention :exec
+INSERT INTO AddressRetention (address, retention)
+VALUES ($1, $2)
+ON CONFLICT (address) DO UPDATE SET retention = excluded.retention;
+
+-- name: GetAddressRetention :one
+SELECT retention FROM AddressRetention WHERE address = $1;
+
+-- name: GetEmailTimesForAddress :many
+SELECT Email.id, Email.createdAt
+FROM Email
+JOIN Inbox ON Email.id = Inbox.emailId
+WHERE Inbox.address = $1;
+
+-- name: GetEmailRecipients :many
+SELECT address FROM Inbox WHERE emailId = sqlc.arg(email_id);
+
+-- name: GetInboxRecipients :many
+SELECT other.address
+FROM Inbox
+JOIN Inbox AS other ON other.emailId = Inbox.emailId
+WHERE Inbox.id = sqlc.arg(inbox_id);
+
+-- name: UpdateEmailExpiry :exec
+UPDATE Email SET expiresAt = sqlc.arg(expires_at) WHERE id = sqlc.arg(id);
+
+-- name: UpdateInboxEmailExpiry :exec
+UPDATE Email SET expiresAt = sqlc.arg(expires_at)
+WHERE id = (SELECT emailId FROM Inbox WHERE Inbox.id = sqlc.arg(inbox_id));
+
+-- name: HitRateLimit :one
+-- Counts a request against key and returns the count and end of the
+-- current window. A window that has ended starts over.
+INSERT INTO RateLimit (key, hits, windowStart, expiresAt)
+VALUES (sqlc.arg(key), 1, sqlc.arg(window_start), sqlc.arg(expires_at))
+ON CONFLICT (key) DO UPDATE SET
+  hits = CASE WHEN RateLimit.expiresAt <= excluded.windowStart THEN 1 ELSE RateLimit.hits + 1 END,
+  windowStart = CASE WHEN RateLimit.expiresAt <= excluded.windowStart THEN excluded.windowStart ELSE RateLimit.windowStart END,
+  expiresAt = CASE WHEN RateLimit.expiresAt <= excluded.windowStart THEN excluded.expiresAt ELSE RateLimit.expiresAt END
+RETURNING hits, expiresAt;
+
+-- name: DeleteExpiredRateLimits :execrows
+DELETE FROM RateLimit WHERE expiresAt <= $1;
+
+-- name: InsertDomain :execrows
+INSERT INTO Domain (name, status, metadata, createdAt, updatedAt)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (name) DO NOTHING;
+
+-- name: GetDomain :one
+SELECT name, status, metadata, createdAt, updatedAt
+FROM Domain
+WHERE name = $1;
+
+-- name: GetDomainList :many
+SELECT name, status, metadata, createdAt, updatedAt
+FROM Domain
+ORDER BY name;
+
+-- name: GetActiveDomainNames :many
+SELECT name FROM Domain
+WHERE status = 'active'
+ORDER BY createdAt, name;
+
+-- name: UpdateDomain :execrows
+UPDATE Domain SET status = $1, metadata = $2, updatedAt = $3
+WHERE name = $4;
+
+-- name: DeleteDomain :execrows
+DELETE FROM Domain WHERE name = $1;
+
+-- name: InsertAdminKey :exec
+INSERT INTO AdminKey (id, name, keyHash, createdAt)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetAdminKeyByHash :one
+SELECT id, name, createdAt, lastUsedAt
+FROM AdminKey
+WHERE keyHash = $1;
+
+-- name: GetAdminKeys :many
+SELECT id, name, createdAt, lastUsedAt
+FROM AdminKey
+ORDER BY createdAt;
+
+-- name: UpdateAdminKeyLastUsed :exec
+UPDATE AdminKey SET lastUsedAt = $1 WHERE id = $2;
+
+-- name: DeleteAdminKey :execrows
+DELETE FROM AdminKey WHERE id = $1;
+
+-- name: GetAddressSummaries :many
+SELECT
+  Inbox.address,
+  COUNT(*) AS messages,
+  MAX(Email.createdAt)::bigint AS lastReceivedAt
+FROM Inbox
+JOIN Email ON Inbox.emailId = Email.id
+WHERE Inbox.address IS NOT NULL
+  AND (Inbox.address LIKE '%@' || sqlc.narg(domain)::text OR sqlc.narg(domain)::text IS NULL)
+GROUP BY Inbox.address
+ORDER BY lastReceivedAt DESC, Inbox.address
+LIMIT sqlc.arg('limit')::bigint OFFSET sqlc.arg('offset')::bigint;
+
+-- name: GetInboxesForPurge :many
+SELECT Inbox.id, Inbox.address, Inbox.emailId
+FROM Inbox
+WHERE (Inbox.address = sqlc.narg(address)::text OR sqlc.narg(address)::text IS NULL)
+  AND (Inbox.address LIKE '%@' || sqlc.narg(domain)::text OR sqlc.narg(domain)::text IS NULL)
+  AND (EXISTS (
+    SELECT 1 FROM EmailAddress
+    WHERE EmailAddress.emailId = Inbox.emailId AND EmailAddress.type = 'from'
+      AND LOWER(EmailAddress.address) = sqlc.narg(sender)::text
+  ) OR sqlc.narg(sender)::text IS NULL)
+LIMIT sqlc.arg('limit')::bigint;
+
+-- name: DeleteInboxesByID :execrows
+DELETE FROM Inbox WHERE id = ANY(sqlc.arg(ids)::text[]);
+
+-- name: DeleteOrphanedEmails :execrows
+DELETE FROM Email
+WHERE id = ANY(sqlc.arg(ids)::bigint[])
+  AND NOT EXISTS (SELECT 1 FROM Inbox WHERE Inbox.emailId = Email.id);
+
+-- name: GetStats :one
+SELECT
+  (SELECT COUNT(*) FROM Email)::bigint AS emails,
+  (SELECT COUNT(*) FROM Inbox)::bigint AS inboxes,
+  (SELECT COUNT(DISTINCT address) FROM Inbox)::bigint AS addresses,
+  (SELECT COUNT(*) FROM Address)::bigint AS generatedAddresses,
+  (SELECT COUNT(*) FROM AddressClaim)::bigint AS claimedAddresses,
+  (SELECT COUNT(*) FROM Attachment)::bigint AS attachments,
+  (SELECT COALESCE(SUM(size), 0) FROM Attachment)::bigint AS attachmentBytes,
+  (SELECT COALESCE(SUM(size), 0) FROM RawMessage)::bigint AS rawBytes,
+  (SELECT COUNT(*) FROM Webhook)::bigint AS webhooks,
+  (SELECT COUNT(*) FROM WebhookDelivery WHERE status = 'pending')::bigint AS pendingDeliveries,
+  (SELECT COUNT(*) FROM WebhookDelivery WHERE status = 'failed')::bigint AS failedDeliveries,
+  (SELECT COALESCE(MIN(createdAt), 0) FROM Email)::bigint AS oldestEmailAt,
+  (SELECT COALESCE(MAX(createdAt), 0) FROM Email)::bigint AS newestEmailAt;
+
+-- name: InsertLease :execrows
+INSERT INTO Lease (name, holder, expiresAt)
+VALUES (sqlc.arg(name), sqlc.arg(holder), sqlc.arg(expires_at))
+ON CONFLICT (name) DO NOTHING;
+
+-- name: TakeLease :execrows
+UPDATE Lease SET holder = sqlc.arg(holder), expiresAt = sqlc.arg(expires_at)
+WHERE name = sqlc.arg(name) AND (holder = sqlc.arg(holder) OR expiresAt <= sqlc.arg(now));
+
+-- name: SetLeaseResult :exec
+INSERT INTO Lease (name, holder, expiresAt, result)
+VALUES (sqlc.arg(name), '', 0, sqlc.arg(result))
+ON CONFLICT (name) DO UPDATE SET result = excluded.result;
+
+-- name: GetLeaseResult :one
+SELECT result FROM Lease WHERE name = sqlc.arg(name);
+
+-- name: InsertEvent :exec
+INSERT INTO Event (origin, payload, createdAt)
+VALUES (sqlc.arg(origin), sqlc.arg(payload), sqlc.arg(created_at));
+
+-- name: GetEventsAfter :many
+SELECT id, origin, payload FROM Event
+WHERE id > sqlc.arg(after)
+ORDER BY id
+LIMIT sqlc.arg('limit')::bigint;
+
+-- name: GetLastEventID :one
+SELECT CAST(COALESCE(MAX(id), 0) AS BIGINT) FROM Event;
+
+-- name: DeleteEventsBefore :execrows
+DELETE FROM Event WHERE createdAt < sqlc.arg(before);
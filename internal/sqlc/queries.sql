@@ -1,6 +1,6 @@
 -- name: InsertEmail :one
-INSERT INTO Email (subject, expiresAt) 
-VALUES (?, ?)
+INSERT INTO Email (subject, createdAt, expiresAt) 
+VALUES (?, ?, ?)
 RETURNING id;
 
 -- name: InsertInbox :exec
@@ -11,35 +11,381 @@ VALUES (?, ?, ?, ?, ?);
 INSERT INTO EmailAddress (emailId, type, address) 
 VALUES (?, ?, ?);
 
+-- name: InsertAttachment :exec
+INSERT INTO Attachment (id, emailId, filename, contentType, size, contentId, disposition, content)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: InsertRawMessage :exec
+INSERT INTO RawMessage (emailId, compression, size, content)
+VALUES (?, ?, ?, ?);
+
+-- name: InsertSearchEntry :exec
+INSERT INTO EmailSearch (rowid, address, subject, sender, textContent, htmlText)
+SELECT Inbox.rowid, Inbox.address, sqlc.arg(subject), sqlc.arg(sender), Inbox.textContent, sqlc.arg(html_text)
+FROM Inbox
+WHERE Inbox.id = sqlc.arg(inbox_id);
+
 -- name: GetEmailsForAddress :many
 SELECT 
   Inbox.id,
+  Email.id as emailId,
   Email.subject,
   Email.createdAt,
   Email.expiresAt,
   (SELECT address FROM EmailAddress WHERE emailId = Email.id AND type = 'from') as fromAddress,
-  (SELECT GROUP_CONCAT(address, ', ') FROM EmailAddress WHERE emailId = Email.id AND type = 'to') as toAddress
+  CAST(COALESCE((SELECT GROUP_CONCAT(address, ', ') FROM EmailAddress WHERE emailId = Email.id AND type = 'to'), '') AS TEXT) as toAddress
 FROM Email
 JOIN Inbox ON Email.id = Inbox.emailId
-WHERE Inbox.address = ?
-ORDER BY Email.createdAt DESC;
+WHERE Inbox.address = sqlc.arg(address)
+  AND (Email.createdAt >= sqlc.narg(since) OR sqlc.narg(since) IS NULL)
+  AND (Email.createdAt < sqlc.narg(until) OR sqlc.narg(until) IS NULL)
+  AND ((Email.subject LIKE '%' || sqlc.narg(subject) || '%' ESCAPE '\') OR sqlc.narg(subject) IS NULL)
+  AND (EXISTS (
+    SELECT 1 FROM EmailAddress
+    WHERE emailId = Email.id AND type = 'from' AND (address LIKE '%' || sqlc.narg(from_address) || '%' ESCAPE '\')
+  ) OR sqlc.narg(from_address) IS NULL)
+  AND (Email.createdAt < sqlc.narg(cursor_created_at)
+    OR (Email.createdAt = sqlc.narg(cursor_created_at) AND Email.id < sqlc.narg(cursor_id))
+    OR sqlc.narg(cursor_created_at) IS NULL)
+ORDER BY Email.createdAt DESC, Email.id DESC
+LIMIT sqlc.arg(limit);
+
+-- name: GetNextEmailForAddress :one
+SELECT
+  Inbox.id,
+  Email.id as emailId,
+  Email.subject,
+  Email.createdAt,
+  Email.expiresAt,
+  (SELECT address FROM EmailAddress WHERE emailId = Email.id AND type = 'from') as fromAddress,
+  CAST(COALESCE((SELECT GROUP_CONCAT(address, ', ') FROM EmailAddress WHERE emailId = Email.id AND type = 'to'), '') AS TEXT) as toAddress
+FROM Email
+JOIN Inbox ON Email.id = Inbox.emailId
+WHERE Inbox.address = sqlc.arg(address)
+  AND (Email.createdAt > sqlc.arg(after_created_at)
+    OR (Email.createdAt = sqlc.arg(after_created_at) AND Email.id > sqlc.arg(after_id)))
+  AND ((Email.subject LIKE '%' || sqlc.narg(subject) || '%' ESCAPE '\') OR sqlc.narg(subject) IS NULL)
+  AND (EXISTS (
+    SELECT 1 FROM EmailAddress
+    WHERE emailId = Email.id AND type = 'from' AND (address LIKE '%' || sqlc.narg(from_address) || '%' ESCAPE '\')
+  ) OR sqlc.narg(from_address) IS NULL)
+ORDER BY Email.createdAt ASC, Email.id ASC
+LIMIT 1;
 
 -- name: GetInboxByID :one
 SELECT 
   Inbox.id,
+  Inbox.address,
   Inbox.textContent, 
   Inbox.htmlContent, 
   Email.subject, 
   Email.expiresAt,
   Email.createdAt,
   (SELECT address FROM EmailAddress WHERE emailId = Email.id AND type = 'from') as fromAddress,
-  (SELECT GROUP_CONCAT(address, ', ') FROM EmailAddress WHERE emailId = Email.id AND type = 'to') as toAddress
+  CAST(COALESCE((SELECT GROUP_CONCAT(address, ', ') FROM EmailAddress WHERE emailId = Email.id AND type = 'to'), '') AS TEXT) as toAddress
 FROM Inbox
 JOIN Email ON Inbox.emailId = Email.id
 WHERE Inbox.id = ?;
 
+-- name: GetAttachmentsByInboxID :many
+SELECT
+  Attachment.id,
+  Attachment.filename,
+  Attachment.contentType,
+  Attachment.size,
+  Attachment.contentId,
+  Attachment.disposition
+FROM Attachment
+JOIN Inbox ON Attachment.emailId = Inbox.emailId
+WHERE Inbox.id = ?
+ORDER BY Attachment.rowid;
+
+-- name: GetAttachmentForInbox :one
+SELECT
+  Attachment.id,
+  Attachment.filename,
+  Attachment.contentType,
+  Attachment.size,
+  Attachment.disposition,
+  Attachment.content
+FROM Attachment
+JOIN Inbox ON Attachment.emailId = Inbox.emailId
+WHERE Inbox.id = sqlc.arg(inbox_id) AND Attachment.id = sqlc.arg(attachment_id);
+
+-- name: GetRawMessageByInboxID :one
+SELECT
+  RawMessage.compression,
+  RawMessage.size,
+  RawMessage.content
+FROM RawMessage
+JOIN Inbox ON RawMessage.emailId = Inbox.emailId
+WHERE Inbox.id = ?;
+
 -- name: DeleteByInboxID :exec
 DELETE FROM Inbox WHERE id = ?;
 
--- name: DeleteExpiredEntries :exec
-DELETE FROM Email WHERE expiresAt < strftime('%s', 'now') * 1000;
+-- name: GetExpiredEmailIDs :many
+SELECT id FROM Email
+WHERE expiresAt <= sqlc.arg(now)
+ORDER BY expiresAt
+LIMIT sqlc.arg(limit);
+
+-- name: GetInboxesByEmailIDs :many
+SELECT id, address FROM Inbox
+WHERE emailId IN (sqlc.slice(email_ids));
+
+-- name: DeleteEmailsByID :execrows
+DELETE FROM Email WHERE id IN (sqlc.slice(ids));
+
+-- name: DeleteExpiredAddresses :execrows
+DELETE FROM Address WHERE expiresAt <= ?;
+
+-- name: InsertWebhook :exec
+INSERT INTO Webhook (id, url, secret, pattern, includeBody, tokenHash)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: GetWebhook :one
+SELECT id, url, secret, pattern, includeBody, createdAt, tokenHash
+FROM Webhook
+WHERE id = ?;
+
+-- name: GetWebhooks :many
+SELECT id, url, secret, pattern, includeBody, createdAt, tokenHash
+FROM Webhook;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM Webhook WHERE id = ?;
+
+-- name: InsertWebhookDelivery :exec
+INSERT INTO WebhookDelivery (webhookId, inboxId, payload, nextAttemptAt)
+VALUES (?, ?, ?, ?);
+
+-- name: GetDueWebhookDeliveries :many
+SELECT
+  WebhookDelivery.id,
+  WebhookDelivery.payload,
+  WebhookDelivery.attempts,
+  Webhook.url,
+  Webhook.secret
+FROM WebhookDelivery
+JOIN Webhook ON WebhookDelivery.webhookId = Webhook.id
+WHERE WebhookDelivery.status = 'pending' AND WebhookDelivery.nextAttemptAt <= ?
+ORDER BY WebhookDelivery.nextAttemptAt
+LIMIT ?;
+
+-- name: LeaseWebhookDeliveries :exec
+UPDATE WebhookDelivery SET nextAttemptAt = sqlc.arg(until)
+WHERE id IN (sqlc.slice(ids));
+
+-- name: UpdateWebhookDelivery :exec
+UPDATE WebhookDelivery
+SET status = ?, attempts = ?, responseStatus = ?, lastError = ?, nextAttemptAt = ?, deliveredAt = ?
+WHERE id = ?;
+
+-- name: GetWebhookDeliveries :many
+SELECT id, inboxId, status, attempts, responseStatus, lastError, nextAttemptAt, deliveredAt, createdAt
+FROM WebhookDelivery
+WHERE webhookId = ?
+ORDER BY id DESC
+LIMIT ?;
+
+-- name: InsertAddressClaim :execrows
+INSERT INTO AddressClaim (address, tokenHash)
+VALUES (?, ?)
+ON CONFLICT (address) DO NOTHING;
+
+-- name: GetAddressClaim :one
+SELECT address, tokenHash, createdAt
+FROM AddressClaim
+WHERE address = ?;
+
+-- name: InsertAddress :execrows
+INSERT INTO Address (address, strategy, createdAt, expiresAt)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (address) DO NOTHING;
+
+-- name: AddressInUse :one
+SELECT EXISTS (SELECT 1 FROM Address WHERE Address.address = sqlc.arg(address))
+  OR EXISTS (SELECT 1 FROM Inbox WHERE Inbox.address = sqlc.arg(address))
+  OR EXISTS (SELECT 1 FROM AddressClaim WHERE AddressClaim.address = sqlc.arg(address));
+
+-- name: CountInboxesForAddress :one
+SELECT COUNT(*) FROM Inbox WHERE address = ?;
+
+-- name: GetAddress :one
+SELECT address, strategy, createdAt, expiresAt
+FROM Address
+WHERE address = ?;
+
+-- name: UpsertAddressRetention :exec
+INSERT INTO AddressRetention (address, retention)
+VALUES (?, ?)
+ON CONFLICT (address) DO UPDATE SET retention = excluded.retention;
+
+-- name: GetAddressRetention :one
+SELECT retention FROM AddressRetention WHERE address = ?;
+
+-- name: GetEmailTimesForAddress :many
+SELECT Email.id, Email.createdAt
+FROM Email
+JOIN Inbox ON Email.id = Inbox.emailId
+WHERE Inbox.address = ?;
+
+-- name: GetEmailRecipients :many
+SELECT address FROM Inbox WHERE emailId = sqlc.arg(email_id);
+
+-- name: GetInboxRecipients :many
+SELECT other.address
+FROM Inbox
+JOIN Inbox AS other ON other.emailId = Inbox.emailId
+WHERE Inbox.id = sqlc.arg(inbox_id);
+
+-- name: UpdateEmailExpiry :exec
+UPDATE Email SET expiresAt = sqlc.arg(expires_at) WHERE id = sqlc.arg(id);
+
+-- name: UpdateInboxEmailExpiry :exec
+UPDATE Email SET expiresAt = sqlc.arg(expires_at)
+WHERE id = (SELECT emailId FROM Inbox WHERE Inbox.id = sqlc.arg(inbox_id));
+
+-- name: HitRateLimit :one
+-- Counts a request against key and returns the count and end of the
+-- current window. A window that has ended starts over.
+INSERT INTO RateLimit (key, hits, windowStart, expiresAt)
+VALUES (sqlc.arg(key), 1, sqlc.arg(window_start), sqlc.arg(expires_at))
+ON CONFLICT (key) DO UPDATE SET
+  hits = CASE WHEN RateLimit.expiresAt <= excluded.windowStart THEN 1 ELSE RateLimit.hits + 1 END,
+  windowStart = CASE WHEN RateLimit.expiresAt <= excluded.windowStart THEN excluded.windowStart ELSE RateLimit.windowStart END,
+  expiresAt = CASE WHEN RateLimit.expiresAt <= excluded.windowStart THEN excluded.expiresAt ELSE RateLimit.expiresAt END
+RETURNING hits, expiresAt;
+
+-- name: DeleteExpiredRateLimits :execrows
+DELETE FROM RateLimit WHERE expiresAt <= ?;
+
+-- name: InsertDomain :execrows
+INSERT INTO Domain (name, status, metadata, createdAt, updatedAt)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (name) DO NOTHING;
+
+-- name: GetDomain :one
+SELECT name, status, metadata, createdAt, updatedAt
+FROM Domain
+WHERE name = ?;
+
+-- name: GetDomainList :many
+SELECT name, status, metadata, createdAt, updatedAt
+FROM Domain
+ORDER BY name;
+
+-- name: GetActiveDomainNames :many
+SELECT name FROM Domain
+WHERE status = 'active'
+ORDER BY createdAt, name;
+
+-- name: UpdateDomain :execrows
+UPDATE Domain SET status = ?, metadata = ?, updatedAt = ?
+WHERE name = ?;
+
+-- name: DeleteDomain :execrows
+DELETE FROM Domain WHERE name = ?;
+
+-- name: InsertAdminKey :exec
+INSERT INTO AdminKey (id, name, keyHash, createdAt)
+VALUES (?, ?, ?, ?);
+
+-- name: GetAdminKeyByHash :one
+SELECT id, name, createdAt, lastUsedAt
+FROM AdminKey
+WHERE keyHash = ?;
+
+-- name: GetAdminKeys :many
+SELECT id, name, createdAt, lastUsedAt
+FROM AdminKey
+ORDER BY createdAt;
+
+-- name: UpdateAdminKeyLastUsed :exec
+UPDATE AdminKey SET lastUsedAt = ? WHERE id = ?;
+
+-- name: DeleteAdminKey :execrows
+DELETE FROM AdminKey WHERE id = ?;
+
+-- name: GetAddressSummaries :many
+SELECT
+  Inbox.address,
+  COUNT(*) AS messages,
+  CAST(MAX(Email.createdAt) AS INTEGER) AS lastReceivedAt
+FROM Inbox
+JOIN Email ON Inbox.emailId = Email.id
+WHERE Inbox.address IS NOT NULL
+  AND (Inbox.address LIKE '%@' || sqlc.narg(domain) OR sqlc.narg(domain) IS NULL)
+GROUP BY Inbox.address
+ORDER BY lastReceivedAt DESC, Inbox.address
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: GetInboxesForPurge :many
+SELECT Inbox.id, Inbox.address, Inbox.emailId
+FROM Inbox
+WHERE (Inbox.address = sqlc.narg(address) OR sqlc.narg(address) IS NULL)
+  AND (Inbox.address LIKE '%@' || sqlc.narg(domain) OR sqlc.narg(domain) IS NULL)
+  AND (EXISTS (
+    SELECT 1 FROM EmailAddress
+    WHERE EmailAddress.emailId = Inbox.emailId AND EmailAddress.type = 'from'
+      AND LOWER(EmailAddress.address) = CAST(sqlc.narg(sender) AS TEXT)
+  ) OR sqlc.narg(sender) IS NULL)
+LIMIT sqlc.arg(limit);
+
+-- name: DeleteInboxesByID :execrows
+DELETE FROM Inbox WHERE id IN (sqlc.slice(ids));
+
+-- name: DeleteOrphanedEmails :execrows
+DELETE FROM Email
+WHERE id IN (sqlc.slice(ids))
+  AND NOT EXISTS (SELECT 1 FROM Inbox WHERE Inbox.emailId = Email.id);
+
+-- name: GetStats :one
+SELECT
+  (SELECT COUNT(*) FROM Email) AS emails,
+  (SELECT COUNT(*) FROM Inbox) AS inboxes,
+  (SELECT COUNT(DISTINCT address) FROM Inbox) AS addresses,
+  (SELECT COUNT(*) FROM Address) AS generatedAddresses,
+  (SELECT COUNT(*) FROM AddressClaim) AS claimedAddresses,
+  (SELECT COUNT(*) FROM Attachment) AS attachments,
+  CAST((SELECT COALESCE(SUM(size), 0) FROM Attachment) AS INTEGER) AS attachmentBytes,
+  CAST((SELECT COALESCE(SUM(size), 0) FROM RawMessage) AS INTEGER) AS rawBytes,
+  (SELECT COUNT(*) FROM Webhook) AS webhooks,
+  (SELECT COUNT(*) FROM WebhookDelivery WHERE status = 'pending') AS pendingDeliveries,
+  (SELECT COUNT(*) FROM WebhookDelivery WHERE status = 'failed') AS failedDeliveries,
+  CAST((SELECT COALESCE(MIN(createdAt), 0) FROM Email) AS INTEGER) AS oldestEmailAt,
+  CAST((SELECT COALESCE(MAX(createdAt), 0) FROM Email) AS INTEGER) AS newestEmailAt;
+
+-- name: InsertLease :execrows
+INSERT INTO Lease (name, holder, expiresAt)
+VALUES (sqlc.arg(name), sqlc.arg(holder), sqlc.arg(expires_at))
+ON CONFLICT (name) DO NOTHING;
+
+-- name: TakeLease :execrows
+UPDATE Lease SET holder = sqlc.arg(holder), expiresAt = sqlc.arg(expires_at)
+WHERE name = sqlc.arg(name) AND (holder = sqlc.arg(holder) OR expiresAt <= sqlc.arg(now));
+
+-- name: SetLeaseResult :exec
+INSERT INTO Lease (name, holder, expiresAt, result)
+VALUES (sqlc.arg(name), '', 0, sqlc.arg(result))
+ON CONFLICT (name) DO UPDATE SET result = excluded.result;
+
+-- name: GetLeaseResult :one
+SELECT result FROM Lease WHERE name = sqlc.arg(name);
+
+-- name: InsertEvent :exec
+INSERT INTO Event (origin, payload, createdAt)
+VALUES (sqlc.arg(origin), sqlc.arg(payload), sqlc.arg(created_at));
+
+-- name: GetEventsAfter :many
+SELECT id, origin, payload FROM Event
+WHERE id > sqlc.arg(after)
+ORDER BY id
+LIMIT sqlc.arg(limit);
+
+-- name: GetLastEventID :one
+SELECT CAST(COALESCE(MAX(id), 0) AS INTEGER) FROM Event;
+
+-- name: DeleteEventsBefore :execrows
+DELETE FROM Event WHERE createdAt < sqlc.arg(before);
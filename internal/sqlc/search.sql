@@ -0,0 +1,15 @@
+-- Full-text search index over received mail. It is derived from Inbox and
+-- Email and requires SQLite built with FTS5 (go build -tags sqlite_fts5).
+CREATE VIRTUAL TABLE IF NOT EXISTS EmailSearch USING fts5(
+  address UNINDEXED,
+  subject,
+  sender,
+  textContent,
+  htmlText,
+  tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS trg_inbox_delete_search AFTER DELETE ON Inbox
+BEGIN
+  DELETE FROM EmailSearch WHERE rowid = old.rowid;
+END;
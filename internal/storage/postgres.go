@@ -0,0 +1,451 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/lib/pq"
+
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/db/pgdb"
+	"github.com/pageton/temp-mail/internal/search"
+)
+
+// Postgres is the Store for PostgreSQL. Its queries are generated from
+// internal/sqlc/postgres and converted to the types of package db.
+type Postgres struct {
+	pool *sql.DB   // nil inside InTx
+	conn pgdb.DBTX // pool, or the transaction inside InTx
+	q    *pgdb.Queries
+}
+
+func NewPostgres(database *sql.DB) *Postgres {
+	return &Postgres{pool: database, conn: database, q: pgdb.New(database)}
+}
+
+// convert maps rows of one generated type to another.
+func convert[T, U any](rows []T, f func(T) U) []U {
+	if rows == nil {
+		return nil
+	}
+	out := make([]U, len(rows))
+	for i, r := range rows {
+		out[i] = f(r)
+	}
+	return out
+}
+
+func (p *Postgres) AddressInUse(ctx context.Context, address string) (sql.NullBool, error) {
+	return p.q.AddressInUse(ctx, address)
+}
+
+func (p *Postgres) CountInboxesForAddress(ctx context.Context, address sql.NullString) (int64, error) {
+	return p.q.CountInboxesForAddress(ctx, address)
+}
+
+func (p *Postgres) DeleteAdminKey(ctx context.Context, id string) (int64, error) {
+	return p.q.DeleteAdminKey(ctx, id)
+}
+
+func (p *Postgres) DeleteByInboxID(ctx context.Context, id string) error {
+	return p.q.DeleteByInboxID(ctx, id)
+}
+
+func (p *Postgres) DeleteDomain(ctx context.Context, name string) (int64, error) {
+	return p.q.DeleteDomain(ctx, name)
+}
+
+func (p *Postgres) DeleteEmailsByID(ctx context.Context, ids []int64) (int64, error) {
+	return p.q.DeleteEmailsByID(ctx, ids)
+}
+
+func (p *Postgres) DeleteEventsBefore(ctx context.Context, before int64) (int64, error) {
+	return p.q.DeleteEventsBefore(ctx, before)
+}
+
+func (p *Postgres) DeleteExpiredAddresses(ctx context.Context, expiresat int64) (int64, error) {
+	return p.q.DeleteExpiredAddresses(ctx, expiresat)
+}
+
+func (p *Postgres) DeleteExpiredRateLimits(ctx context.Context, expiresat int64) (int64, error) {
+	return p.q.DeleteExpiredRateLimits(ctx, expiresat)
+}
+
+func (p *Postgres) DeleteInboxesByID(ctx context.Context, ids []string) (int64, error) {
+	return p.q.DeleteInboxesByID(ctx, ids)
+}
+
+func (p *Postgres) DeleteOrphanedEmails(ctx context.Context, ids []int64) (int64, error) {
+	return p.q.DeleteOrphanedEmails(ctx, ids)
+}
+
+func (p *Postgres) DeleteWebhook(ctx context.Context, id string) (int64, error) {
+	return p.q.DeleteWebhook(ctx, id)
+}
+
+func (p *Postgres) GetActiveDomainNames(ctx context.Context) ([]string, error) {
+	return p.q.GetActiveDomainNames(ctx)
+}
+
+func (p *Postgres) GetAddress(ctx context.Context, address string) (db.Address, error) {
+	row, err := p.q.GetAddress(ctx, address)
+	return db.Address(row), err
+}
+
+func (p *Postgres) GetAddressClaim(ctx context.Context, address string) (db.Addressclaim, error) {
+	row, err := p.q.GetAddressClaim(ctx, address)
+	return db.Addressclaim(row), err
+}
+
+func (p *Postgres) GetAddressRetention(ctx context.Context, address string) (int64, error) {
+	return p.q.GetAddressRetention(ctx, address)
+}
+
+func (p *Postgres) GetAddressSummaries(ctx context.Context, arg db.GetAddressSummariesParams) ([]db.GetAddressSummariesRow, error) {
+	rows, err := p.q.GetAddressSummaries(ctx, pgdb.GetAddressSummariesParams(arg))
+	return convert(rows, func(r pgdb.GetAddressSummariesRow) db.GetAddressSummariesRow {
+		return db.GetAddressSummariesRow(r)
+	}), err
+}
+
+func (p *Postgres) GetAdminKeyByHash(ctx context.Context, keyhash string) (db.GetAdminKeyByHashRow, error) {
+	row, err := p.q.GetAdminKeyByHash(ctx, keyhash)
+	return db.GetAdminKeyByHashRow(row), err
+}
+
+func (p *Postgres) GetAdminKeys(ctx context.Context) ([]db.GetAdminKeysRow, error) {
+	rows, err := p.q.GetAdminKeys(ctx)
+	return convert(rows, func(r pgdb.GetAdminKeysRow) db.GetAdminKeysRow { return db.GetAdminKeysRow(r) }), err
+}
+
+func (p *Postgres) GetAttachmentForInbox(ctx context.Context, arg db.GetAttachmentForInboxParams) (db.GetAttachmentForInboxRow, error) {
+	row, err := p.q.GetAttachmentForInbox(ctx, pgdb.GetAttachmentForInboxParams(arg))
+	return db.GetAttachmentForInboxRow(row), err
+}
+
+func (p *Postgres) GetAttachmentsByInboxID(ctx context.Context, id string) ([]db.GetAttachmentsByInboxIDRow, error) {
+	rows, err := p.q.GetAttachmentsByInboxID(ctx, id)
+	return convert(rows, func(r pgdb.GetAttachmentsByInboxIDRow) db.GetAttachmentsByInboxIDRow {
+		return db.GetAttachmentsByInboxIDRow(r)
+	}), err
+}
+
+func (p *Postgres) GetDomain(ctx context.Context, name string) (db.Domain, error) {
+	row, err := p.q.GetDomain(ctx, name)
+	return db.Domain(row), err
+}
+
+func (p *Postgres) GetDomainList(ctx context.Context) ([]db.Domain, error) {
+	rows, err := p.q.GetDomainList(ctx)
+	return convert(rows, func(r pgdb.Domain) db.Domain { return db.Domain(r) }), err
+}
+
+func (p *Postgres) GetDueWebhookDeliveries(ctx context.Context, arg db.GetDueWebhookDeliveriesParams) ([]db.GetDueWebhookDeliveriesRow, error) {
+	rows, err := p.q.GetDueWebhookDeliveries(ctx, pgdb.GetDueWebhookDeliveriesParams(arg))
+	return convert(rows, func(r pgdb.GetDueWebhookDeliveriesRow) db.GetDueWebhookDeliveriesRow {
+		return db.GetDueWebhookDeliveriesRow(r)
+	}), err
+}
+
+func (p *Postgres) GetEmailRecipients(ctx context.Context, emailID sql.NullInt64) ([]sql.NullString, error) {
+	return p.q.GetEmailRecipients(ctx, emailID)
+}
+
+func (p *Postgres) GetEmailTimesForAddress(ctx context.Context, address sql.NullString) ([]db.GetEmailTimesForAddressRow, error) {
+	rows, err := p.q.GetEmailTimesForAddress(ctx, address)
+	return convert(rows, func(r pgdb.GetEmailTimesForAddressRow) db.GetEmailTimesForAddressRow {
+		return db.GetEmailTimesForAddressRow(r)
+	}), err
+}
+
+func (p *Postgres) GetEmailsForAddress(ctx context.Context, arg db.GetEmailsForAddressParams) ([]db.GetEmailsForAddressRow, error) {
+	rows, err := p.q.GetEmailsForAddress(ctx, pgdb.GetEmailsForAddressParams(arg))
+	return convert(rows, func(r pgdb.GetEmailsForAddressRow) db.GetEmailsForAddressRow {
+		return db.GetEmailsForAddressRow(r)
+	}), err
+}
+
+func (p *Postgres) GetEventsAfter(ctx context.Context, arg db.GetEventsAfterParams) ([]db.GetEventsAfterRow, error) {
+	rows, err := p.q.GetEventsAfter(ctx, pgdb.GetEventsAfterParams(arg))
+	return convert(rows, func(r pgdb.GetEventsAfterRow) db.GetEventsAfterRow { return db.GetEventsAfterRow(r) }), err
+}
+
+func (p *Postgres) GetExpiredEmailIDs(ctx context.Context, arg db.GetExpiredEmailIDsParams) ([]int64, error) {
+	return p.q.GetExpiredEmailIDs(ctx, pgdb.GetExpiredEmailIDsParams(arg))
+}
+
+func (p *Postgres) GetInboxByID(ctx context.Context, id string) (db.GetInboxByIDRow, error) {
+	row, err := p.q.GetInboxByID(ctx, id)
+	return db.GetInboxByIDRow(row), err
+}
+
+func (p *Postgres) GetInboxRecipients(ctx context.Context, inboxID string) ([]sql.NullString, error) {
+	return p.q.GetInboxRecipients(ctx, inboxID)
+}
+
+func (p *Postgres) GetInboxesByEmailIDs(ctx context.Context, emailIds []sql.NullInt64) ([]db.GetInboxesByEmailIDsRow, error) {
+	ids := make([]int64, 0, len(emailIds))
+	for _, id := range emailIds {
+		if id.Valid {
+			ids = append(ids, id.Int64)
+		}
+	}
+	rows, err := p.q.GetInboxesByEmailIDs(ctx, ids)
+	return convert(rows, func(r pgdb.GetInboxesByEmailIDsRow) db.GetInboxesByEmailIDsRow {
+		return db.GetInboxesByEmailIDsRow(r)
+	}), err
+}
+
+func (p *Postgres) GetInboxesForPurge(ctx context.Context, arg db.GetInboxesForPurgeParams) ([]db.GetInboxesForPurgeRow, error) {
+	rows, err := p.q.GetInboxesForPurge(ctx, pgdb.GetInboxesForPurgeParams(arg))
+	return convert(rows, func(r pgdb.GetInboxesForPurgeRow) db.GetInboxesForPurgeRow {
+		return db.GetInboxesForPurgeRow(r)
+	}), err
+}
+
+func (p *Postgres) GetLastEventID(ctx context.Context) (int64, error) {
+	return p.q.GetLastEventID(ctx)
+}
+
+func (p *Postgres) GetLeaseResult(ctx context.Context, name string) (sql.NullString, error) {
+	return p.q.GetLeaseResult(ctx, name)
+}
+
+func (p *Postgres) GetNextEmailForAddress(ctx context.Context, arg db.GetNextEmailForAddressParams) (db.GetNextEmailForAddressRow, error) {
+	row, err := p.q.GetNextEmailForAddress(ctx, pgdb.GetNextEmailForAddressParams(arg))
+	return db.GetNextEmailForAddressRow(row), err
+}
+
+func (p *Postgres) GetRawMessageByInboxID(ctx context.Context, id string) (db.GetRawMessageByInboxIDRow, error) {
+	row, err := p.q.GetRawMessageByInboxID(ctx, id)
+	return db.GetRawMessageByInboxIDRow(row), err
+}
+
+func (p *Postgres) GetStats(ctx context.Context) (db.GetStatsRow, error) {
+	row, err := p.q.GetStats(ctx)
+	return db.GetStatsRow(row), err
+}
+
+func (p *Postgres) GetWebhook(ctx context.Context, id string) (db.Webhook, error) {
+	row, err := p.q.GetWebhook(ctx, id)
+	return db.Webhook(row), err
+}
+
+func (p *Postgres) GetWebhookDeliveries(ctx context.Context, arg db.GetWebhookDeliveriesParams) ([]db.GetWebhookDeliveriesRow, error) {
+	rows, err := p.q.GetWebhookDeliveries(ctx, pgdb.GetWebhookDeliveriesParams(arg))
+	return convert(rows, func(r pgdb.GetWebhookDeliveriesRow) db.GetWebhookDeliveriesRow {
+		return db.GetWebhookDeliveriesRow(r)
+	}), err
+}
+
+func (p *Postgres) GetWebhooks(ctx context.Context) ([]db.Webhook, error) {
+	rows, err := p.q.GetWebhooks(ctx)
+	return convert(rows, func(r pgdb.Webhook) db.Webhook { return db.Webhook(r) }), err
+}
+
+func (p *Postgres) HitRateLimit(ctx context.Context, arg db.HitRateLimitParams) (db.HitRateLimitRow, error) {
+	row, err := p.q.HitRateLimit(ctx, pgdb.HitRateLimitParams(arg))
+	return db.HitRateLimitRow(row), err
+}
+
+func (p *Postgres) InTx(ctx context.Context, fn func(Store) error) error {
+	if p.pool == nil {
+		return fn(p)
+	}
+	return inTx(ctx, p.pool, func(tx *sql.Tx) error {
+		return fn(&Postgres{conn: tx, q: p.q.WithTx(tx)})
+	})
+}
+
+func (p *Postgres) InsertAddress(ctx context.Context, arg db.InsertAddressParams) (int64, error) {
+	return p.q.InsertAddress(ctx, pgdb.InsertAddressParams(arg))
+}
+
+func (p *Postgres) InsertAddressClaim(ctx context.Context, arg db.InsertAddressClaimParams) (int64, error) {
+	return p.q.InsertAddressClaim(ctx, pgdb.InsertAddressClaimParams(arg))
+}
+
+func (p *Postgres) InsertAdminKey(ctx context.Context, arg db.InsertAdminKeyParams) error {
+	return p.q.InsertAdminKey(ctx, pgdb.InsertAdminKeyParams(arg))
+}
+
+func (p *Postgres) InsertAttachment(ctx context.Context, arg db.InsertAttachmentParams) error {
+	return p.q.InsertAttachment(ctx, pgdb.InsertAttachmentParams(arg))
+}
+
+func (p *Postgres) InsertDomain(ctx context.Context, arg db.InsertDomainParams) (int64, error) {
+	return p.q.InsertDomain(ctx, pgdb.InsertDomainParams(arg))
+}
+
+func (p *Postgres) InsertEmail(ctx context.Context, arg db.InsertEmailParams) (int64, error) {
+	return p.q.InsertEmail(ctx, pgdb.InsertEmailParams(arg))
+}
+
+func (p *Postgres) InsertEmailAddress(ctx context.Context, arg db.InsertEmailAddressParams) error {
+	return p.q.InsertEmailAddress(ctx, pgdb.InsertEmailAddressParams(arg))
+}
+
+func (p *Postgres) InsertEvent(ctx context.Context, arg db.InsertEventParams) error {
+	return p.q.InsertEvent(ctx, pgdb.InsertEventParams(arg))
+}
+
+func (p *Postgres) InsertInbox(ctx context.Context, arg db.InsertInboxParams) error {
+	return p.q.InsertInbox(ctx, pgdb.InsertInboxParams(arg))
+}
+
+func (p *Postgres) InsertLease(ctx context.Context, arg db.InsertLeaseParams) (int64, error) {
+	return p.q.InsertLease(ctx, pgdb.InsertLeaseParams(arg))
+}
+
+func (p *Postgres) InsertRawMessage(ctx context.Context, arg db.InsertRawMessageParams) error {
+	return p.q.InsertRawMessage(ctx, pgdb.InsertRawMessageParams(arg))
+}
+
+func (p *Postgres) InsertSearchEntry(ctx context.Context, arg db.InsertSearchEntryParams) error {
+	return p.q.InsertSearchEntry(ctx, pgdb.InsertSearchEntryParams(arg))
+}
+
+func (p *Postgres) InsertWebhook(ctx context.Context, arg db.InsertWebhookParams) error {
+	return p.q.InsertWebhook(ctx, pgdb.InsertWebhookParams(arg))
+}
+
+func (p *Postgres) InsertWebhookDelivery(ctx context.Context, arg db.InsertWebhookDeliveryParams) error {
+	return p.q.InsertWebhookDelivery(ctx, pgdb.InsertWebhookDeliveryParams(arg))
+}
+
+func (p *Postgres) LeaseWebhookDeliveries(ctx context.Context, arg db.LeaseWebhookDeliveriesParams) error {
+	return p.q.LeaseWebhookDeliveries(ctx, pgdb.LeaseWebhookDeliveriesParams(arg))
+}
+
+func (p *Postgres) SetLeaseResult(ctx context.Context, arg db.SetLeaseResultParams) error {
+	return p.q.SetLeaseResult(ctx, pgdb.SetLeaseResultParams(arg))
+}
+
+func (p *Postgres) TakeLease(ctx context.Context, arg db.TakeLeaseParams) (int64, error) {
+	return p.q.TakeLease(ctx, pgdb.TakeLeaseParams(arg))
+}
+
+func (p *Postgres) UpdateAdminKeyLastUsed(ctx context.Context, arg db.UpdateAdminKeyLastUsedParams) error {
+	return p.q.UpdateAdminKeyLastUsed(ctx, pgdb.UpdateAdminKeyLastUsedParams(arg))
+}
+
+func (p *Postgres) UpdateDomain(ctx context.Context, arg db.UpdateDomainParams) (int64, error) {
+	return p.q.UpdateDomain(ctx, pgdb.UpdateDomainParams(arg))
+}
+
+func (p *Postgres) UpdateEmailExpiry(ctx context.Context, arg db.UpdateEmailExpiryParams) error {
+	return p.q.UpdateEmailExpiry(ctx, pgdb.UpdateEmailExpiryParams(arg))
+}
+
+func (p *Postgres) UpdateInboxEmailExpiry(ctx context.Context, arg db.UpdateInboxEmailExpiryParams) error {
+	return p.q.UpdateInboxEmailExpiry(ctx, pgdb.UpdateInboxEmailExpiryParams(arg))
+}
+
+func (p *Postgres) UpdateWebhookDelivery(ctx context.Context, arg db.UpdateWebhookDeliveryParams) error {
+	return p.q.UpdateWebhookDelivery(ctx, pgdb.UpdateWebhookDeliveryParams(arg))
+}
+
+func (p *Postgres) UpsertAddressRetention(ctx context.Context, arg db.UpsertAddressRetentionParams) error {
+	return p.q.UpsertAddressRetention(ctx, pgdb.UpsertAddressRetentionParams(arg))
+}
+
+// SearchEnabled always reports true: the PostgreSQL index is part of the
+// schema.
+func (p *Postgres) SearchEnabled() bool {
+	return true
+}
+
+// Search translates the parsed query into a tsquery over EmailSearch.document,
+// or over a single column for from: and subject: terms, and ranks matches
+// with the same column weights as the SQLite index.
+func (p *Postgres) Search(ctx context.Context, address, query string, limit int64) ([]db.SearchEmailsForAddressRow, error) {
+	terms, err := search.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []any{address}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	tsquery := func(t search.Term) string {
+		q := "phraseto_tsquery('simple', " + arg(t.Text) + ")"
+		if t.Prefix {
+			q = "(" + q + "::text || ':*')::tsquery"
+		}
+		return q
+	}
+
+	// SQL gives NOT, AND and OR the same precedence as FTS5, so the terms
+	// map one to one. Adjacent terms are joined with AND.
+	var where, all []string
+	for i, t := range terms {
+		if t.Operator != "" {
+			if t.Operator == "NOT" {
+				where = append(where, "AND NOT")
+			} else {
+				where = append(where, t.Operator)
+			}
+			continue
+		}
+		if i > 0 && terms[i-1].Operator == "" {
+			where = append(where, "AND")
+		}
+		q := tsquery(t)
+		all = append(all, q)
+		if t.Column != "" {
+			where = append(where, "to_tsvector('simple', EmailSearch."+t.Column+") @@ "+q)
+		} else {
+			where = append(where, "EmailSearch.document @@ "+q)
+		}
+	}
+	rank := strings.Join(all, " || ")
+	limitArg := arg(limit)
+
+	rows, err := p.conn.QueryContext(ctx, `
+SELECT
+  Inbox.id,
+  Email.subject,
+  Email.createdAt,
+  Email.expiresAt,
+  (SELECT address FROM EmailAddress WHERE emailId = Email.id AND type = 'from' LIMIT 1) as fromAddress,
+  COALESCE((SELECT string_agg(address, ', ') FROM EmailAddress WHERE emailId = Email.id AND type = 'to'), '') as toAddress,
+  ts_headline('simple', EmailSearch.subject, `+rank+`,
+    'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', HighlightAll=true') as subjectHighlight,
+  ts_headline('simple', EmailSearch.textContent || ' ' || EmailSearch.htmlText, `+rank+`,
+    'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', MaxWords=16, MinWords=8') as snippet
+FROM EmailSearch
+JOIN Inbox ON Inbox.id = EmailSearch.inboxId
+JOIN Email ON Email.id = Inbox.emailId
+WHERE EmailSearch.address = $1 AND (`+strings.Join(where, " ")+`)
+ORDER BY ts_rank(EmailSearch.document, `+rank+`) DESC
+LIMIT `+limitArg, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []db.SearchEmailsForAddressRow
+	for rows.Next() {
+		var i db.SearchEmailsForAddressRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Subject,
+			&i.Createdat,
+			&i.Expiresat,
+			&i.Fromaddress,
+			&i.Toaddress,
+			&i.Subjecthighlight,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+var _ Store = (*Postgres)(nil)
+var _ Store = (*SQLite)(nil)
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/search"
+)
+
+// SQLite is the Store for the embedded SQLite database.
+type SQLite struct {
+	*db.Queries
+	db *sql.DB // nil inside InTx
+}
+
+func NewSQLite(database *sql.DB) *SQLite {
+	return &SQLite{Queries: db.New(database), db: database}
+}
+
+func (s *SQLite) InTx(ctx context.Context, fn func(Store) error) error {
+	if s.db == nil {
+		return fn(s)
+	}
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		return fn(&SQLite{Queries: s.Queries.WithTx(tx)})
+	})
+}
+
+// deliveryLease is how long GetDueWebhookDeliveries holds back the returned
+// deliveries from other dispatchers, like the PostgreSQL query does.
+const deliveryLease = 5 * time.Minute
+
+// GetDueWebhookDeliveries returns the deliveries that are due and leases them
+// in the same transaction, so that two dispatchers on one database do not
+// attempt the same delivery.
+func (s *SQLite) GetDueWebhookDeliveries(ctx context.Context, arg db.GetDueWebhookDeliveriesParams) ([]db.GetDueWebhookDeliveriesRow, error) {
+	var due []db.GetDueWebhookDeliveriesRow
+	err := s.InTx(ctx, func(tx Store) error {
+		var err error
+		due, err = tx.(*SQLite).Queries.GetDueWebhookDeliveries(ctx, arg)
+		if err != nil || len(due) == 0 {
+			return err
+		}
+		ids := make([]int64, len(due))
+		for i, d := range due {
+			ids[i] = d.ID
+		}
+		return tx.LeaseWebhookDeliveries(ctx, db.LeaseWebhookDeliveriesParams{
+			Until: arg.Nextattemptat + deliveryLease.Milliseconds(),
+			Ids:   ids,
+		})
+	})
+	return due, err
+}
+
+func (s *SQLite) Search(ctx context.Context, address, query string, limit int64) ([]db.SearchEmailsForAddressRow, error) {
+	match, err := search.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return s.SearchEmailsForAddress(ctx, db.SearchEmailsForAddressParams{
+		Query:   match,
+		Address: address,
+		Limit:   limit,
+	})
+}
+
+// SearchEnabled reports whether SQLite was built with FTS5.
+func (s *SQLite) SearchEnabled() bool {
+	return search.Enabled()
+}
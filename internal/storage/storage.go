@@ -0,0 +1,93 @@
+// Package storage contains the storage backends for the application.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/db"
+)
+
+// Backends selectable with backend in [database].
+const (
+	BackendSQLite   = "sqlite"
+	BackendPostgres = "postgres"
+)
+
+// Store is the data access used by the application. Every backend returns
+// the row and parameter types generated for SQLite in package db.
+type Store interface {
+	db.Querier
+
+	// Search runs a user search query (see search.Parse) against the mail of
+	// address. It returns search.ErrEmptyQuery for queries without terms.
+	Search(ctx context.Context, address, query string, limit int64) ([]db.SearchEmailsForAddressRow, error)
+	// SearchEnabled reports whether the backend has a search index.
+	SearchEnabled() bool
+	// InTx runs fn with a Store whose queries share one transaction. It
+	// commits when fn returns nil and rolls back otherwise. Calls on a Store
+	// that is already in a transaction join it.
+	InTx(ctx context.Context, fn func(Store) error) error
+}
+
+// inTx runs fn in a transaction on database.
+func inTx(ctx context.Context, database *sql.DB, fn func(*sql.Tx) error) error {
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Open connects to the backend selected in cfg. The returned *sql.DB is the
+// underlying connection pool, used for migrations and closing.
+func Open(cfg config.DatabaseConfig) (Store, *sql.DB, error) {
+	switch cfg.Backend {
+	case "", BackendSQLite:
+		database, err := sql.Open("sqlite3", sqliteDSN(cfg))
+		if err != nil {
+			return nil, nil, err
+		}
+		return NewSQLite(database), database, nil
+	case BackendPostgres:
+		if cfg.DSN == "" {
+			return nil, nil, errors.New("database.dsn is required for the postgres backend")
+		}
+		database, err := sql.Open("postgres", cfg.DSN)
+		if err != nil {
+			return nil, nil, err
+		}
+		return NewPostgres(database), database, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown database backend %q", cfg.Backend)
+	}
+}
+
+// sqliteDSN adds the connection settings to the database path. They apply to
+// every pooled connection: WAL lets readers run alongside the writer, the busy
+// timeout makes writers from other connections and prefork processes wait for
+// the lock, and immediate transactions take it up front instead of failing
+// when a read transaction later tries to write.
+func sqliteDSN(cfg config.DatabaseConfig) string {
+	params := url.Values{}
+	params.Set("_busy_timeout", strconv.FormatInt(cfg.BusyTimeout.Milliseconds(), 10))
+	params.Set("_foreign_keys", "on")
+	params.Set("_journal_mode", "WAL")
+	params.Set("_synchronous", "NORMAL")
+	params.Set("_txlock", "immediate")
+	sep := "?"
+	if strings.Contains(cfg.Path, "?") {
+		sep = "&"
+	}
+	return cfg.Path + sep + params.Encode()
+}
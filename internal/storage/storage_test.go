@@ -0,0 +1,376 @@
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/pubsub"
+	"github.com/pageton/temp-mail/internal/search"
+	"github.com/pageton/temp-mail/internal/storage"
+	"github.com/pageton/temp-mail/internal/storage/storagetest"
+)
+
+// TestConformance runs the same checks against every backend, so that the
+// SQLite queries and their PostgreSQL translations behave alike. PostgreSQL
+// is skipped unless storagetest.PostgresEnv is set.
+func TestConformance(t *testing.T) {
+	cases := map[string]func(*testing.T, storage.Store){
+		"InTx":            testInTx,
+		"SubjectFilter":   testSubjectFilter,
+		"Recipients":      testRecipients,
+		"DeliveryLease":   testDeliveryLease,
+		"Lease":           testLease,
+		"CountForAddress": testCountForAddress,
+		"RateLimit":       testRateLimit,
+		"Events":          testEvents,
+		"RelayOrder":      testRelayOrder,
+		"Search":          testSearch,
+	}
+	for backend, open := range storagetest.Backends() {
+		t.Run(backend, func(t *testing.T) {
+			for name, run := range cases {
+				t.Run(name, func(t *testing.T) {
+					queries, _ := open(t)
+					run(t, queries)
+				})
+			}
+		})
+	}
+}
+
+// insertMail stores one message with subject for addresses and returns the
+// email ID.
+func insertMail(t *testing.T, queries storage.Store, subject string, addresses ...string) int64 {
+	t.Helper()
+	ctx := context.Background()
+	emailID, err := queries.InsertEmail(ctx, db.InsertEmailParams{
+		Subject:   sql.NullString{String: subject, Valid: true},
+		Createdat: 1,
+		Expiresat: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, address := range addresses {
+		err := queries.InsertInbox(ctx, db.InsertInboxParams{
+			ID:      address + "/" + subject,
+			Emailid: sql.NullInt64{Int64: emailID, Valid: true},
+			Address: sql.NullString{String: address, Valid: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return emailID
+}
+
+func testInTx(t *testing.T, queries storage.Store) {
+	ctx := context.Background()
+	errRollback := errors.New("rollback")
+	err := queries.InTx(ctx, func(tx storage.Store) error {
+		insertMail(t, tx, "rolled back", "alice@example.com")
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("InTx = %v, want the error of fn", err)
+	}
+	err = queries.InTx(ctx, func(tx storage.Store) error {
+		// A nested InTx joins the outer transaction.
+		return tx.InTx(ctx, func(tx storage.Store) error {
+			insertMail(t, tx, "committed", "alice@example.com")
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := queries.CountInboxesForAddress(ctx, sql.NullString{String: "alice@example.com", Valid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("%d inbox entries stored, want only the committed one", n)
+	}
+}
+
+func testSubjectFilter(t *testing.T, queries storage.Store) {
+	ctx := context.Background()
+	for _, subject := range []string{"100% off", "1000 offers", "a_b", "axb"} {
+		insertMail(t, queries, subject, "alice@example.com")
+	}
+	for filter, want := range map[string]string{
+		`100\% off`: "100% off",
+		`a\_b`:      "a_b",
+	} {
+		emails, err := queries.GetEmailsForAddress(ctx, db.GetEmailsForAddressParams{
+			Address: sql.NullString{String: "alice@example.com", Valid: true},
+			Subject: sql.NullString{String: filter, Valid: true},
+			Limit:   10,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(emails) != 1 || emails[0].Subject.String != want {
+			t.Errorf("subject filter %q matched %d emails, want only %q", filter, len(emails), want)
+		}
+	}
+}
+
+func testRecipients(t *testing.T, queries storage.Store) {
+	ctx := context.Background()
+	emailID := insertMail(t, queries, "shared", "alice@example.com", "bob@example.com")
+
+	recipients, err := queries.GetEmailRecipients(ctx, sql.NullInt64{Int64: emailID, Valid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, len(recipients))
+	for i, r := range recipients {
+		got[i] = r.String
+	}
+	sort.Strings(got)
+	if len(got) != 2 || got[0] != "alice@example.com" || got[1] != "bob@example.com" {
+		t.Errorf("GetEmailRecipients = %v, want alice and bob", got)
+	}
+
+	recipients, err = queries.GetInboxRecipients(ctx, "alice@example.com/shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recipients) != 2 {
+		t.Errorf("GetInboxRecipients returned %d addresses, want 2", len(recipients))
+	}
+}
+
+func testSearch(t *testing.T, queries storage.Store) {
+	if !queries.SearchEnabled() {
+		t.Skip("SQLite built without FTS5; run with -tags sqlite_fts5")
+	}
+	ctx := context.Background()
+	insertMail(t, queries, "reset your password", "alice@example.com")
+	if err := search.Index(ctx, queries, "alice@example.com/reset your password", "reset your password", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	// Terms of only punctuation are dropped, together with their operators.
+	for _, query := range []string{"reset ...*", "--* OR password", "subject:!!! reset", "pass* AND ?*"} {
+		rows, err := queries.Search(ctx, "alice@example.com", query, 10)
+		if err != nil {
+			t.Errorf("Search(%q): %v", query, err)
+			continue
+		}
+		if len(rows) != 1 {
+			t.Errorf("Search(%q) found %d emails, want 1", query, len(rows))
+		}
+	}
+	if _, err := queries.Search(ctx, "alice@example.com", `... "!"*`, 10); !errors.Is(err, search.ErrEmptyQuery) {
+		t.Errorf("Search of only punctuation = %v, want ErrEmptyQuery", err)
+	}
+}
+
+func testDeliveryLease(t *testing.T, queries storage.Store) {
+	ctx := context.Background()
+	err := queries.InsertWebhook(ctx, db.InsertWebhookParams{
+		ID:      "hook",
+		Url:     "https://example.com/hook",
+		Secret:  "secret",
+		Pattern: "*@example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, inboxID := range []string{"a", "b"} {
+		err := queries.InsertWebhookDelivery(ctx, db.InsertWebhookDeliveryParams{
+			Webhookid:     "hook",
+			Inboxid:       inboxID,
+			Payload:       "{}",
+			Nextattemptat: 1000,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	params := db.GetDueWebhookDeliveriesParams{Nextattemptat: 2000, Limit: 10}
+	due, err := queries.GetDueWebhookDeliveries(ctx, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 2 || due[0].Url != "https://example.com/hook" || due[0].Secret != "secret" {
+		t.Fatalf("GetDueWebhookDeliveries = %+v, want both deliveries of hook", due)
+	}
+	if due, err = queries.GetDueWebhookDeliveries(ctx, params); err != nil || len(due) != 0 {
+		t.Errorf("GetDueWebhookDeliveries while leased = %d deliveries, %v; want none", len(due), err)
+	}
+	params.Nextattemptat += 300000
+	if due, err = queries.GetDueWebhookDeliveries(ctx, params); err != nil || len(due) != 2 {
+		t.Errorf("GetDueWebhookDeliveries after the lease = %d deliveries, %v; want 2", len(due), err)
+	}
+}
+
+func testLease(t *testing.T, queries storage.Store) {
+	ctx := context.Background()
+	n, err := queries.InsertLease(ctx, db.InsertLeaseParams{Name: "job", Holder: "a", ExpiresAt: 100})
+	if err != nil || n != 1 {
+		t.Fatalf("InsertLease = %d, %v; want 1", n, err)
+	}
+	if n, err = queries.InsertLease(ctx, db.InsertLeaseParams{Name: "job", Holder: "b", ExpiresAt: 100}); err != nil || n != 0 {
+		t.Errorf("second InsertLease = %d, %v; want 0", n, err)
+	}
+	take := func(holder string, now int64) int64 {
+		t.Helper()
+		n, err := queries.TakeLease(ctx, db.TakeLeaseParams{Holder: holder, ExpiresAt: now + 100, Name: "job", Now: now})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if take("b", 50) != 0 {
+		t.Error("TakeLease took a held lease")
+	}
+	if take("a", 50) != 1 {
+		t.Error("TakeLease did not renew the holder's lease")
+	}
+	if take("b", 150) != 1 {
+		t.Error("TakeLease did not take an expired lease")
+	}
+
+	for _, name := range []string{"job", "unleased"} {
+		result := sql.NullString{String: `{"Emails":1}`, Valid: true}
+		if err := queries.SetLeaseResult(ctx, db.SetLeaseResultParams{Name: name, Result: result}); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := queries.GetLeaseResult(ctx, name); err != nil || got != result {
+			t.Errorf("GetLeaseResult(%q) = %v, %v; want %v", name, got, err, result)
+		}
+	}
+	if take("a", 150) != 0 {
+		t.Error("SetLeaseResult released the lease")
+	}
+}
+
+func testCountForAddress(t *testing.T, queries storage.Store) {
+	ctx := context.Background()
+	insertMail(t, queries, "one", "alice@example.com")
+	insertMail(t, queries, "two", "alice@example.com", "bob@example.com")
+	n, err := queries.CountInboxesForAddress(ctx, sql.NullString{String: "alice@example.com", Valid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("CountInboxesForAddress = %d, want 2", n)
+	}
+}
+
+func testRateLimit(t *testing.T, queries storage.Store) {
+	ctx := context.Background()
+	hit := func(now int64) db.HitRateLimitRow {
+		t.Helper()
+		row, err := queries.HitRateLimit(ctx, db.HitRateLimitParams{Key: "ip", WindowStart: now, ExpiresAt: now + 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return row
+	}
+	if row := hit(0); row.Hits != 1 || row.Expiresat != 100 {
+		t.Errorf("first hit = %+v, want 1 until 100", row)
+	}
+	if row := hit(50); row.Hits != 2 || row.Expiresat != 100 {
+		t.Errorf("second hit = %+v, want 2 until 100", row)
+	}
+	if row := hit(100); row.Hits != 1 || row.Expiresat != 200 {
+		t.Errorf("hit in a new window = %+v, want 1 until 200", row)
+	}
+}
+
+func testEvents(t *testing.T, queries storage.Store) {
+	ctx := context.Background()
+	for i, origin := range []string{"a", "b", "a"} {
+		err := queries.InsertEvent(ctx, db.InsertEventParams{Origin: origin, Payload: "{}", CreatedAt: int64(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	last, err := queries.GetLastEventID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := queries.GetEventsAfter(ctx, db.GetEventsAfterParams{After: last - 2, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Origin != "b" || events[1].ID != last {
+		t.Errorf("GetEventsAfter = %+v, want the last two events", events)
+	}
+	if n, err := queries.DeleteEventsBefore(ctx, 2); err != nil || n != 2 {
+		t.Errorf("DeleteEventsBefore = %d, %v; want 2", n, err)
+	}
+}
+
+// testRelayOrder commits an event after one with a higher ID has been
+// relayed, which PostgreSQL allows since IDs are taken when an insert starts.
+func testRelayOrder(t *testing.T, queries storage.Store) {
+	if _, ok := queries.(*storage.Postgres); !ok {
+		t.Skip("SQLite commits events in ID order")
+	}
+	ctx := context.Background()
+	hub := pubsub.NewHub()
+	relay, err := pubsub.NewRelay(ctx, hub, queries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := hub.Subscribe("example.com")
+	defer sub.Close()
+	insert := func(queries storage.Store, inboxID string) error {
+		return queries.InsertEvent(ctx, db.InsertEventParams{
+			Origin:  "other",
+			Payload: `{"type":"received","address":"alice@example.com","inboxId":"` + inboxID + `"}`,
+		})
+	}
+	poll := func() []string {
+		t.Helper()
+		if err := relay.Poll(ctx); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for {
+			select {
+			case e := <-sub.Events():
+				got = append(got, e.InboxID)
+			default:
+				return got
+			}
+		}
+	}
+
+	inserted, commit, done := make(chan struct{}), make(chan struct{}), make(chan error)
+	go func() {
+		done <- queries.InTx(ctx, func(tx storage.Store) error {
+			err := insert(tx, "late")
+			close(inserted)
+			<-commit
+			return err
+		})
+	}()
+	<-inserted
+	if err := insert(queries, "early"); err != nil {
+		t.Fatal(err)
+	}
+	if got := poll(); len(got) != 1 || got[0] != "early" {
+		t.Fatalf("poll before the late commit = %v, want early", got)
+	}
+	close(commit)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := poll(); len(got) != 1 || got[0] != "late" {
+		t.Errorf("poll after the late commit = %v, want late", got)
+	}
+	if got := poll(); len(got) != 0 {
+		t.Errorf("poll without new events = %v, want none", got)
+	}
+}
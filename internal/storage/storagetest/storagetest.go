@@ -0,0 +1,80 @@
+// Package storagetest opens migrated databases for tests.
+package storagetest
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/migrate"
+	"github.com/pageton/temp-mail/internal/search"
+	"github.com/pageton/temp-mail/internal/storage"
+)
+
+// PostgresEnv names the environment variable with a PostgreSQL DSN. Tests
+// against PostgreSQL are skipped when it is unset. The database is expected
+// to be disposable: every test drops and recreates the public schema.
+const PostgresEnv = "TEMP_MAIL_TEST_POSTGRES_DSN"
+
+// SQLite returns a store on a new, migrated SQLite database in a temporary
+// directory, with the busy timeout LoadConfig defaults to.
+func SQLite(t testing.TB) (storage.Store, *sql.DB) {
+	t.Helper()
+	return open(t, config.DatabaseConfig{
+		Backend:     storage.BackendSQLite,
+		Path:        filepath.Join(t.TempDir(), "mail.db"),
+		BusyTimeout: 5 * time.Second,
+	})
+}
+
+// Postgres returns a store on an empty, migrated PostgreSQL database, or
+// skips the test when PostgresEnv is not set.
+func Postgres(t testing.TB) (storage.Store, *sql.DB) {
+	t.Helper()
+	dsn := os.Getenv(PostgresEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", PostgresEnv)
+	}
+	cfg := config.DatabaseConfig{Backend: storage.BackendPostgres, DSN: dsn}
+	_, database, err := storage.Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = database.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public")
+	database.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return open(t, cfg)
+}
+
+// Backends returns the backends to run a suite against, by name.
+func Backends() map[string]func(testing.TB) (storage.Store, *sql.DB) {
+	return map[string]func(testing.TB) (storage.Store, *sql.DB){
+		storage.BackendSQLite:   SQLite,
+		storage.BackendPostgres: Postgres,
+	}
+}
+
+func open(t testing.TB, cfg config.DatabaseConfig) (storage.Store, *sql.DB) {
+	t.Helper()
+	store, database, err := storage.Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	ctx := context.Background()
+	if _, err = migrate.Up(ctx, database, cfg.Backend); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Backend == storage.BackendSQLite {
+		if err = search.Setup(ctx, database); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store, database
+}
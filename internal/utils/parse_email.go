@@ -4,6 +4,7 @@ package utils
 import (
 	"log"
 	"net/mail"
+	"strings"
 )
 
 func ParseEmailAddresses(header string) []string {
@@ -24,3 +25,13 @@ func ParseEmailAddresses(header string) []string {
 
 	return result
 }
+
+// EmailDomain returns the lower-cased domain part of an email address, or an
+// empty string if the address has no domain.
+func EmailDomain(address string) string {
+	i := strings.LastIndex(address, "@")
+	if i < 0 {
+		return ""
+	}
+	return strings.ToLower(address[i+1:])
+}
@@ -0,0 +1,161 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/db"
+)
+
+const (
+	defaultMaxAttempts = 8
+	defaultTimeout     = 10 * time.Second
+	baseBackoff        = 10 * time.Second
+	maxBackoff         = time.Hour
+	pollInterval       = time.Second
+	batchSize          = 32
+)
+
+// Dispatcher posts pending deliveries and reschedules failed ones with
+// exponential backoff until they succeed or run out of attempts.
+type Dispatcher struct {
+	queries     db.Querier
+	client      *http.Client
+	maxAttempts int64
+
+	// Now returns the current time; replace it to run against a fake clock.
+	Now func() time.Time
+}
+
+func NewDispatcher(cfg *config.Config, queries db.Querier) *Dispatcher {
+	maxAttempts := cfg.Webhooks.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	timeout := cfg.Webhooks.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Dispatcher{
+		queries:     queries,
+		client:      newClient(timeout, cfg.Webhooks.AllowPrivate),
+		maxAttempts: int64(maxAttempts),
+		Now:         time.Now,
+	}
+}
+
+// Start polls the queue until ctx is cancelled.
+func (d *Dispatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				d.RunOnce(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// RunOnce attempts every delivery that is due and waits for them to finish.
+func (d *Dispatcher) RunOnce(ctx context.Context) {
+	due, err := d.queries.GetDueWebhookDeliveries(ctx, db.GetDueWebhookDeliveriesParams{
+		Nextattemptat: d.Now().UnixMilli(),
+		Limit:         batchSize,
+	})
+	if err != nil {
+		log.Println("Error getting webhook deliveries:", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range due {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.deliver(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery db.GetDueWebhookDeliveriesRow) {
+	attempts := delivery.Attempts + 1
+	status, err := d.post(ctx, delivery)
+
+	params := db.UpdateWebhookDeliveryParams{
+		ID:             delivery.ID,
+		Attempts:       attempts,
+		Responsestatus: sql.NullInt64{Int64: int64(status), Valid: status != 0},
+	}
+	now := d.Now()
+	switch {
+	case err == nil:
+		params.Status = "delivered"
+		params.Nextattemptat = now.UnixMilli()
+		params.Deliveredat = sql.NullInt64{Int64: now.UnixMilli(), Valid: true}
+	case attempts >= d.maxAttempts:
+		params.Status = "failed"
+		params.Nextattemptat = now.UnixMilli()
+		params.Lasterror = sql.NullString{String: err.Error(), Valid: true}
+	default:
+		params.Status = "pending"
+		params.Nextattemptat = now.Add(backoff(attempts)).UnixMilli()
+		params.Lasterror = sql.NullString{String: err.Error(), Valid: true}
+	}
+	if err := d.queries.UpdateWebhookDelivery(ctx, params); err != nil {
+		log.Println("Error updating webhook delivery:", err)
+	}
+}
+
+// post sends one delivery and returns the response status, with an error
+// for transport failures and non-2xx responses.
+func (d *Dispatcher) post(ctx context.Context, delivery db.GetDueWebhookDeliveriesRow) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := d.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "temp-mail-webhooks")
+	req.Header.Set(HeaderEvent, "email.received")
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt: 10s, 20s, 40s, ...
+// capped at one hour.
+func backoff(attempts int64) time.Duration {
+	delay := baseBackoff << (attempts - 1)
+	if delay <= 0 || delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,154 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pageton/temp-mail/config"
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/pubsub"
+	"github.com/pageton/temp-mail/internal/storage/storagetest"
+)
+
+func TestDispatcherRetries(t *testing.T) {
+	const secret = "s3cret"
+	var now time.Time
+
+	// The receiver fails twice before accepting the delivery.
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		body, _ := io.ReadAll(r.Body)
+		err := Verify(secret, r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature), body, time.Minute, now)
+		if err != nil {
+			t.Errorf("request %d: %v", requests, err)
+		}
+		if r.Header.Get(HeaderEvent) != "email.received" || r.Header.Get(HeaderDelivery) == "" {
+			t.Errorf("request %d: headers %v", requests, r.Header)
+		}
+		if requests < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	queries, _ := storagetest.SQLite(t)
+	err := queries.InsertWebhook(ctx, db.InsertWebhookParams{
+		ID:      "hook",
+		Url:     srv.URL,
+		Secret:  secret,
+		Pattern: "example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Enqueue(ctx, queries, []pubsub.Event{{
+		Type:    pubsub.EventReceived,
+		Address: "alice@example.com",
+		InboxID: "inbox",
+	}}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Webhooks: config.WebhooksConfig{MaxAttempts: 5, AllowPrivate: true}}
+	d := NewDispatcher(cfg, queries)
+	now = time.UnixMilli(time.Now().UnixMilli()) // Stored times have millisecond precision
+	d.Now = func() time.Time { return now }
+
+	steps := []struct {
+		advance  time.Duration
+		requests int
+		status   string
+		attempts int64
+		next     time.Duration // Delay until the next attempt
+	}{
+		{0, 1, "pending", 1, 10 * time.Second},
+		{5 * time.Second, 1, "pending", 1, 5 * time.Second}, // Not due yet
+		{5 * time.Second, 2, "pending", 2, 20 * time.Second},
+		{20 * time.Second, 3, "delivered", 3, 0},
+	}
+	for i, step := range steps {
+		now = now.Add(step.advance)
+		d.RunOnce(ctx)
+
+		rows, err := queries.GetWebhookDeliveries(ctx, db.GetWebhookDeliveriesParams{Webhookid: "hook", Limit: 10})
+		if err != nil || len(rows) != 1 {
+			t.Fatalf("step %d: %d deliveries (err %v), want 1", i, len(rows), err)
+		}
+		row := rows[0]
+		mu.Lock()
+		got := requests
+		mu.Unlock()
+		if got != step.requests || row.Status != step.status || row.Attempts != step.attempts {
+			t.Fatalf("step %d: %d requests, status %s, %d attempts; want %d, %s, %d",
+				i, got, row.Status, row.Attempts, step.requests, step.status, step.attempts)
+		}
+		if step.status == "pending" {
+			if next := time.UnixMilli(row.Nextattemptat).Sub(now); next != step.next {
+				t.Errorf("step %d: next attempt in %v, want %v", i, next, step.next)
+			}
+			if row.Responsestatus.Int64 != http.StatusInternalServerError {
+				t.Errorf("step %d: response status %v", i, row.Responsestatus)
+			}
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempts, want := range map[int64]time.Duration{
+		1:  10 * time.Second,
+		2:  20 * time.Second,
+		3:  40 * time.Second,
+		9:  2560 * time.Second,
+		10: time.Hour,
+		64: time.Hour,
+	} {
+		if got := backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestDispatcherMarksFailed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	queries, _ := storagetest.SQLite(t)
+	if err := queries.InsertWebhook(ctx, db.InsertWebhookParams{ID: "hook", Url: srv.URL, Secret: "s", Pattern: "example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Enqueue(ctx, queries, []pubsub.Event{{Type: pubsub.EventReceived, Address: "a@example.com", InboxID: "inbox"}}, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Webhooks: config.WebhooksConfig{MaxAttempts: 2, AllowPrivate: true}}
+	d := NewDispatcher(cfg, queries)
+	now := time.Now()
+	d.Now = func() time.Time { return now }
+	for range 2 {
+		d.RunOnce(ctx)
+		now = now.Add(time.Hour)
+	}
+	rows, err := queries.GetWebhookDeliveries(ctx, db.GetWebhookDeliveriesParams{Webhookid: "hook", Limit: 10})
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("%d deliveries (err %v), want 1", len(rows), err)
+	}
+	if rows[0].Status != "failed" || rows[0].Lasterror.String != "unexpected status "+strconv.Itoa(http.StatusBadGateway)+" Bad Gateway" {
+		t.Errorf("delivery = %+v, want failed with the response status", rows[0])
+	}
+}
@@ -0,0 +1,245 @@
+// Package webhooks contains the outbound webhook queue for the application.
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pageton/temp-mail/internal/db"
+	"github.com/pageton/temp-mail/internal/pubsub"
+	"github.com/pageton/temp-mail/internal/utils"
+)
+
+// Headers set on every delivery.
+const (
+	HeaderSignature = "X-TempMail-Signature"
+	HeaderTimestamp = "X-TempMail-Timestamp"
+	HeaderDelivery  = "X-TempMail-Delivery"
+	HeaderEvent     = "X-TempMail-Event"
+)
+
+// Payload is the JSON body posted to webhook URLs.
+type Payload struct {
+	Event   pubsub.EventType `json:"event"`
+	Address string           `json:"address"`
+	Email   Email            `json:"email"`
+	Text    *string          `json:"text,omitempty"`
+	HTML    *string          `json:"html,omitempty"`
+}
+
+// Email is the message summary in a Payload.
+type Email struct {
+	ID          string    `json:"id"`
+	Subject     string    `json:"subject,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	FromAddress string    `json:"fromAddress,omitempty"`
+	ToAddress   string    `json:"toAddress"`
+}
+
+// Match reports whether a webhook pattern covers address. A pattern is an
+// address, an address pattern using * and ? wildcards, or a bare domain.
+func Match(pattern, address string) bool {
+	pattern = strings.ToLower(pattern)
+	address = strings.ToLower(address)
+	if !strings.Contains(pattern, "@") {
+		return pattern == utils.EmailDomain(address)
+	}
+	ok, err := path.Match(pattern, address)
+	return err == nil && ok
+}
+
+// ValidPattern reports whether pattern is well formed.
+func ValidPattern(pattern string) bool {
+	_, err := path.Match(pattern, "")
+	return err == nil && pattern != "" && !strings.ContainsAny(pattern, "/ ")
+}
+
+// Literal returns the one address an address pattern without wildcards
+// matches, with any escaping removed, and false for other patterns.
+func Literal(pattern string) (string, bool) {
+	if !strings.Contains(pattern, "@") || strings.ContainsAny(pattern, "*?[") {
+		return "", false
+	}
+	var b strings.Builder
+	escaped := false
+	for _, r := range pattern {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String(), true
+}
+
+// Enqueue stores a pending delivery for every webhook matching the events.
+// text and html are included for webhooks registered with includeBody.
+func Enqueue(ctx context.Context, queries db.Querier, events []pubsub.Event, text, html string) error {
+	hooks, err := queries.GetWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixMilli()
+	for _, e := range events {
+		claim, err := queries.GetAddressClaim(ctx, e.Address)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		claimed := err == nil
+		for _, hook := range hooks {
+			if !Match(hook.Pattern, e.Address) {
+				continue
+			}
+			// A claimed address only reaches webhooks registered for exactly
+			// that address after the claim, which required its token.
+			if claimed && (hook.Pattern != e.Address || hook.Createdat.Int64 < claim.Createdat.Int64) {
+				continue
+			}
+			payload := Payload{
+				Event:   e.Type,
+				Address: e.Address,
+				Email: Email{
+					ID:          e.InboxID,
+					Subject:     e.Subject,
+					CreatedAt:   e.CreatedAt,
+					ExpiresAt:   e.ExpiresAt,
+					FromAddress: e.FromAddress,
+					ToAddress:   e.ToAddress,
+				},
+			}
+			if hook.Includebody {
+				if text != "" {
+					payload.Text = &text
+				}
+				if html != "" {
+					payload.HTML = &html
+				}
+			}
+			body, err := json.Marshal(payload)
+			if err != nil {
+				return err
+			}
+			err = queries.InsertWebhookDelivery(ctx, db.InsertWebhookDeliveryParams{
+				Webhookid:     hook.ID,
+				Inboxid:       e.InboxID,
+				Payload:       string(body),
+				Nextattemptat: now,
+			})
+			if err != nil {
+				return fmt.Errorf("inserting webhook delivery: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	ErrMissingToken = errors.New("webhook token required")
+	ErrInvalidToken = errors.New("invalid webhook token")
+)
+
+// NewToken returns the bearer token that manages a new webhook and the hash
+// stored for it.
+func NewToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+// Authorize checks a bearer token against hook. Webhooks created before
+// tokens existed accept their signing secret instead.
+func Authorize(hook db.Webhook, token string) error {
+	if token == "" {
+		return ErrMissingToken
+	}
+	got, want := token, hook.Secret
+	if hook.Tokenhash.Valid {
+		got, want = hashToken(token), hook.Tokenhash.String
+	}
+	if subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Sign returns the signature header value for a delivery body: the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignMessage returns the signature header value for a message posted to
+// /webhook: the hex HMAC-SHA256 of "<timestamp>.<envelope>\n<body>", where
+// envelope is the recipients joined with commas. Covering the envelope stops
+// a captured request from being replayed to other addresses; it cannot hold
+// a newline, so the boundary to the body is unambiguous.
+func SignMessage(secret string, timestamp int64, envelope []string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.%s\n", timestamp, strings.Join(envelope, ","))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrStaleTimestamp   = errors.New("timestamp outside the replay window")
+)
+
+// Verify checks a signed request against secret. The timestamp header holds
+// Unix seconds and must be within window of now, so a captured request cannot
+// be replayed later. Identical requests inside the window are accepted:
+// Postfix delivers the same message once per recipient.
+func Verify(secret, timestamp, signature string, body []byte, window time.Duration, now time.Time) error {
+	return verify(secret, timestamp, signature, window, now, func(ts int64) string {
+		return Sign(secret, ts, body)
+	})
+}
+
+// VerifyMessage is Verify for a message signed with SignMessage.
+func VerifyMessage(secret, timestamp, signature string, envelope []string, body []byte, window time.Duration, now time.Time) error {
+	return verify(secret, timestamp, signature, window, now, func(ts int64) string {
+		return SignMessage(secret, ts, envelope, body)
+	})
+}
+
+func verify(secret, timestamp, signature string, window time.Duration, now time.Time, sign func(int64) string) error {
+	if secret == "" {
+		return ErrInvalidSignature
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > window || d < -window {
+		return ErrStaleTimestamp
+	}
+	if !hmac.Equal([]byte(signature), []byte(sign(ts))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package webhooks
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/pageton/temp-mail/internal/db"
+)
+
+func TestAuthorize(t *testing.T) {
+	token, hash, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook := db.Webhook{Secret: "secret", Tokenhash: sql.NullString{String: hash, Valid: true}}
+	legacy := db.Webhook{Secret: "secret"}
+
+	tests := []struct {
+		name  string
+		hook  db.Webhook
+		token string
+		want  error
+	}{
+		{"token", hook, token, nil},
+		{"missing", hook, "", ErrMissingToken},
+		{"wrong", hook, "wrong", ErrInvalidToken},
+		{"secret is not the token", hook, "secret", ErrInvalidToken},
+		{"hash is not the token", hook, hash, ErrInvalidToken},
+		{"legacy secret", legacy, "secret", nil},
+		{"legacy wrong", legacy, token, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		if err := Authorize(tt.hook, tt.token); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Authorize = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Package middlewares contains the middlewares for the application.
+package middlewares
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pageton/temp-mail/internal/adminkeys"
+	"github.com/pageton/temp-mail/internal/storage"
+)
+
+// AdminAuth guards the /admin routes with an admin API key, sent as
+// "Authorization: Bearer <key>". Keys are created with
+// "temp-mail admin-key create"; address tokens are not accepted.
+func AdminAuth(c *fiber.Ctx) error {
+	queries := c.Locals("queries").(storage.Store)
+	key, err := adminkeys.Authenticate(c.Context(), queries, adminKey(c))
+	switch {
+	case err == nil:
+		c.Locals("adminKey", key.Name)
+		return c.Next()
+	case errors.Is(err, adminkeys.ErrMissingKey), errors.Is(err, adminkeys.ErrInvalidKey):
+		return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{"error": err.Error()})
+	default:
+		log.Println("Error checking admin API key:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error checking admin API key"})
+	}
+}
+
+// adminKey returns the key from the Authorization header. Unlike address
+// tokens it is never read from the query string, where it would end up in
+// access logs.
+func adminKey(c *fiber.Ctx) string {
+	if auth := c.Get(fiber.HeaderAuthorization); len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
@@ -2,16 +2,34 @@
 package middlewares
 
 import (
+	"log"
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/limiter"
+
+	"github.com/pageton/temp-mail/internal/db"
+)
+
+// Requests allowed per client IP and window.
+const (
+	rateLimitMax    = 40
+	rateLimitWindow = time.Minute
 )
 
-func RateLimiter(app *fiber.App) {
+// RateLimiter limits each client IP to 40 requests a minute. The counters
+// are kept in process memory, or in the database when queries is not nil,
+// so that prefork processes share them.
+func RateLimiter(app *fiber.App, queries db.Querier) {
+	if queries != nil {
+		app.Use(sharedLimiter(queries))
+		return
+	}
 	app.Use(limiter.New(limiter.Config{
-		Max:        40,
-		Expiration: 1 * time.Minute,
+		Max:        rateLimitMax,
+		Expiration: rateLimitWindow,
 		KeyGenerator: func(c *fiber.Ctx) string {
 			return c.IP()
 		},
@@ -20,3 +38,31 @@ func RateLimiter(app *fiber.App) {
 		},
 	}))
 }
+
+// sharedLimiter counts requests in the RateLimit table with one atomic
+// upsert per request, and sets the same headers as the limiter middleware.
+// Requests are let through when the counter cannot be updated. Expired rows
+// are removed by the cleaner.
+func sharedLimiter(queries db.Querier) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		now := time.Now()
+		hit, err := queries.HitRateLimit(c.Context(), db.HitRateLimitParams{
+			Key:         c.IP(),
+			WindowStart: now.UnixMilli(),
+			ExpiresAt:   now.Add(rateLimitWindow).UnixMilli(),
+		})
+		if err != nil {
+			log.Println("Error counting request for rate limit:", err)
+			return c.Next()
+		}
+		reset := int64(math.Ceil(float64(hit.Expiresat-now.UnixMilli()) / 1000))
+		if hit.Hits > rateLimitMax {
+			c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(reset, 10))
+			return c.SendStatus(fiber.StatusTooManyRequests)
+		}
+		c.Set("X-RateLimit-Limit", strconv.Itoa(rateLimitMax))
+		c.Set("X-RateLimit-Remaining", strconv.FormatInt(rateLimitMax-hit.Hits, 10))
+		c.Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		return c.Next()
+	}
+}
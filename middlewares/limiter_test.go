@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pageton/temp-mail/internal/storage/storagetest"
+)
+
+// TestSharedLimiterConcurrent checks that concurrent requests, as from
+// several prefork processes, are counted once each.
+func TestSharedLimiterConcurrent(t *testing.T) {
+	queries, _ := storagetest.SQLite(t)
+	app := fiber.New()
+	RateLimiter(app, queries)
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+
+	var allowed, limited atomic.Int64
+	var wg sync.WaitGroup
+	for range 2 * rateLimitMax {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil), -1)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			switch res.StatusCode {
+			case fiber.StatusNoContent:
+				allowed.Add(1)
+			case fiber.StatusTooManyRequests:
+				if res.Header.Get(fiber.HeaderRetryAfter) == "" {
+					t.Error("429 without Retry-After")
+				}
+				limited.Add(1)
+			default:
+				t.Errorf("status %d", res.StatusCode)
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed.Load() != rateLimitMax || limited.Load() != rateLimitMax {
+		t.Errorf("%d requests allowed and %d limited, want %d each", allowed.Load(), limited.Load(), rateLimitMax)
+	}
+}
@@ -0,0 +1,57 @@
+// Package middlewares contains the middlewares for the application.
+package middlewares
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pageton/temp-mail/internal/claims"
+	"github.com/pageton/temp-mail/internal/storage"
+)
+
+// AddressOwner guards routes with an :email or :inboxid parameter. Claimed
+// addresses require their token as "Authorization: Bearer <token>", or in the
+// token query parameter for clients such as EventSource that cannot set
+// headers. Unclaimed addresses stay open.
+func AddressOwner(c *fiber.Ctx) error {
+	queries := c.Locals("queries").(storage.Store)
+
+	address := strings.ToLower(c.Params("email"))
+	if inboxID := c.Params("inboxid"); address == "" && inboxID != "" {
+		inbox, err := queries.GetInboxByID(c.Context(), inboxID)
+		if err != nil {
+			// Unknown inboxes are reported by the handler.
+			return c.Next()
+		}
+		address = inbox.Address.String
+	}
+	if address == "" {
+		return c.Next()
+	}
+
+	err := claims.Check(c.Context(), queries, address, BearerToken(c))
+	switch {
+	case err == nil:
+		return c.Next()
+	case errors.Is(err, claims.ErrMissingToken):
+		return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{"error": err.Error()})
+	case errors.Is(err, claims.ErrInvalidToken):
+		return c.Status(fiber.StatusForbidden).JSON(&fiber.Map{"error": err.Error()})
+	default:
+		log.Println("Error checking address claim:", err)
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(&fiber.Map{"error": "Error checking address claim"})
+	}
+}
+
+// BearerToken returns the token from the Authorization header or the token
+// query parameter.
+func BearerToken(c *fiber.Ctx) string {
+	if auth := c.Get(fiber.HeaderAuthorization); len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return c.Query("token")
+}